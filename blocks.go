@@ -0,0 +1,133 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/Serux/chirpy/internal/database"
+)
+
+// postBlockHandler blocks a user. From then on neither of them sees the
+// other's chirps, so neither can reply to them, and they can't mention or
+// follow each other. Follows and follow requests between the two are removed
+// in the same transaction, along with their chirps in each other's home
+// timeline.
+func (cfg *apiConfig) postBlockHandler(rw http.ResponseWriter, r *http.Request) {
+	uidtok, err := cfg.authenticatedUserId(r)
+	if err != nil {
+		respondWithError(rw, http.StatusUnauthorized, "Something went wrong validating JWT")
+		return
+	}
+	target, ok := cfg.targetUser(rw, r)
+	if !ok {
+		return
+	}
+	blocked := target.ID
+	if blocked == uidtok {
+		respondWithError(rw, http.StatusBadRequest, "You can't block yourself")
+		return
+	}
+
+	tx, err := cfg.db.BeginTx(r.Context(), nil)
+	if err != nil {
+		respondWithError(rw, http.StatusInternalServerError, "Something went wrong blocking user")
+		return
+	}
+	defer tx.Rollback()
+	q := cfg.queries.WithTx(tx)
+
+	_, err = q.InsertBlock(r.Context(), database.InsertBlockParams{BlockerID: uidtok, BlockedID: blocked})
+	if err != nil {
+		respondWithError(rw, http.StatusInternalServerError, "Something went wrong blocking user")
+		return
+	}
+	err = q.DeleteFollowsBetween(r.Context(), database.DeleteFollowsBetweenParams{FollowerID: uidtok, FolloweeID: blocked})
+	if err != nil {
+		respondWithError(rw, http.StatusInternalServerError, "Something went wrong removing follows")
+		return
+	}
+	err = q.DeleteFollowRequestsBetween(r.Context(), database.DeleteFollowRequestsBetweenParams{RequesterID: uidtok, TargetID: blocked})
+	if err != nil {
+		respondWithError(rw, http.StatusInternalServerError, "Something went wrong removing follows")
+		return
+	}
+	err = q.DeleteTimelineEntriesBetween(r.Context(), database.DeleteTimelineEntriesBetweenParams{UserID: uidtok, AuthorID: blocked})
+	if err != nil {
+		respondWithError(rw, http.StatusInternalServerError, "Something went wrong updating timeline")
+		return
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		respondWithError(rw, http.StatusInternalServerError, "Something went wrong blocking user")
+		return
+	}
+	rw.WriteHeader(http.StatusNoContent)
+}
+
+// deleteBlockHandler unblocks a user. Follows removed by the block stay gone.
+func (cfg *apiConfig) deleteBlockHandler(rw http.ResponseWriter, r *http.Request) {
+	uidtok, err := cfg.authenticatedUserId(r)
+	if err != nil {
+		respondWithError(rw, http.StatusUnauthorized, "Something went wrong validating JWT")
+		return
+	}
+	target, ok := cfg.targetUser(rw, r)
+	if !ok {
+		return
+	}
+	blocked := target.ID
+
+	_, err = cfg.queries.DeleteBlock(r.Context(), database.DeleteBlockParams{BlockerID: uidtok, BlockedID: blocked})
+	if err != nil {
+		respondWithError(rw, http.StatusInternalServerError, "Something went wrong unblocking user")
+		return
+	}
+	rw.WriteHeader(http.StatusNoContent)
+}
+
+// postMuteHandler mutes a user. Unlike a block it only affects the muter:
+// the muted user's chirps are left out of their lists, home timeline, search
+// and notifications, but can still be opened directly.
+func (cfg *apiConfig) postMuteHandler(rw http.ResponseWriter, r *http.Request) {
+	uidtok, err := cfg.authenticatedUserId(r)
+	if err != nil {
+		respondWithError(rw, http.StatusUnauthorized, "Something went wrong validating JWT")
+		return
+	}
+	target, ok := cfg.targetUser(rw, r)
+	if !ok {
+		return
+	}
+	muted := target.ID
+	if muted == uidtok {
+		respondWithError(rw, http.StatusBadRequest, "You can't mute yourself")
+		return
+	}
+
+	_, err = cfg.queries.InsertMute(r.Context(), database.InsertMuteParams{MuterID: uidtok, MutedID: muted})
+	if err != nil {
+		respondWithError(rw, http.StatusInternalServerError, "Something went wrong muting user")
+		return
+	}
+	rw.WriteHeader(http.StatusNoContent)
+}
+
+func (cfg *apiConfig) deleteMuteHandler(rw http.ResponseWriter, r *http.Request) {
+	uidtok, err := cfg.authenticatedUserId(r)
+	if err != nil {
+		respondWithError(rw, http.StatusUnauthorized, "Something went wrong validating JWT")
+		return
+	}
+	target, ok := cfg.targetUser(rw, r)
+	if !ok {
+		return
+	}
+	muted := target.ID
+
+	_, err = cfg.queries.DeleteMute(r.Context(), database.DeleteMuteParams{MuterID: uidtok, MutedID: muted})
+	if err != nil {
+		respondWithError(rw, http.StatusInternalServerError, "Something went wrong unmuting user")
+		return
+	}
+	rw.WriteHeader(http.StatusNoContent)
+}
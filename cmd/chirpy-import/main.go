@@ -0,0 +1,221 @@
+// Command chirpy-import brings a JSON or CSV archive of posts from another
+// platform into a Chirpy account, keeping their original timestamps.
+//
+//	chirpy-import -token $CHIRPY_TOKEN -source twitter tweets.js
+//
+// The archive goes to POST /api/chirps/import in batches. Progress is saved
+// to a state file after every batch, so running the same command again
+// after an interruption carries on where it stopped. The server skips rows
+// it already imported, so overlapping a batch is harmless.
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+
+	"github.com/Serux/chirpy/internal/importer"
+)
+
+type importResult struct {
+	Imported int `json:"imported"`
+	Skipped  int `json:"skipped"`
+	Failed   int `json:"failed"`
+	Errors   []struct {
+		Row      int    `json:"row"`
+		SourceId string `json:"source_id"`
+		Error    string `json:"error"`
+	} `json:"errors"`
+}
+
+// state is what's kept between runs: how many rows of the archive are done
+// and the totals so far.
+type state struct {
+	Source   string `json:"source"`
+	RowsDone int    `json:"rows_done"`
+	Imported int    `json:"imported"`
+	Skipped  int    `json:"skipped"`
+	Failed   int    `json:"failed"`
+}
+
+const maxAttempts = 3
+
+func main() {
+	server := flag.String("server", "http://localhost:8080", "Chirpy server URL")
+	token := flag.String("token", os.Getenv("CHIRPY_TOKEN"), "access token, defaults to $CHIRPY_TOKEN")
+	source := flag.String("source", "archive", "where the archive comes from, ids are deduplicated per source")
+	format := flag.String("format", "", "json or csv, guessed from the file name when empty")
+	batchSize := flag.Int("batch", 100, "rows per request, at most 1000")
+	statePath := flag.String("state", "", "progress file, defaults to the archive path plus .import-state")
+	restart := flag.Bool("restart", false, "ignore saved progress and start from the first row")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: chirpy-import [flags] ARCHIVE\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() != 1 || *token == "" || *batchSize < 1 || *batchSize > 1000 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	archive := flag.Arg(0)
+	if *format == "" {
+		*format = importer.DetectFormat(archive, "")
+	}
+	if *statePath == "" {
+		*statePath = archive + ".import-state"
+	}
+
+	f, err := os.Open(archive)
+	if err != nil {
+		fail(err)
+	}
+	rows, err := importer.Parse(f, *format)
+	f.Close()
+	if err != nil {
+		fail(err)
+	}
+	// Sorted before batching so a reply is never sent before its parent.
+	// The saved rows_done counts rows in this order.
+	importer.SortByCreatedAt(rows)
+
+	st := state{Source: *source}
+	if !*restart {
+		saved, err := loadState(*statePath)
+		if err != nil {
+			fail(err)
+		}
+		if saved != nil && saved.Source == *source && saved.RowsDone <= len(rows) {
+			st = *saved
+			fmt.Printf("resuming after row %d of %d\n", st.RowsDone, len(rows))
+		}
+	}
+
+	client := &http.Client{Timeout: 5 * time.Minute}
+	endpoint := *server + "/api/chirps/import?" + url.Values{"source": {*source}, "format": {"json"}}.Encode()
+	for st.RowsDone < len(rows) {
+		end := min(st.RowsDone+*batchSize, len(rows))
+		batch := []importer.Row{}
+		for _, row := range rows[st.RowsDone:end] {
+			// Rows that didn't parse are reported here and never sent.
+			if row.Err != nil {
+				st.Failed++
+				fmt.Printf("row %d: %v\n", row.Line, row.Err)
+				continue
+			}
+			batch = append(batch, row)
+		}
+
+		if len(batch) > 0 {
+			res, err := send(client, endpoint, *token, batch)
+			if err != nil {
+				fail(fmt.Errorf("%w (progress is saved, run again to resume)", err))
+			}
+			for _, e := range res.Errors {
+				line := e.Row
+				if e.Row >= 1 && e.Row <= len(batch) {
+					line = batch[e.Row-1].Line
+				}
+				fmt.Printf("row %d (id %s): %s\n", line, e.SourceId, e.Error)
+			}
+			st.Imported += res.Imported
+			st.Skipped += res.Skipped
+			st.Failed += res.Failed
+		}
+
+		st.RowsDone = end
+		err = saveState(*statePath, st)
+		if err != nil {
+			fail(err)
+		}
+		fmt.Printf("%d/%d rows: %d imported, %d skipped, %d failed\n", st.RowsDone, len(rows), st.Imported, st.Skipped, st.Failed)
+	}
+	fmt.Println("done")
+}
+
+// send posts one batch, trying again on network and server errors. That's
+// safe because the server skips rows it already imported.
+func send(client *http.Client, endpoint, token string, batch []importer.Row) (importResult, error) {
+	body, err := json.Marshal(batch)
+	if err != nil {
+		return importResult{}, err
+	}
+	for attempt := 1; ; attempt++ {
+		res, retry, err := post(client, endpoint, token, body)
+		if err == nil || !retry || attempt == maxAttempts {
+			return res, err
+		}
+		fmt.Printf("%v, trying again\n", err)
+		time.Sleep(time.Duration(attempt) * 2 * time.Second)
+	}
+}
+
+func post(client *http.Client, endpoint, token string, body []byte) (importResult, bool, error) {
+	req, err := http.NewRequest(http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return importResult{}, false, err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := client.Do(req)
+	if err != nil {
+		return importResult{}, true, err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return importResult{}, true, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		msg := struct {
+			Error string `json:"error"`
+		}{}
+		json.Unmarshal(data, &msg)
+		return importResult{}, resp.StatusCode >= 500, fmt.Errorf("server answered %s: %s", resp.Status, msg.Error)
+	}
+	res := importResult{}
+	err = json.Unmarshal(data, &res)
+	return res, false, err
+}
+
+func loadState(path string) (*state, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	st := &state{}
+	err = json.Unmarshal(data, st)
+	if err != nil {
+		return nil, fmt.Errorf("reading %s: %w", path, err)
+	}
+	return st, nil
+}
+
+// saveState writes to a temporary file and renames it, so an interrupted run
+// never leaves a half written state behind.
+func saveState(path string, st state) error {
+	data, err := json.MarshalIndent(st, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	err = os.WriteFile(tmp, data, 0o600)
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+func fail(err error) {
+	fmt.Fprintln(os.Stderr, "chirpy-import:", err)
+	os.Exit(1)
+}
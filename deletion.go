@@ -0,0 +1,209 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/Serux/chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+// How long a deleted account can still be recovered by logging in.
+const deletionGracePeriod = 14 * 24 * time.Hour
+const deletionBatchSize = 10
+
+// ghostUserId owns the tombstones left of erased accounts. It is never a
+// real user and can't log in or be followed.
+var ghostUserId = uuid.Max
+
+// ghostUserEmail is the ghost user's address, so nobody can sign up or
+// change their email to it.
+const ghostUserEmail = "ghost@chirpy.invalid"
+
+func reservedEmail(email string) bool {
+	return strings.EqualFold(strings.TrimSpace(email), ghostUserEmail)
+}
+
+// deleteUserHandler schedules the account for erasure after
+// deletionGracePeriod and signs out every session, so the only way back is
+// logging in again, which cancels it. Like changing the password it needs
+// current_password or a recent login.
+func (cfg *apiConfig) deleteUserHandler(rw http.ResponseWriter, r *http.Request) {
+	type requestJson struct {
+		CurrentPassword string `json:"current_password"`
+	}
+	type responseJson struct {
+		DeletionScheduledAt string `json:"deletion_scheduled_at"`
+	}
+
+	uidtok, sessionId, err := cfg.authenticatedSession(r)
+	if err != nil {
+		respondWithError(rw, http.StatusUnauthorized, "Something went wrong validating JWT")
+		return
+	}
+	decoder := json.NewDecoder(r.Body)
+	params := requestJson{}
+	err = decoder.Decode(&params)
+	if err != nil && !errors.Is(err, io.EOF) {
+		respondWithError(rw, http.StatusBadRequest, "Something went wrong decoding input")
+		return
+	}
+
+	user, err := cfg.queries.SelectUserById(r.Context(), uidtok)
+	if err != nil {
+		respondWithError(rw, http.StatusInternalServerError, "Something went wrong getting user")
+		return
+	}
+	ok, err := cfg.reauthenticated(r.Context(), user, sessionId, params.CurrentPassword)
+	if err != nil {
+		respondWithError(rw, http.StatusInternalServerError, "Something went wrong checking session")
+		return
+	}
+	if !ok {
+		respondWithError(rw, http.StatusForbidden, "Deleting the account needs current_password or a recent login")
+		return
+	}
+
+	tx, err := cfg.db.BeginTx(r.Context(), nil)
+	if err != nil {
+		respondWithError(rw, http.StatusInternalServerError, "Something went wrong deleting account")
+		return
+	}
+	defer tx.Rollback()
+	q := cfg.queries.WithTx(tx)
+
+	user, err = q.ScheduleUserDeletion(r.Context(), database.ScheduleUserDeletionParams{GraceSeconds: deletionGracePeriod.Seconds(), ID: uidtok})
+	if err != nil {
+		respondWithError(rw, http.StatusInternalServerError, "Something went wrong deleting account")
+		return
+	}
+	// No session is uuid.Nil, so this revokes them all.
+	err = q.RevokeOtherRefreshTokens(r.Context(), database.RevokeOtherRefreshTokensParams{UserID: uidtok, SessionID: uuid.Nil})
+	if err != nil {
+		respondWithError(rw, http.StatusInternalServerError, "Something went wrong revoking sessions")
+		return
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		respondWithError(rw, http.StatusInternalServerError, "Something went wrong deleting account")
+		return
+	}
+	respondWithJSON(rw, http.StatusAccepted, responseJson{DeletionScheduledAt: user.DeletionScheduledAt.Time.Format(time.RFC3339)})
+}
+
+// eraseUser removes everything of a user whose grace period is over. Their
+// chirps that other users replied to become tombstones owned by the ghost
+// user, so those replies still point at a chirp; the rest, along with media,
+// exports, follows, tokens and everything else, goes with the user row
+// through ON DELETE CASCADE. It returns the blob keys to delete once
+// committed.
+func eraseUser(ctx context.Context, q *database.Queries, userId uuid.UUID) ([]string, error) {
+	// A chirp whose only replies are the user's own is kept too once one of
+	// those replies became a tombstone, so go until nothing changes.
+	for {
+		ids, err := q.TombstoneRepliedChirpsUser(ctx, database.TombstoneRepliedChirpsUserParams{GhostID: ghostUserId, UserID: userId})
+		if err != nil {
+			return nil, err
+		}
+		if len(ids) == 0 {
+			break
+		}
+		err = q.DeleteTombstoneIndexes(ctx, ids)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	media, err := q.SelectMediaUser(ctx, userId)
+	if err != nil {
+		return nil, err
+	}
+	keys := []string{}
+	for _, m := range media {
+		keys = append(keys, m.BlobKey, m.ThumbnailKey)
+	}
+	exports, err := q.SelectExportsUser(ctx, userId)
+	if err != nil {
+		return nil, err
+	}
+	for _, e := range exports {
+		if e.BlobKey.Valid {
+			keys = append(keys, e.BlobKey.String)
+		}
+	}
+
+	err = q.DeleteUser(ctx, userId)
+	if err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+// eraseDueUsers erases one batch of accounts whose deletion is due and
+// returns how many it erased.
+func (cfg *apiConfig) eraseDueUsers(ctx context.Context) (int, error) {
+	tx, err := cfg.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+	q := cfg.queries.WithTx(tx)
+
+	users, err := q.SelectUsersDueForDeletion(ctx, deletionBatchSize)
+	if err != nil || len(users) == 0 {
+		return 0, err
+	}
+	err = q.EnsureGhostUser(ctx, ghostUserId)
+	if err != nil {
+		return 0, err
+	}
+	keys := []string{}
+	for _, u := range users {
+		userKeys, err := eraseUser(ctx, q, u.ID)
+		if err != nil {
+			return 0, err
+		}
+		keys = append(keys, userKeys...)
+	}
+	err = tx.Commit()
+	if err != nil {
+		return 0, err
+	}
+
+	// Same as purging the trash, files only go once their rows are gone.
+	for _, key := range keys {
+		err = cfg.blobs.Delete(ctx, key)
+		if err != nil {
+			fmt.Println("ERROR DELETING ERASED USER FILES", key, err)
+		}
+	}
+	return len(users), nil
+}
+
+// runDeletionWorker erases accounts past their grace period every tick until
+// ctx is cancelled, going again right away while there are full batches.
+func (cfg *apiConfig) runDeletionWorker(ctx context.Context, every time.Duration) {
+	ticker := time.NewTicker(every)
+	defer ticker.Stop()
+	for {
+		n, err := cfg.eraseDueUsers(ctx)
+		if err != nil {
+			fmt.Println("ERROR ERASING DELETED USERS", err)
+		}
+		if err == nil && n == deletionBatchSize {
+			continue
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
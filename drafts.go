@@ -0,0 +1,230 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/Serux/chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+// Drafts may run over the chirp limit while they're being worked on, they
+// only have to fit once they're published.
+const maxDraftLength = 4000
+
+type draftJson struct {
+	Id        string `json:"id"`
+	CreatedAt string `json:"created_at"`
+	UpdatedAt string `json:"updated_at"`
+	Body      string `json:"body"`
+}
+
+func draftToJson(d database.Draft) draftJson {
+	return draftJson{
+		Id:        d.ID.String(),
+		CreatedAt: d.CreatedAt.Format(time.RFC3339),
+		UpdatedAt: d.UpdatedAt.Format(time.RFC3339),
+		Body:      d.Body,
+	}
+}
+
+// decodeDraftBody reads the {"body": ...} payload shared by creating and
+// updating a draft.
+func decodeDraftBody(r *http.Request) (string, error) {
+	type requestJson struct {
+		Body string `json:"body"`
+	}
+	decoder := json.NewDecoder(r.Body)
+	params := requestJson{}
+	err := decoder.Decode(&params)
+	if err != nil {
+		return "", errors.New("Something went wrong decoding input")
+	}
+	if len(params.Body) > maxDraftLength {
+		return "", errors.New("Draft is too long")
+	}
+	return params.Body, nil
+}
+
+func (cfg *apiConfig) postDraftsHandler(rw http.ResponseWriter, r *http.Request) {
+	uidtok, err := cfg.authenticatedUserId(r)
+	if err != nil {
+		respondWithError(rw, http.StatusUnauthorized, "Something went wrong validating JWT")
+		return
+	}
+	body, err := decodeDraftBody(r)
+	if err != nil {
+		respondWithError(rw, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	draft, err := cfg.queries.CreateDraft(r.Context(), database.CreateDraftParams{UserID: uidtok, Body: body})
+	if err != nil {
+		respondWithError(rw, http.StatusInternalServerError, "Something went wrong creating draft")
+		return
+	}
+	respondWithJSON(rw, http.StatusCreated, draftToJson(draft))
+}
+
+func (cfg *apiConfig) getDraftsHandler(rw http.ResponseWriter, r *http.Request) {
+	uidtok, err := cfg.authenticatedUserId(r)
+	if err != nil {
+		respondWithError(rw, http.StatusUnauthorized, "Something went wrong validating JWT")
+		return
+	}
+
+	drafts, err := cfg.queries.SelectDraftsUser(r.Context(), uidtok)
+	if err != nil {
+		respondWithError(rw, http.StatusInternalServerError, "Something went wrong getting drafts")
+		return
+	}
+	ret := []draftJson{}
+	for _, d := range drafts {
+		ret = append(ret, draftToJson(d))
+	}
+	respondWithJSON(rw, http.StatusOK, ret)
+}
+
+func (cfg *apiConfig) getDraftHandler(rw http.ResponseWriter, r *http.Request) {
+	uidtok, err := cfg.authenticatedUserId(r)
+	if err != nil {
+		respondWithError(rw, http.StatusUnauthorized, "Something went wrong validating JWT")
+		return
+	}
+	draftId, err := uuid.Parse(r.PathValue("draftID"))
+	if err != nil {
+		respondWithError(rw, http.StatusNotFound, "Draft not found")
+		return
+	}
+
+	draft, err := cfg.queries.SelectOneDraft(r.Context(), database.SelectOneDraftParams{ID: draftId, UserID: uidtok})
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(rw, http.StatusNotFound, "Draft not found")
+		return
+	}
+	if err != nil {
+		respondWithError(rw, http.StatusInternalServerError, "Something went wrong getting draft")
+		return
+	}
+	respondWithJSON(rw, http.StatusOK, draftToJson(draft))
+}
+
+func (cfg *apiConfig) putDraftHandler(rw http.ResponseWriter, r *http.Request) {
+	uidtok, err := cfg.authenticatedUserId(r)
+	if err != nil {
+		respondWithError(rw, http.StatusUnauthorized, "Something went wrong validating JWT")
+		return
+	}
+	draftId, err := uuid.Parse(r.PathValue("draftID"))
+	if err != nil {
+		respondWithError(rw, http.StatusNotFound, "Draft not found")
+		return
+	}
+	body, err := decodeDraftBody(r)
+	if err != nil {
+		respondWithError(rw, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	draft, err := cfg.queries.UpdateDraft(r.Context(), database.UpdateDraftParams{Body: body, ID: draftId, UserID: uidtok})
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(rw, http.StatusNotFound, "Draft not found")
+		return
+	}
+	if err != nil {
+		respondWithError(rw, http.StatusInternalServerError, "Something went wrong updating draft")
+		return
+	}
+	respondWithJSON(rw, http.StatusOK, draftToJson(draft))
+}
+
+func (cfg *apiConfig) deleteDraftHandler(rw http.ResponseWriter, r *http.Request) {
+	uidtok, err := cfg.authenticatedUserId(r)
+	if err != nil {
+		respondWithError(rw, http.StatusUnauthorized, "Something went wrong validating JWT")
+		return
+	}
+	draftId, err := uuid.Parse(r.PathValue("draftID"))
+	if err != nil {
+		respondWithError(rw, http.StatusNotFound, "Draft not found")
+		return
+	}
+
+	_, err = cfg.queries.DeleteDraft(r.Context(), database.DeleteDraftParams{ID: draftId, UserID: uidtok})
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(rw, http.StatusNotFound, "Draft not found")
+		return
+	}
+	if err != nil {
+		respondWithError(rw, http.StatusInternalServerError, "Something went wrong deleting draft")
+		return
+	}
+	rw.WriteHeader(http.StatusNoContent)
+}
+
+// publishDraftHandler turns a draft into a chirp. Deleting the draft, creating
+// the chirp and indexing it happen in one transaction, so a draft is never
+// published twice and a failure leaves it untouched.
+func (cfg *apiConfig) publishDraftHandler(rw http.ResponseWriter, r *http.Request) {
+	uidtok, err := cfg.authenticatedUserId(r)
+	if err != nil {
+		respondWithError(rw, http.StatusUnauthorized, "Something went wrong validating JWT")
+		return
+	}
+	draftId, err := uuid.Parse(r.PathValue("draftID"))
+	if err != nil {
+		respondWithError(rw, http.StatusNotFound, "Draft not found")
+		return
+	}
+
+	tx, err := cfg.db.BeginTx(r.Context(), nil)
+	if err != nil {
+		respondWithError(rw, http.StatusInternalServerError, "Something went wrong publishing draft")
+		return
+	}
+	defer tx.Rollback()
+	q := cfg.queries.WithTx(tx)
+
+	draft, err := q.DeleteDraft(r.Context(), database.DeleteDraftParams{ID: draftId, UserID: uidtok})
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(rw, http.StatusNotFound, "Draft not found")
+		return
+	}
+	if err != nil {
+		respondWithError(rw, http.StatusInternalServerError, "Something went wrong publishing draft")
+		return
+	}
+
+	body, err := cleanChirpBody(draft.Body)
+	if err != nil {
+		respondWithError(rw, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	chirp, err := q.CreateChirp(r.Context(), database.CreateChirpParams{Body: body, UserID: uidtok, Visibility: defaultChirpVisibility})
+	if err != nil {
+		respondWithError(rw, http.StatusInternalServerError, "Something went wrong creating chirp")
+		return
+	}
+	chirp, err = indexChirpEntities(r.Context(), q, chirp)
+	if err != nil {
+		respondWithError(rw, http.StatusInternalServerError, "Something went wrong indexing chirp")
+		return
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		respondWithError(rw, http.StatusInternalServerError, "Something went wrong publishing draft")
+		return
+	}
+
+	ret, err := cfg.chirpsToJson(r.Context(), uidtok, []database.Chirp{chirp})
+	if err != nil {
+		respondWithError(rw, http.StatusInternalServerError, "Something went wrong loading chirp")
+		return
+	}
+	respondWithJSON(rw, http.StatusCreated, ret[0])
+}
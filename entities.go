@@ -0,0 +1,186 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Serux/chirpy/internal/database"
+	"github.com/Serux/chirpy/internal/entities"
+	"github.com/google/uuid"
+)
+
+const entitiesBackfillBatchSize = 100
+
+// Offsets are given in runes and in UTF-16 code units, start inclusive and
+// end exclusive, and cover the leading '@' or '#'.
+type urlEntityJson struct {
+	Url        string `json:"url"`
+	Start      int    `json:"start"`
+	End        int    `json:"end"`
+	StartUtf16 int    `json:"start_utf16"`
+	EndUtf16   int    `json:"end_utf16"`
+}
+
+type mentionEntityJson struct {
+	UserId     string `json:"user_id"`
+	Handle     string `json:"handle"`
+	Start      int    `json:"start"`
+	End        int    `json:"end"`
+	StartUtf16 int    `json:"start_utf16"`
+	EndUtf16   int    `json:"end_utf16"`
+}
+
+type hashtagEntityJson struct {
+	Tag        string `json:"tag"`
+	Text       string `json:"text"`
+	Start      int    `json:"start"`
+	End        int    `json:"end"`
+	StartUtf16 int    `json:"start_utf16"`
+	EndUtf16   int    `json:"end_utf16"`
+}
+
+type chirpEntitiesJson struct {
+	Urls     []urlEntityJson     `json:"urls"`
+	Mentions []mentionEntityJson `json:"mentions"`
+	Hashtags []hashtagEntityJson `json:"hashtags"`
+}
+
+// buildChirpEntities turns parsed entities into what is stored with the chirp.
+// users maps lowercase handles to the mentioned users; mentions of anybody
+// else stay plain text.
+func buildChirpEntities(parsed entities.Entities, users map[string]mentionEntityJson) chirpEntitiesJson {
+	ret := chirpEntitiesJson{Urls: []urlEntityJson{}, Mentions: []mentionEntityJson{}, Hashtags: []hashtagEntityJson{}}
+	for _, u := range parsed.URLs {
+		ret.Urls = append(ret.Urls, urlEntityJson{
+			Url:        u.URL,
+			Start:      u.Start,
+			End:        u.End,
+			StartUtf16: u.StartUTF16,
+			EndUtf16:   u.EndUTF16,
+		})
+	}
+	for _, m := range parsed.Mentions {
+		mj, ok := users[strings.ToLower(m.Handle)]
+		if !ok {
+			continue
+		}
+		mj.Start, mj.End = m.Start, m.End
+		mj.StartUtf16, mj.EndUtf16 = m.StartUTF16, m.EndUTF16
+		ret.Mentions = append(ret.Mentions, mj)
+	}
+	for _, h := range parsed.Hashtags {
+		ret.Hashtags = append(ret.Hashtags, hashtagEntityJson{
+			Tag:        h.Tag,
+			Text:       h.Text,
+			Start:      h.Start,
+			End:        h.End,
+			StartUtf16: h.StartUTF16,
+			EndUtf16:   h.EndUTF16,
+		})
+	}
+	return ret
+}
+
+// decodeChirpEntities reads the entities stored with a chirp. Chirps the
+// backfill hasn't reached yet have none.
+func decodeChirpEntities(data json.RawMessage) (chirpEntitiesJson, error) {
+	ret := chirpEntitiesJson{}
+	if len(data) > 0 {
+		err := json.Unmarshal(data, &ret)
+		if err != nil {
+			return chirpEntitiesJson{}, err
+		}
+	}
+	if ret.Urls == nil {
+		ret.Urls = []urlEntityJson{}
+	}
+	if ret.Mentions == nil {
+		ret.Mentions = []mentionEntityJson{}
+	}
+	if ret.Hashtags == nil {
+		ret.Hashtags = []hashtagEntityJson{}
+	}
+	return ret, nil
+}
+
+// indexChirpEntities tokenizes a chirp body once, indexes its hashtags and
+// mentions and stores the entities on the chirp, returning the updated row.
+// It runs on the given queries so callers can make it part of a transaction.
+func indexChirpEntities(ctx context.Context, q *database.Queries, chirp database.Chirp) (database.Chirp, error) {
+	parsed := entities.Parse(chirp.Body)
+	err := indexChirpHashtags(ctx, q, chirp, parsed.Tags())
+	if err != nil {
+		return database.Chirp{}, err
+	}
+	users, err := indexChirpMentions(ctx, q, chirp, parsed.Mentions)
+	if err != nil {
+		return database.Chirp{}, err
+	}
+	data, err := json.Marshal(buildChirpEntities(parsed, users))
+	if err != nil {
+		return database.Chirp{}, err
+	}
+	return q.UpdateChirpEntities(ctx, database.UpdateChirpEntitiesParams{Entities: data, ID: chirp.ID})
+}
+
+// backfillChirpEntities stores entities for chirps written before they were
+// persisted. Mentions are taken from the mentions already indexed, so nobody
+// gets notified again.
+func (cfg *apiConfig) backfillChirpEntities(ctx context.Context) error {
+	for {
+		chirps, err := cfg.queries.SelectChirpsMissingEntities(ctx, entitiesBackfillBatchSize)
+		if err != nil {
+			return err
+		}
+		ids := []uuid.UUID{}
+		for _, ch := range chirps {
+			ids = append(ids, ch.ID)
+		}
+		mentions, err := cfg.queries.SelectMentionsForChirps(ctx, ids)
+		if err != nil {
+			return err
+		}
+		users := map[uuid.UUID]map[string]mentionEntityJson{}
+		for _, m := range mentions {
+			if users[m.ChirpID] == nil {
+				users[m.ChirpID] = map[string]mentionEntityJson{}
+			}
+			users[m.ChirpID][strings.ToLower(m.Handle)] = mentionEntityJson{UserId: m.UserID.String(), Handle: m.Handle}
+		}
+
+		for _, ch := range chirps {
+			data, err := json.Marshal(buildChirpEntities(entities.Parse(ch.Body), users[ch.ID]))
+			if err != nil {
+				return err
+			}
+			_, err = cfg.queries.UpdateChirpEntities(ctx, database.UpdateChirpEntitiesParams{Entities: data, ID: ch.ID})
+			if err != nil {
+				return err
+			}
+		}
+		if len(chirps) < entitiesBackfillBatchSize {
+			return nil
+		}
+	}
+}
+
+// runEntitiesBackfill retries the backfill until it gets through once.
+func (cfg *apiConfig) runEntitiesBackfill(ctx context.Context, every time.Duration) {
+	ticker := time.NewTicker(every)
+	defer ticker.Stop()
+	for {
+		err := cfg.backfillChirpEntities(ctx)
+		if err == nil {
+			return
+		}
+		fmt.Println("ERROR BACKFILLING CHIRP ENTITIES", err)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
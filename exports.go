@@ -0,0 +1,512 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Serux/chirpy/internal/auth"
+	"github.com/Serux/chirpy/internal/blob"
+	"github.com/Serux/chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+// How long a finished archive can be downloaded, and how long each download
+// link handed out for it stays valid.
+const exportRetention = 7 * 24 * time.Hour
+const exportLinkTTL = time.Hour
+const exportCleanupBatchSize = 100
+
+type exportJson struct {
+	Id          string `json:"id"`
+	CreatedAt   string `json:"created_at"`
+	Status      string `json:"status"`
+	ReadyAt     string `json:"ready_at,omitempty"`
+	ExpiresAt   string `json:"expires_at,omitempty"`
+	SizeBytes   int64  `json:"size_bytes,omitempty"`
+	DownloadUrl string `json:"download_url,omitempty"`
+}
+
+// exportToJson describes an export, with a freshly signed download link once
+// it's ready.
+func (cfg *apiConfig) exportToJson(e database.DataExport) exportJson {
+	ret := exportJson{
+		Id:        e.ID.String(),
+		CreatedAt: e.CreatedAt.Format(time.RFC3339),
+		Status:    e.Status,
+	}
+	if e.Status == "ready" {
+		ret.ReadyAt = e.ReadyAt.Time.Format(time.RFC3339)
+		ret.ExpiresAt = e.ExpiresAt.Time.Format(time.RFC3339)
+		ret.SizeBytes = e.SizeBytes.Int64
+		expires := time.Now().Add(exportLinkTTL)
+		if e.ExpiresAt.Time.Before(expires) {
+			expires = e.ExpiresAt.Time
+		}
+		path := exportDownloadPath(e.ID)
+		ret.DownloadUrl = path + "?" + auth.SignLink(path, expires, cfg.jwtSecret)
+	}
+	return ret
+}
+
+func exportDownloadPath(id uuid.UUID) string {
+	return "/api/exports/" + id.String() + "/download"
+}
+
+// postExportHandler queues an archive of the user's data for the export
+// worker. While one is still pending, asking again returns that one.
+func (cfg *apiConfig) postExportHandler(rw http.ResponseWriter, r *http.Request) {
+	uidtok, err := cfg.authenticatedUserId(r)
+	if err != nil {
+		respondWithError(rw, http.StatusUnauthorized, "Something went wrong validating JWT")
+		return
+	}
+
+	export, err := cfg.queries.SelectPendingExportUser(r.Context(), uidtok)
+	if errors.Is(err, sql.ErrNoRows) {
+		export, err = cfg.queries.CreateExport(r.Context(), uidtok)
+	}
+	if err != nil {
+		respondWithError(rw, http.StatusInternalServerError, "Something went wrong creating export")
+		return
+	}
+	respondWithJSON(rw, http.StatusAccepted, cfg.exportToJson(export))
+}
+
+func (cfg *apiConfig) getExportsHandler(rw http.ResponseWriter, r *http.Request) {
+	uidtok, err := cfg.authenticatedUserId(r)
+	if err != nil {
+		respondWithError(rw, http.StatusUnauthorized, "Something went wrong validating JWT")
+		return
+	}
+
+	exports, err := cfg.queries.SelectExportsUser(r.Context(), uidtok)
+	if err != nil {
+		respondWithError(rw, http.StatusInternalServerError, "Something went wrong getting exports")
+		return
+	}
+	ret := []exportJson{}
+	for _, e := range exports {
+		ret = append(ret, cfg.exportToJson(e))
+	}
+	respondWithJSON(rw, http.StatusOK, ret)
+}
+
+// downloadExportHandler serves an archive to whoever has a valid signed link,
+// so it works from a plain browser download without a bearer token.
+func (cfg *apiConfig) downloadExportHandler(rw http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(r.PathValue("exportID"))
+	if err != nil {
+		respondWithError(rw, http.StatusNotFound, "Export not found")
+		return
+	}
+	err = auth.CheckLink(exportDownloadPath(id), r.URL.Query(), cfg.jwtSecret)
+	if err != nil {
+		respondWithError(rw, http.StatusForbidden, "Download link is invalid or expired")
+		return
+	}
+
+	export, err := cfg.queries.SelectOneExport(r.Context(), id)
+	if err != nil || export.Status != "ready" || time.Now().After(export.ExpiresAt.Time) {
+		respondWithError(rw, http.StatusNotFound, "Export not found")
+		return
+	}
+	content, err := cfg.blobs.Get(r.Context(), export.BlobKey.String)
+	if errors.Is(err, blob.ErrNotFound) {
+		respondWithError(rw, http.StatusNotFound, "Export not found")
+		return
+	}
+	if err != nil {
+		respondWithError(rw, http.StatusInternalServerError, "Something went wrong reading export")
+		return
+	}
+	defer content.Close()
+
+	filename := "chirpy-export-" + export.ReadyAt.Time.Format("2006-01-02") + ".zip"
+	rw.Header().Set("Content-Type", "application/zip")
+	rw.Header().Set("Content-Disposition", `attachment; filename="`+filename+`"`)
+	rw.Header().Set("Content-Length", strconv.FormatInt(export.SizeBytes.Int64, 10))
+	rw.Header().Set("Cache-Control", "private, no-store")
+	rw.WriteHeader(http.StatusOK)
+	io.Copy(rw, content)
+}
+
+type exportProfileJson struct {
+	userMailJsonDb
+	IsProtected      bool   `json:"is_protected"`
+	SensitiveContent string `json:"sensitive_content"`
+}
+
+type exportFollowsJson struct {
+	Followers []followJson `json:"followers"`
+	Following []followJson `json:"following"`
+}
+
+type exportListJson struct {
+	listJson
+	Members []listMemberJson `json:"members"`
+}
+
+type exportListsJson struct {
+	Owned      []exportListJson `json:"owned"`
+	Subscribed []listJson       `json:"subscribed"`
+}
+
+type exportUserRefJson struct {
+	UserId    string `json:"user_id"`
+	Handle    string `json:"handle"`
+	CreatedAt string `json:"created_at"`
+}
+
+type exportMediaJson struct {
+	mediaJson
+	// Where the upload is in the archive, empty if its file is gone.
+	File string `json:"file,omitempty"`
+}
+
+type exportSessionJson struct {
+	SessionId       string `json:"session_id"`
+	CreatedAt       string `json:"created_at"`
+	AuthenticatedAt string `json:"authenticated_at"`
+	ExpiresAt       string `json:"expires_at"`
+	RevokedAt       string `json:"revoked_at,omitempty"`
+}
+
+var exportChirpsTemplate = template.Must(template.New("chirps").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Your chirps</title>
+</head>
+<body>
+<h1>Your chirps</h1>
+{{range .}}<article id="{{.Id}}">
+<p>{{.Body}}</p>
+<footer><time datetime="{{.CreatedAt}}">{{.CreatedAt}}</time> · {{.Visibility}}
+{{- if .ReplyToId}} · reply to {{.ReplyToId}}{{end}}
+{{- if .PublishAt}} · scheduled for {{.PublishAt}}{{end}}
+{{- if .DeletedAt}} · in the trash since {{.DeletedAt}}{{end}}</footer>
+</article>
+{{else}}<p>No chirps.</p>
+{{end}}</body>
+</html>
+`))
+
+// buildExportArchive writes the ZIP of everything the user has here: the
+// profile, every chirp including scheduled ones and the trash (as JSON and as
+// a readable HTML page), drafts, lists, both sides of their follows, the
+// users they blocked or muted, their sessions and the files they uploaded.
+// The archive is streamed to w, so uploads never sit in memory all at once.
+func (cfg *apiConfig) writeExportArchive(ctx context.Context, q *database.Queries, userId uuid.UUID, w io.Writer) error {
+	user, err := q.SelectUserById(ctx, userId)
+	if err != nil {
+		return err
+	}
+	account, err := cfg.accountToJson(ctx, user)
+	if err != nil {
+		return err
+	}
+	profile := exportProfileJson{userMailJsonDb: account, IsProtected: user.IsProtected, SensitiveContent: user.SensitiveContent}
+
+	chirps, err := q.SelectChirpsForExport(ctx, userId)
+	if err != nil {
+		return err
+	}
+	chirpsJson, err := cfg.chirpsToJson(ctx, userId, chirps)
+	if err != nil {
+		return err
+	}
+	chirpsHtml := bytes.Buffer{}
+	err = exportChirpsTemplate.Execute(&chirpsHtml, chirpsJson)
+	if err != nil {
+		return err
+	}
+
+	followRows, err := q.SelectFollowsForExport(ctx, userId)
+	if err != nil {
+		return err
+	}
+	follows := exportFollowsJson{Followers: []followJson{}, Following: []followJson{}}
+	for _, f := range followRows {
+		if f.Follow.FollowerID == userId {
+			follows.Following = append(follows.Following, followJson{UserId: f.Follow.FolloweeID.String(), Handle: f.Handle.String, FollowedAt: f.Follow.CreatedAt.Format(time.RFC3339)})
+		} else {
+			follows.Followers = append(follows.Followers, followJson{UserId: f.Follow.FollowerID.String(), Handle: f.Handle.String, FollowedAt: f.Follow.CreatedAt.Format(time.RFC3339)})
+		}
+	}
+
+	drafts, err := q.SelectDraftsUser(ctx, userId)
+	if err != nil {
+		return err
+	}
+	draftsJson := []draftJson{}
+	for _, d := range drafts {
+		draftsJson = append(draftsJson, draftToJson(d))
+	}
+
+	listRows, err := q.SelectListsUser(ctx, userId)
+	if err != nil {
+		return err
+	}
+	memberRows, err := q.SelectListMembersForExport(ctx, userId)
+	if err != nil {
+		return err
+	}
+	members := map[uuid.UUID][]listMemberJson{}
+	for _, m := range memberRows {
+		members[m.ListMember.ListID] = append(members[m.ListMember.ListID], listMemberJson{
+			UserId:  m.ListMember.UserID.String(),
+			Handle:  m.Handle.String,
+			AddedAt: m.ListMember.CreatedAt.Format(time.RFC3339),
+		})
+	}
+	lists := exportListsJson{Owned: []exportListJson{}, Subscribed: []listJson{}}
+	for _, l := range listRows {
+		if l.OwnerID != userId {
+			lists.Subscribed = append(lists.Subscribed, listToJson(l))
+			continue
+		}
+		listMembers := members[l.ID]
+		if listMembers == nil {
+			listMembers = []listMemberJson{}
+		}
+		lists.Owned = append(lists.Owned, exportListJson{listJson: listToJson(l), Members: listMembers})
+	}
+
+	blockRows, err := q.SelectBlocksForExport(ctx, userId)
+	if err != nil {
+		return err
+	}
+	blocks := []exportUserRefJson{}
+	for _, b := range blockRows {
+		blocks = append(blocks, exportUserRefJson{UserId: b.Block.BlockedID.String(), Handle: b.Handle.String, CreatedAt: b.Block.CreatedAt.Format(time.RFC3339)})
+	}
+	muteRows, err := q.SelectMutesForExport(ctx, userId)
+	if err != nil {
+		return err
+	}
+	mutes := []exportUserRefJson{}
+	for _, m := range muteRows {
+		mutes = append(mutes, exportUserRefJson{UserId: m.Mute.MutedID.String(), Handle: m.Handle.String, CreatedAt: m.Mute.CreatedAt.Format(time.RFC3339)})
+	}
+
+	tokens, err := q.SelectSessionsUser(ctx, userId)
+	if err != nil {
+		return err
+	}
+	sessions := []exportSessionJson{}
+	for _, t := range tokens {
+		s := exportSessionJson{
+			SessionId:       t.SessionID.String(),
+			CreatedAt:       t.CreatedAt.Time.Format(time.RFC3339),
+			AuthenticatedAt: t.AuthenticatedAt.Format(time.RFC3339),
+			ExpiresAt:       t.ExpiresAt.Time.Format(time.RFC3339),
+		}
+		if t.RevokedAt.Valid {
+			s.RevokedAt = t.RevokedAt.Time.Format(time.RFC3339)
+		}
+		sessions = append(sessions, s)
+	}
+
+	uploads, err := q.SelectMediaForExport(ctx, userId)
+	if err != nil {
+		return err
+	}
+
+	zw := zip.NewWriter(w)
+	now := time.Now()
+
+	// The uploads go first, so media.json knows which of them still have a
+	// file. They're already compressed, so they're stored as they are.
+	mediaJsonList := []exportMediaJson{}
+	for _, m := range uploads {
+		entry := exportMediaJson{mediaJson: mediaToJson(m)}
+		content, err := cfg.blobs.Get(ctx, m.BlobKey)
+		if errors.Is(err, blob.ErrNotFound) {
+			mediaJsonList = append(mediaJsonList, entry)
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		entry.File = "media/" + m.ID.String() + "." + strings.TrimPrefix(m.ContentType, "image/")
+		fw, err := zw.CreateHeader(&zip.FileHeader{Name: entry.File, Method: zip.Store, Modified: now})
+		if err == nil {
+			_, err = io.Copy(fw, content)
+		}
+		content.Close()
+		if err != nil {
+			return err
+		}
+		mediaJsonList = append(mediaJsonList, entry)
+	}
+
+	files := []struct {
+		name    string
+		content any
+	}{
+		{"profile.json", profile},
+		{"chirps.json", chirpsJson},
+		{"chirps.html", chirpsHtml.Bytes()},
+		{"drafts.json", draftsJson},
+		{"lists.json", lists},
+		{"follows.json", follows},
+		{"blocks.json", blocks},
+		{"mutes.json", mutes},
+		{"sessions.json", sessions},
+		{"media.json", mediaJsonList},
+	}
+	for _, f := range files {
+		fw, err := zw.CreateHeader(&zip.FileHeader{Name: f.name, Method: zip.Deflate, Modified: now})
+		if err != nil {
+			return err
+		}
+		if raw, ok := f.content.([]byte); ok {
+			_, err = fw.Write(raw)
+		} else {
+			enc := json.NewEncoder(fw)
+			enc.SetIndent("", "  ")
+			err = enc.Encode(f.content)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return zw.Close()
+}
+
+// exportNext builds the oldest pending export, stores the archive and
+// notifies its user. It returns false when there was nothing to build. An
+// export that can't be built is marked failed rather than retried forever.
+func (cfg *apiConfig) exportNext(ctx context.Context) (bool, error) {
+	tx, err := cfg.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+	q := cfg.queries.WithTx(tx)
+
+	export, err := q.SelectNextPendingExport(ctx)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	// The archive is written into the blob store as it's built. Closing the
+	// read side lets the builder stop if the store gives up early, and the
+	// builder is waited for before the transaction is used again.
+	key := "exports/" + export.ID.String() + ".zip"
+	pr, pw := io.Pipe()
+	archive := &countingWriter{w: pw}
+	built := make(chan error, 1)
+	go func() {
+		err := cfg.writeExportArchive(ctx, q, export.UserID, archive)
+		pw.CloseWithError(err)
+		built <- err
+	}()
+	err = cfg.blobs.Put(ctx, key, pr)
+	pr.Close()
+	if berr := <-built; err == nil {
+		err = berr
+	}
+	if err != nil {
+		tx.Rollback()
+		ferr := cfg.queries.FailExport(ctx, database.FailExportParams{RetentionSeconds: exportRetention.Seconds(), ID: export.ID})
+		if ferr != nil {
+			fmt.Println("ERROR MARKING EXPORT FAILED", export.ID, ferr)
+		}
+		return true, err
+	}
+
+	_, err = q.CompleteExport(ctx, database.CompleteExportParams{
+		BlobKey:          sql.NullString{String: key, Valid: true},
+		SizeBytes:        sql.NullInt64{Int64: archive.n, Valid: true},
+		RetentionSeconds: exportRetention.Seconds(),
+		ID:               export.ID,
+	})
+	if err != nil {
+		return true, err
+	}
+	_, err = q.InsertNotification(ctx, database.InsertNotificationParams{
+		UserID:   export.UserID,
+		Kind:     "export_ready",
+		ExportID: uuid.NullUUID{UUID: export.ID, Valid: true},
+	})
+	if err != nil {
+		return true, err
+	}
+	// If this fails the archive stays behind unreachable, and the export is
+	// built again on the next try.
+	return true, tx.Commit()
+}
+
+// countingWriter counts the bytes written through it.
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// deleteExpiredExports removes one batch of expired exports and their
+// archives, returning how many it removed.
+func (cfg *apiConfig) deleteExpiredExports(ctx context.Context) (int, error) {
+	keys, err := cfg.queries.DeleteExpiredExports(ctx, exportCleanupBatchSize)
+	if err != nil {
+		return 0, err
+	}
+	for _, key := range keys {
+		if !key.Valid {
+			continue
+		}
+		err = cfg.blobs.Delete(ctx, key.String)
+		if err != nil {
+			fmt.Println("ERROR DELETING EXPIRED EXPORT", key.String, err)
+		}
+	}
+	return len(keys), nil
+}
+
+// runExportWorker builds pending exports every tick until ctx is cancelled,
+// one after another while there are any, then clears out expired ones.
+func (cfg *apiConfig) runExportWorker(ctx context.Context, every time.Duration) {
+	ticker := time.NewTicker(every)
+	defer ticker.Stop()
+	for {
+		built, err := cfg.exportNext(ctx)
+		if err != nil {
+			fmt.Println("ERROR BUILDING EXPORT", err)
+		}
+		if err == nil && built {
+			continue
+		}
+		n, err := cfg.deleteExpiredExports(ctx)
+		if err != nil {
+			fmt.Println("ERROR DELETING EXPIRED EXPORTS", err)
+		}
+		if err == nil && n == exportCleanupBatchSize {
+			continue
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/Serux/chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+// getFollowRequestsHandler lists the pending requests to follow the user,
+// newest first.
+func (cfg *apiConfig) getFollowRequestsHandler(rw http.ResponseWriter, r *http.Request) {
+	uidtok, err := cfg.authenticatedUserId(r)
+	if err != nil {
+		respondWithError(rw, http.StatusUnauthorized, "Something went wrong validating JWT")
+		return
+	}
+	limit, cursorCreatedAt, cursorId, err := parseFollowPage(r)
+	if err != nil {
+		respondWithError(rw, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	page, err := cfg.queries.SelectFollowRequestsPage(r.Context(), database.SelectFollowRequestsPageParams{
+		UserID:          uidtok,
+		CursorCreatedAt: cursorCreatedAt,
+		CursorID:        cursorId,
+		MaxResults:      int32(limit + 1),
+	})
+	if err != nil {
+		respondWithError(rw, http.StatusInternalServerError, "Something went wrong getting follow requests")
+		return
+	}
+	rows := []followRow{}
+	for _, f := range page {
+		rows = append(rows, followRow{user: f.User, followedAt: f.RequestedAt})
+	}
+	respondWithFollowPage(rw, r, rows, limit)
+}
+
+// approveFollow turns requester into a follower of target and backfills their
+// home timeline, on the given queries so it can join a transaction.
+func approveFollow(ctx context.Context, q *database.Queries, requester, target uuid.UUID) error {
+	_, err := q.InsertFollow(ctx, database.InsertFollowParams{FollowerID: requester, FolloweeID: target})
+	if err != nil {
+		return err
+	}
+	return q.BackfillTimeline(ctx, database.BackfillTimelineParams{UserID: requester, AuthorID: target, MaxEntries: timelineBackfillSize})
+}
+
+func (cfg *apiConfig) approveFollowRequestHandler(rw http.ResponseWriter, r *http.Request) {
+	uidtok, err := cfg.authenticatedUserId(r)
+	if err != nil {
+		respondWithError(rw, http.StatusUnauthorized, "Something went wrong validating JWT")
+		return
+	}
+	requester, err := uuid.Parse(r.PathValue("userID"))
+	if err != nil {
+		respondWithError(rw, http.StatusNotFound, "Follow request not found")
+		return
+	}
+
+	tx, err := cfg.db.BeginTx(r.Context(), nil)
+	if err != nil {
+		respondWithError(rw, http.StatusInternalServerError, "Something went wrong approving follow request")
+		return
+	}
+	defer tx.Rollback()
+	q := cfg.queries.WithTx(tx)
+
+	deleted, err := q.DeleteFollowRequest(r.Context(), database.DeleteFollowRequestParams{RequesterID: requester, TargetID: uidtok})
+	if err != nil {
+		respondWithError(rw, http.StatusInternalServerError, "Something went wrong approving follow request")
+		return
+	}
+	if deleted == 0 {
+		respondWithError(rw, http.StatusNotFound, "Follow request not found")
+		return
+	}
+	err = approveFollow(r.Context(), q, requester, uidtok)
+	if err != nil {
+		respondWithError(rw, http.StatusInternalServerError, "Something went wrong approving follow request")
+		return
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		respondWithError(rw, http.StatusInternalServerError, "Something went wrong approving follow request")
+		return
+	}
+	rw.WriteHeader(http.StatusNoContent)
+}
+
+func (cfg *apiConfig) rejectFollowRequestHandler(rw http.ResponseWriter, r *http.Request) {
+	uidtok, err := cfg.authenticatedUserId(r)
+	if err != nil {
+		respondWithError(rw, http.StatusUnauthorized, "Something went wrong validating JWT")
+		return
+	}
+	requester, err := uuid.Parse(r.PathValue("userID"))
+	if err != nil {
+		respondWithError(rw, http.StatusNotFound, "Follow request not found")
+		return
+	}
+
+	deleted, err := cfg.queries.DeleteFollowRequest(r.Context(), database.DeleteFollowRequestParams{RequesterID: requester, TargetID: uidtok})
+	if err != nil {
+		respondWithError(rw, http.StatusInternalServerError, "Something went wrong rejecting follow request")
+		return
+	}
+	if deleted == 0 {
+		respondWithError(rw, http.StatusNotFound, "Follow request not found")
+		return
+	}
+	rw.WriteHeader(http.StatusNoContent)
+}
+
+// setProtected turns a protected account on or off. Turning it off approves
+// every pending follow request.
+func setProtected(ctx context.Context, q *database.Queries, userId uuid.UUID, protected bool) (database.User, error) {
+	user, err := q.UpdateProtected(ctx, database.UpdateProtectedParams{IsProtected: protected, ID: userId})
+	if err != nil || protected {
+		return user, err
+	}
+	followers, err := q.ApproveAllFollowRequests(ctx, userId)
+	if err != nil {
+		return database.User{}, err
+	}
+	for _, follower := range followers {
+		err = q.BackfillTimeline(ctx, database.BackfillTimelineParams{UserID: follower, AuthorID: userId, MaxEntries: timelineBackfillSize})
+		if err != nil {
+			return database.User{}, err
+		}
+	}
+	return user, nil
+}
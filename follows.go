@@ -0,0 +1,305 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Serux/chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+type followJson struct {
+	UserId     string `json:"user_id"`
+	Handle     string `json:"handle"`
+	FollowedAt string `json:"followed_at"`
+}
+
+type followRequestStatusJson struct {
+	Status string `json:"status"`
+}
+
+type followRow struct {
+	user       database.User
+	followedAt time.Time
+}
+
+// followCursor points just after a row of a follower or following list,
+// which are ordered by (followed_at, user id) newest first.
+func followCursor(f followRow) string {
+	return encodeCursor(strconv.FormatInt(f.followedAt.UnixMicro(), 10), f.user.ID.String())
+}
+
+func parseFollowCursor(cursor string) (time.Time, uuid.UUID, error) {
+	parts, err := decodeCursor(cursor, 2)
+	if err != nil {
+		return time.Time{}, uuid.Nil, err
+	}
+	micros, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		return time.Time{}, uuid.Nil, fmt.Errorf("MALFORMED CURSOR")
+	}
+	id, err := uuid.Parse(parts[1])
+	if err != nil {
+		return time.Time{}, uuid.Nil, fmt.Errorf("MALFORMED CURSOR")
+	}
+	return time.UnixMicro(micros).UTC(), id, nil
+}
+
+// targetUser reads the {userID} of a follow, block or mute endpoint and checks
+// the user exists, writing the error response if not.
+func (cfg *apiConfig) targetUser(rw http.ResponseWriter, r *http.Request) (database.User, bool) {
+	userId, err := uuid.Parse(r.PathValue("userID"))
+	if err != nil {
+		respondWithError(rw, http.StatusNotFound, "User not found")
+		return database.User{}, false
+	}
+	user, err := cfg.queries.SelectUserById(r.Context(), userId)
+	if errors.Is(err, sql.ErrNoRows) || userId == ghostUserId {
+		respondWithError(rw, http.StatusNotFound, "User not found")
+		return database.User{}, false
+	}
+	if err != nil {
+		respondWithError(rw, http.StatusInternalServerError, "Something went wrong getting user")
+		return database.User{}, false
+	}
+	return user, true
+}
+
+// postFollowHandler follows a user and copies their latest chirps into the
+// follower's home timeline. Following a protected account only sends a follow
+// request and answers 202 until the owner approves it. Following somebody
+// already followed, or requesting twice, succeeds without doing anything, so
+// retries and double taps are harmless.
+func (cfg *apiConfig) postFollowHandler(rw http.ResponseWriter, r *http.Request) {
+	uidtok, err := cfg.authenticatedUserId(r)
+	if err != nil {
+		respondWithError(rw, http.StatusUnauthorized, "Something went wrong validating JWT")
+		return
+	}
+	target, ok := cfg.targetUser(rw, r)
+	if !ok {
+		return
+	}
+	followee := target.ID
+	if followee == uidtok {
+		respondWithError(rw, http.StatusBadRequest, "You can't follow yourself")
+		return
+	}
+	blocked, err := cfg.queries.IsBlockedBetween(r.Context(), database.IsBlockedBetweenParams{BlockerID: followee, BlockedID: uidtok})
+	if err != nil {
+		respondWithError(rw, http.StatusInternalServerError, "Something went wrong following user")
+		return
+	}
+	if blocked {
+		respondWithError(rw, http.StatusForbidden, "You can't follow this user")
+		return
+	}
+
+	// The target's row stays locked until commit, so switching the account
+	// between protected and public waits for this follow and the other way
+	// round. Otherwise a direct follow could land on a protected account, or
+	// a request be left pending on a public one.
+	tx, err := cfg.db.BeginTx(r.Context(), nil)
+	if err != nil {
+		respondWithError(rw, http.StatusInternalServerError, "Something went wrong following user")
+		return
+	}
+	defer tx.Rollback()
+	q := cfg.queries.WithTx(tx)
+
+	protected, err := q.LockUserProtected(r.Context(), followee)
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(rw, http.StatusNotFound, "User not found")
+		return
+	}
+	if err != nil {
+		respondWithError(rw, http.StatusInternalServerError, "Something went wrong following user")
+		return
+	}
+	if protected {
+		following, err := q.IsFollowing(r.Context(), database.IsFollowingParams{FollowerID: uidtok, FolloweeID: followee})
+		if err != nil {
+			respondWithError(rw, http.StatusInternalServerError, "Something went wrong following user")
+			return
+		}
+		if following {
+			rw.WriteHeader(http.StatusNoContent)
+			return
+		}
+		_, err = q.InsertFollowRequest(r.Context(), database.InsertFollowRequestParams{RequesterID: uidtok, TargetID: followee})
+		if err != nil {
+			respondWithError(rw, http.StatusInternalServerError, "Something went wrong requesting to follow user")
+			return
+		}
+		err = tx.Commit()
+		if err != nil {
+			respondWithError(rw, http.StatusInternalServerError, "Something went wrong requesting to follow user")
+			return
+		}
+		respondWithJSON(rw, http.StatusAccepted, followRequestStatusJson{Status: "requested"})
+		return
+	}
+
+	followed, err := q.InsertFollow(r.Context(), database.InsertFollowParams{FollowerID: uidtok, FolloweeID: followee})
+	if err != nil {
+		respondWithError(rw, http.StatusInternalServerError, "Something went wrong following user")
+		return
+	}
+	if followed > 0 {
+		err = q.BackfillTimeline(r.Context(), database.BackfillTimelineParams{UserID: uidtok, AuthorID: followee, MaxEntries: timelineBackfillSize})
+		if err != nil {
+			respondWithError(rw, http.StatusInternalServerError, "Something went wrong updating timeline")
+			return
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		respondWithError(rw, http.StatusInternalServerError, "Something went wrong following user")
+		return
+	}
+	rw.WriteHeader(http.StatusNoContent)
+}
+
+// deleteFollowHandler unfollows a user, or withdraws a pending follow request,
+// and takes their chirps out of the home timeline. Like following, it succeeds
+// when there is nothing to undo.
+func (cfg *apiConfig) deleteFollowHandler(rw http.ResponseWriter, r *http.Request) {
+	uidtok, err := cfg.authenticatedUserId(r)
+	if err != nil {
+		respondWithError(rw, http.StatusUnauthorized, "Something went wrong validating JWT")
+		return
+	}
+	target, ok := cfg.targetUser(rw, r)
+	if !ok {
+		return
+	}
+	followee := target.ID
+
+	tx, err := cfg.db.BeginTx(r.Context(), nil)
+	if err != nil {
+		respondWithError(rw, http.StatusInternalServerError, "Something went wrong unfollowing user")
+		return
+	}
+	defer tx.Rollback()
+	q := cfg.queries.WithTx(tx)
+
+	_, err = q.DeleteFollow(r.Context(), database.DeleteFollowParams{FollowerID: uidtok, FolloweeID: followee})
+	if err != nil {
+		respondWithError(rw, http.StatusInternalServerError, "Something went wrong unfollowing user")
+		return
+	}
+	_, err = q.DeleteFollowRequest(r.Context(), database.DeleteFollowRequestParams{RequesterID: uidtok, TargetID: followee})
+	if err != nil {
+		respondWithError(rw, http.StatusInternalServerError, "Something went wrong unfollowing user")
+		return
+	}
+	err = q.DeleteTimelineEntriesAuthor(r.Context(), database.DeleteTimelineEntriesAuthorParams{UserID: uidtok, AuthorID: followee})
+	if err != nil {
+		respondWithError(rw, http.StatusInternalServerError, "Something went wrong updating timeline")
+		return
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		respondWithError(rw, http.StatusInternalServerError, "Something went wrong unfollowing user")
+		return
+	}
+	rw.WriteHeader(http.StatusNoContent)
+}
+
+func (cfg *apiConfig) getFollowersHandler(rw http.ResponseWriter, r *http.Request) {
+	cfg.listFollows(rw, r, true)
+}
+
+func (cfg *apiConfig) getFollowingHandler(rw http.ResponseWriter, r *http.Request) {
+	cfg.listFollows(rw, r, false)
+}
+
+// listFollows serves a page of a user's followers or of the users they
+// follow, newest first, with the next page in the Link header.
+func (cfg *apiConfig) listFollows(rw http.ResponseWriter, r *http.Request, followers bool) {
+	user, ok := cfg.targetUser(rw, r)
+	if !ok {
+		return
+	}
+	limit, cursorCreatedAt, cursorId, err := parseFollowPage(r)
+	if err != nil {
+		respondWithError(rw, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	rows := []followRow{}
+	if followers {
+		page, err := cfg.queries.SelectFollowersPage(r.Context(), database.SelectFollowersPageParams{
+			UserID:          user.ID,
+			CursorCreatedAt: cursorCreatedAt,
+			CursorID:        cursorId,
+			MaxResults:      int32(limit + 1),
+		})
+		if err != nil {
+			respondWithError(rw, http.StatusInternalServerError, "Something went wrong getting followers")
+			return
+		}
+		for _, f := range page {
+			rows = append(rows, followRow{user: f.User, followedAt: f.FollowedAt})
+		}
+	} else {
+		page, err := cfg.queries.SelectFollowingPage(r.Context(), database.SelectFollowingPageParams{
+			UserID:          user.ID,
+			CursorCreatedAt: cursorCreatedAt,
+			CursorID:        cursorId,
+			MaxResults:      int32(limit + 1),
+		})
+		if err != nil {
+			respondWithError(rw, http.StatusInternalServerError, "Something went wrong getting following")
+			return
+		}
+		for _, f := range page {
+			rows = append(rows, followRow{user: f.User, followedAt: f.FollowedAt})
+		}
+	}
+	respondWithFollowPage(rw, r, rows, limit)
+}
+
+// parseFollowPage reads the limit and cursor of a follower, following or
+// follow request list.
+func parseFollowPage(r *http.Request) (int, sql.NullTime, uuid.UUID, error) {
+	limit, err := pageLimit(r)
+	if err != nil {
+		return 0, sql.NullTime{}, uuid.Nil, err
+	}
+	if !r.URL.Query().Has("cursor") {
+		return limit, sql.NullTime{}, uuid.Nil, nil
+	}
+	createdAt, id, err := parseFollowCursor(r.URL.Query().Get("cursor"))
+	if err != nil {
+		return 0, sql.NullTime{}, uuid.Nil, errors.New("Invalid cursor")
+	}
+	return limit, sql.NullTime{Time: createdAt, Valid: true}, id, nil
+}
+
+// respondWithFollowPage writes up to limit rows, fetched with one extra to
+// tell whether there is a next page.
+func respondWithFollowPage(rw http.ResponseWriter, r *http.Request, rows []followRow, limit int) {
+	hasMore := len(rows) > limit
+	if hasMore {
+		rows = rows[:limit]
+	}
+	ret := []followJson{}
+	for _, f := range rows {
+		ret = append(ret, followJson{
+			UserId:     f.user.ID.String(),
+			Handle:     f.user.Handle.String,
+			FollowedAt: f.followedAt.Format(time.RFC3339),
+		})
+	}
+	if hasMore {
+		setPageLinks(rw, r, followCursor(rows[len(rows)-1]), "")
+	}
+	respondWithJSON(rw, http.StatusOK, ret)
+}
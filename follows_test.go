@@ -0,0 +1,201 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Serux/chirpy/internal/auth"
+	"github.com/Serux/chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+// followFlow wraps the follow endpoints of one test config, answering with
+// the status code.
+type followFlow struct {
+	t   *testing.T
+	cfg *apiConfig
+}
+
+func (f followFlow) call(handler http.HandlerFunc, viewer, user uuid.UUID) int {
+	f.t.Helper()
+	return serve(f.t, handler, viewer, "/api/users/"+user.String(), map[string]string{"userID": user.String()}).Code
+}
+
+func (f followFlow) isFollowing(follower, followee uuid.UUID) bool {
+	f.t.Helper()
+	following, err := f.cfg.queries.IsFollowing(context.Background(), database.IsFollowingParams{FollowerID: follower, FolloweeID: followee})
+	if err != nil {
+		f.t.Fatal(err)
+	}
+	return following
+}
+
+// requesters lists the user ids with a pending request to follow owner.
+func (f followFlow) requesters(owner uuid.UUID) []string {
+	f.t.Helper()
+	rw := serve(f.t, f.cfg.getFollowRequestsHandler, owner, "/api/follow-requests", nil)
+	if rw.Code != http.StatusOK {
+		f.t.Fatalf("GET /api/follow-requests = %d: %s", rw.Code, rw.Body.String())
+	}
+	rows := []followJson{}
+	err := json.Unmarshal(rw.Body.Bytes(), &rows)
+	if err != nil {
+		f.t.Fatal(err)
+	}
+	ids := []string{}
+	for _, row := range rows {
+		ids = append(ids, row.UserId)
+	}
+	return ids
+}
+
+func TestFollowPublicAccount(t *testing.T) {
+	cfg := testConfig(t)
+	f := followFlow{t, cfg}
+	alice := createTestUser(t, cfg, "alice")
+	bob := createTestUser(t, cfg, "bob")
+
+	if code := f.call(cfg.postFollowHandler, alice, alice); code != http.StatusBadRequest {
+		t.Errorf("following yourself = %d, want 400", code)
+	}
+	for range 2 {
+		if code := f.call(cfg.postFollowHandler, alice, bob); code != http.StatusNoContent {
+			t.Errorf("follow = %d, want 204", code)
+		}
+	}
+	if !f.isFollowing(alice, bob) {
+		t.Error("alice doesn't follow bob")
+	}
+	rw := serve(t, cfg.getFollowersHandler, uuid.Nil, "/api/users/"+bob.String()+"/followers", map[string]string{"userID": bob.String()})
+	followers := []followJson{}
+	if err := json.Unmarshal(rw.Body.Bytes(), &followers); err != nil || len(followers) != 1 || followers[0].UserId != alice.String() {
+		t.Errorf("followers of bob = %s, want only alice", rw.Body.String())
+	}
+
+	for range 2 {
+		if code := f.call(cfg.deleteFollowHandler, alice, bob); code != http.StatusNoContent {
+			t.Errorf("unfollow = %d, want 204", code)
+		}
+	}
+	if f.isFollowing(alice, bob) {
+		t.Error("alice still follows bob")
+	}
+}
+
+// TestConcurrentFollows follows and unfollows from many requests at once,
+// which must neither fail nor leave more than one follow behind.
+func TestConcurrentFollows(t *testing.T) {
+	cfg := testConfig(t)
+	f := followFlow{t, cfg}
+	alice := createTestUser(t, cfg, "alice")
+	bob := createTestUser(t, cfg, "bob")
+	token, err := auth.MakeJWT(alice, testJwtSecret, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, handler := range []http.HandlerFunc{cfg.postFollowHandler, cfg.deleteFollowHandler, cfg.postFollowHandler} {
+		codes := make([]int, 10)
+		wg := sync.WaitGroup{}
+		for i := range codes {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				r := httptest.NewRequest(http.MethodPost, "/api/users/"+bob.String()+"/follow", nil)
+				r.SetPathValue("userID", bob.String())
+				r.Header.Set("Authorization", "Bearer "+token)
+				rw := httptest.NewRecorder()
+				handler(rw, r)
+				codes[i] = rw.Code
+			}()
+		}
+		wg.Wait()
+		for _, code := range codes {
+			if code != http.StatusNoContent {
+				t.Errorf("concurrent request = %d, want 204", code)
+			}
+		}
+	}
+	if !f.isFollowing(alice, bob) {
+		t.Error("alice doesn't follow bob")
+	}
+	counts, err := cfg.queries.CountFollows(context.Background(), bob)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if counts.Followers != 1 {
+		t.Errorf("bob has %d followers, want 1", counts.Followers)
+	}
+}
+
+func TestFollowProtectedAccount(t *testing.T) {
+	cfg := testConfig(t)
+	ctx := context.Background()
+	f := followFlow{t, cfg}
+	alice := createTestUser(t, cfg, "alice")
+	bob := createTestUser(t, cfg, "bob")
+	carol := createTestUser(t, cfg, "carol")
+	_, err := cfg.queries.UpdateProtected(ctx, database.UpdateProtectedParams{IsProtected: true, ID: carol})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Asking twice leaves a single request.
+	for range 2 {
+		if code := f.call(cfg.postFollowHandler, alice, carol); code != http.StatusAccepted {
+			t.Errorf("follow request = %d, want 202", code)
+		}
+	}
+	if f.isFollowing(alice, carol) {
+		t.Error("a request made alice a follower right away")
+	}
+	if got := f.requesters(carol); len(got) != 1 || got[0] != alice.String() {
+		t.Errorf("requests to carol = %v, want only alice", got)
+	}
+
+	if code := f.call(cfg.approveFollowRequestHandler, carol, alice); code != http.StatusNoContent {
+		t.Errorf("approve = %d, want 204", code)
+	}
+	if code := f.call(cfg.approveFollowRequestHandler, carol, alice); code != http.StatusNotFound {
+		t.Errorf("approving again = %d, want 404", code)
+	}
+	if !f.isFollowing(alice, carol) {
+		t.Error("approving didn't make alice a follower")
+	}
+	if code := f.call(cfg.postFollowHandler, alice, carol); code != http.StatusNoContent {
+		t.Errorf("following again once approved = %d, want 204", code)
+	}
+
+	f.call(cfg.postFollowHandler, bob, carol)
+	if code := f.call(cfg.rejectFollowRequestHandler, carol, bob); code != http.StatusNoContent {
+		t.Errorf("reject = %d, want 204", code)
+	}
+	if code := f.call(cfg.rejectFollowRequestHandler, carol, bob); code != http.StatusNotFound {
+		t.Errorf("rejecting again = %d, want 404", code)
+	}
+	if f.isFollowing(bob, carol) || len(f.requesters(carol)) != 0 {
+		t.Error("rejecting left bob following or requesting")
+	}
+
+	// Going public approves whatever is still pending.
+	f.call(cfg.postFollowHandler, bob, carol)
+	tx, err := cfg.db.BeginTx(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = setProtected(ctx, cfg.queries.WithTx(tx), carol, false)
+	if err == nil {
+		err = tx.Commit()
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !f.isFollowing(bob, carol) || len(f.requesters(carol)) != 0 {
+		t.Error("going public didn't approve bob's request")
+	}
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Serux/chirpy/internal/database"
+)
+
+type trendWindow struct {
+	name     string
+	window   time.Duration
+	halfLife time.Duration
+}
+
+// Trends are computed for every window, weighting each use by its age so
+// that a tag used a lot a while ago ranks below one picking up right now.
+var trendWindows = []trendWindow{
+	{name: "1h", window: time.Hour, halfLife: 15 * time.Minute},
+	{name: "24h", window: 24 * time.Hour, halfLife: 6 * time.Hour},
+	{name: "7d", window: 7 * 24 * time.Hour, halfLife: 36 * time.Hour},
+}
+
+const defaultTrendWindow = "24h"
+const maxTrendingTags = 20
+
+type trendingTagJson struct {
+	Tag   string  `json:"tag"`
+	Uses  int64   `json:"uses"`
+	Score float64 `json:"score"`
+}
+
+type trendsCache struct {
+	mu        sync.RWMutex
+	updatedAt time.Time
+	windows   map[string][]trendingTagJson
+}
+
+// normalizeHashtag turns a user supplied tag (with or without '#') into the
+// form stored in the hashtags table.
+func normalizeHashtag(tag string) string {
+	return strings.ToLower(strings.TrimPrefix(strings.TrimSpace(tag), "#"))
+}
+
+// indexChirpHashtags replaces the hashtags linked to a chirp with the given
+// tags, as returned by entities.Entities.Tags for its current body, so it is
+// safe to call again whenever the body changes. Like indexChirpMentions it
+// runs on the given queries, which may be a transaction.
+func indexChirpHashtags(ctx context.Context, q *database.Queries, chirp database.Chirp, tags []string) error {
+	err := q.DeleteChirpHashtags(ctx, chirp.ID)
+	if err != nil {
+		return err
+	}
+	for _, tag := range tags {
+		hashtag, err := q.UpsertHashtag(ctx, tag)
+		if err != nil {
+			return err
+		}
+		err = q.InsertChirpHashtag(ctx, database.InsertChirpHashtagParams{ChirpID: chirp.ID, HashtagID: hashtag.ID})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// refreshTrends recomputes every trend window and swaps the results into the
+// cache in one go.
+func (cfg *apiConfig) refreshTrends(ctx context.Context) error {
+	windows := map[string][]trendingTagJson{}
+	for _, tw := range trendWindows {
+		rows, err := cfg.queries.SelectTrendingHashtags(ctx, database.SelectTrendingHashtagsParams{
+			HalfLifeSeconds: tw.halfLife.Seconds(),
+			WindowSeconds:   tw.window.Seconds(),
+			MaxTags:         maxTrendingTags,
+		})
+		if err != nil {
+			return err
+		}
+		tags := []trendingTagJson{}
+		for _, row := range rows {
+			tags = append(tags, trendingTagJson{Tag: row.Tag, Uses: row.Uses, Score: row.Score})
+		}
+		windows[tw.name] = tags
+	}
+
+	cfg.trends.mu.Lock()
+	cfg.trends.windows = windows
+	cfg.trends.updatedAt = time.Now()
+	cfg.trends.mu.Unlock()
+	return nil
+}
+
+// runTrendsWorker keeps the trends cache fresh so GET /api/trends never has
+// to aggregate on the request path.
+func (cfg *apiConfig) runTrendsWorker(ctx context.Context, every time.Duration) {
+	ticker := time.NewTicker(every)
+	defer ticker.Stop()
+	for {
+		err := cfg.refreshTrends(ctx)
+		if err != nil {
+			fmt.Println("ERROR REFRESHING TRENDS", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (cfg *apiConfig) getTrendsHandler(rw http.ResponseWriter, r *http.Request) {
+	type responseJson struct {
+		Window    string            `json:"window"`
+		UpdatedAt string            `json:"updated_at"`
+		Tags      []trendingTagJson `json:"tags"`
+	}
+
+	window := defaultTrendWindow
+	if r.URL.Query().Has("window") {
+		window = r.URL.Query().Get("window")
+	}
+	if !slices.ContainsFunc(trendWindows, func(tw trendWindow) bool { return tw.name == window }) {
+		respondWithError(rw, http.StatusBadRequest, "Unknown trends window")
+		return
+	}
+
+	cfg.trends.mu.RLock()
+	tags := cfg.trends.windows[window]
+	updatedAt := cfg.trends.updatedAt
+	cfg.trends.mu.RUnlock()
+
+	if updatedAt.IsZero() {
+		respondWithError(rw, http.StatusServiceUnavailable, "Trends are not computed yet")
+		return
+	}
+
+	ret := responseJson{
+		Window:    window,
+		UpdatedAt: updatedAt.Format(time.RFC3339),
+		Tags:      tags,
+	}
+	respondWithJSON(rw, http.StatusOK, ret)
+}
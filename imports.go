@@ -0,0 +1,191 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"regexp"
+	"time"
+
+	"github.com/Serux/chirpy/internal/database"
+	"github.com/Serux/chirpy/internal/entities"
+	"github.com/Serux/chirpy/internal/importer"
+	"github.com/google/uuid"
+)
+
+// Bigger archives go in several requests, which is what cmd/chirpy-import
+// does.
+const maxImportRows = 1000
+const maxImportBytes = 10 << 20
+const defaultImportSource = "archive"
+
+var importSourceRegexp = regexp.MustCompile(`^[a-z0-9_-]{1,30}$`)
+
+type importRowErrorJson struct {
+	Row      int    `json:"row"`
+	SourceId string `json:"source_id,omitempty"`
+	Error    string `json:"error"`
+}
+
+type importResultJson struct {
+	Source   string               `json:"source"`
+	Total    int                  `json:"total"`
+	Imported int                  `json:"imported"`
+	Skipped  int                  `json:"skipped"`
+	Failed   int                  `json:"failed"`
+	Errors   []importRowErrorJson `json:"errors"`
+}
+
+// postImportHandler creates chirps from a JSON or CSV archive (see
+// importer.Parse), keeping their original timestamps. Every row goes through
+// the same validation as a new chirp and fails on its own, reported by row
+// number. Rows whose id was already imported from the same ?source= are
+// skipped, so sending an archive again, or resuming one that was cut off,
+// only adds what's missing.
+func (cfg *apiConfig) postImportHandler(rw http.ResponseWriter, r *http.Request) {
+	uidtok, err := cfg.authenticatedUserId(r)
+	if err != nil {
+		respondWithError(rw, http.StatusUnauthorized, "Something went wrong validating JWT")
+		return
+	}
+	source := r.URL.Query().Get("source")
+	if source == "" {
+		source = defaultImportSource
+	}
+	if !importSourceRegexp.MatchString(source) {
+		respondWithError(rw, http.StatusBadRequest, "source must be 1 to 30 lowercase letters, digits, dashes or underscores")
+		return
+	}
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = importer.DetectFormat("", r.Header.Get("Content-Type"))
+	}
+	if format != "json" && format != "csv" {
+		respondWithError(rw, http.StatusBadRequest, "format must be json or csv")
+		return
+	}
+
+	r.Body = http.MaxBytesReader(rw, r.Body, maxImportBytes)
+	rows, err := importer.Parse(r.Body, format)
+	var maxErr *http.MaxBytesError
+	if errors.As(err, &maxErr) {
+		respondWithError(rw, http.StatusRequestEntityTooLarge, "Archive is too large, send it in several parts")
+		return
+	}
+	if err != nil {
+		respondWithError(rw, http.StatusBadRequest, err.Error())
+		return
+	}
+	if len(rows) > maxImportRows {
+		respondWithError(rw, http.StatusRequestEntityTooLarge, "At most 1000 rows per request, send the archive in several parts")
+		return
+	}
+	importer.SortByCreatedAt(rows)
+
+	ids := []string{}
+	for _, row := range rows {
+		ids = append(ids, row.SourceID, row.ReplyToSourceID)
+	}
+	previous, err := cfg.queries.SelectChirpImports(r.Context(), database.SelectChirpImportsParams{UserID: uidtok, Source: source, SourceIds: ids})
+	if err != nil {
+		respondWithError(rw, http.StatusInternalServerError, "Something went wrong checking previous imports")
+		return
+	}
+	imported := map[string]uuid.NullUUID{}
+	for _, p := range previous {
+		imported[p.SourceID] = p.ChirpID
+	}
+
+	ret := importResultJson{Source: source, Total: len(rows), Errors: []importRowErrorJson{}}
+	fail := func(row importer.Row, msg string) {
+		ret.Failed++
+		ret.Errors = append(ret.Errors, importRowErrorJson{Row: row.Line, SourceId: row.SourceID, Error: msg})
+	}
+	now := time.Now().UTC()
+	for _, row := range rows {
+		if row.Err != nil {
+			fail(row, row.Err.Error())
+			continue
+		}
+		if _, ok := imported[row.SourceID]; ok {
+			ret.Skipped++
+			continue
+		}
+		if row.CreatedAt.After(now) {
+			fail(row, "created_at is in the future")
+			continue
+		}
+		body, err := cleanChirpBody(row.Body)
+		if err != nil {
+			fail(row, err.Error())
+			continue
+		}
+
+		// Replies stay replies when what they answer was imported too, in
+		// this archive or before. Replies to anybody else's posts can't be
+		// linked and come in as plain chirps.
+		chirp, ok, err := cfg.importChirp(r.Context(), database.CreateImportedChirpParams{
+			CreatedAt:  row.CreatedAt,
+			Body:       body,
+			UserID:     uidtok,
+			ReplyToID:  imported[row.ReplyToSourceID],
+			Visibility: defaultChirpVisibility,
+		}, source, row.SourceID)
+		if err != nil {
+			fail(row, "Something went wrong importing chirp")
+			continue
+		}
+		if !ok {
+			ret.Skipped++
+			continue
+		}
+		imported[row.SourceID] = uuid.NullUUID{UUID: chirp.ID, Valid: true}
+		ret.Imported++
+	}
+
+	respondWithJSON(rw, http.StatusOK, ret)
+}
+
+// importChirp creates one imported chirp and records where it came from in a
+// single transaction. It returns false without creating anything when the
+// row was imported in the meantime, such as by a concurrent request.
+func (cfg *apiConfig) importChirp(ctx context.Context, params database.CreateImportedChirpParams, source, sourceId string) (database.Chirp, bool, error) {
+	tx, err := cfg.db.BeginTx(ctx, nil)
+	if err != nil {
+		return database.Chirp{}, false, err
+	}
+	defer tx.Rollback()
+	q := cfg.queries.WithTx(tx)
+
+	chirp, err := q.CreateImportedChirp(ctx, params)
+	if err != nil {
+		return database.Chirp{}, false, err
+	}
+	n, err := q.InsertChirpImport(ctx, database.InsertChirpImportParams{
+		UserID:   params.UserID,
+		Source:   source,
+		SourceID: sourceId,
+		ChirpID:  uuid.NullUUID{UUID: chirp.ID, Valid: true},
+	})
+	if err != nil || n == 0 {
+		return database.Chirp{}, false, err
+	}
+
+	// The @handles of an archive belong to the platform it came from, so
+	// they aren't linked to anybody here and nobody gets notified.
+	parsed := entities.Parse(chirp.Body)
+	err = indexChirpHashtags(ctx, q, chirp, parsed.Tags())
+	if err != nil {
+		return database.Chirp{}, false, err
+	}
+	data, err := json.Marshal(buildChirpEntities(parsed, map[string]mentionEntityJson{}))
+	if err != nil {
+		return database.Chirp{}, false, err
+	}
+	chirp, err = q.UpdateChirpEntities(ctx, database.UpdateChirpEntitiesParams{Entities: data, ID: chirp.ID})
+	if err != nil {
+		return database.Chirp{}, false, err
+	}
+	return chirp, true, tx.Commit()
+}
@@ -1,10 +1,14 @@
 package auth
 
 import (
+	"crypto/hmac"
 	"crypto/rand"
+	"crypto/sha256"
 	"encoding/hex"
 	"fmt"
 	"net/http"
+	"net/url"
+	"strconv"
 	"strings"
 	"time"
 
@@ -24,39 +28,61 @@ func CheckPasswordHash(password, hash string) error {
 }
 
 func MakeJWT(userID uuid.UUID, tokenSecret string, expiresIn time.Duration) (string, error) {
-	token := jwt.NewWithClaims(
-		jwt.SigningMethodHS256,
-		jwt.RegisteredClaims{
-			Issuer:    "chirpy",
-			IssuedAt:  jwt.NewNumericDate(time.Now().UTC()),
-			ExpiresAt: jwt.NewNumericDate(time.Now().UTC().Add(expiresIn)),
-			Subject:   userID.String()})
+	return MakeSessionJWT(userID, uuid.Nil, tokenSecret, expiresIn)
+}
+
+// MakeSessionJWT makes a JWT tied to the login session it was issued for,
+// whose id is kept in the jti claim. uuid.Nil leaves the claim out.
+func MakeSessionJWT(userID, sessionID uuid.UUID, tokenSecret string, expiresIn time.Duration) (string, error) {
+	claims := jwt.RegisteredClaims{
+		Issuer:    "chirpy",
+		IssuedAt:  jwt.NewNumericDate(time.Now().UTC()),
+		ExpiresAt: jwt.NewNumericDate(time.Now().UTC().Add(expiresIn)),
+		Subject:   userID.String()}
+	if sessionID != uuid.Nil {
+		claims.ID = sessionID.String()
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
 	signtok, _ := token.SignedString([]byte(tokenSecret))
 
 	return signtok, nil
 }
 
 func ValidateJWT(tokenString, tokenSecret string) (uuid.UUID, error) {
+	uid, _, err := ValidateSessionJWT(tokenString, tokenSecret)
+	return uid, err
+}
+
+// ValidateSessionJWT is ValidateJWT that also returns the session id of the
+// token, or uuid.Nil when it wasn't made by MakeSessionJWT.
+func ValidateSessionJWT(tokenString, tokenSecret string) (uuid.UUID, uuid.UUID, error) {
 	claims := jwt.RegisteredClaims{}
 	jwtfunc := func(t *jwt.Token) (interface{}, error) { return []byte(tokenSecret), nil }
 	token, err := jwt.ParseWithClaims(tokenString, &claims, jwtfunc)
 	if err != nil {
-		return uuid.UUID{}, err
+		return uuid.UUID{}, uuid.UUID{}, err
 	}
 	subject, err := token.Claims.GetSubject()
 	if err != nil {
-		return uuid.UUID{}, err
+		return uuid.UUID{}, uuid.UUID{}, err
 	}
 	if t, err := token.Claims.GetExpirationTime(); time.Now().After(t.Time) {
-		return uuid.UUID{}, err
+		return uuid.UUID{}, uuid.UUID{}, err
 	}
 
 	uid, err := uuid.Parse(subject)
 	if err != nil {
-		return uuid.UUID{}, err
+		return uuid.UUID{}, uuid.UUID{}, err
+	}
+	sid := uuid.Nil
+	if claims.ID != "" {
+		sid, err = uuid.Parse(claims.ID)
+		if err != nil {
+			return uuid.UUID{}, uuid.UUID{}, err
+		}
 	}
 
-	return uid, nil
+	return uid, sid, nil
 }
 
 func GetBearerToken(headers http.Header) (string, error) {
@@ -95,3 +121,37 @@ func GetAPIKey(headers http.Header) (string, error) {
 	}
 	return authFields[1], nil
 }
+
+// SignLink signs a link to path that works without authentication until
+// expires, and returns the query string to append to it.
+func SignLink(path string, expires time.Time, secret string) string {
+	exp := strconv.FormatInt(expires.Unix(), 10)
+	q := url.Values{}
+	q.Set("expires", exp)
+	q.Set("signature", linkSignature(path, exp, secret))
+	return q.Encode()
+}
+
+// CheckLink checks the expires and signature query parameters of a link made
+// by SignLink.
+func CheckLink(path string, query url.Values, secret string) error {
+	exp := query.Get("expires")
+	want := linkSignature(path, exp, secret)
+	if !hmac.Equal([]byte(want), []byte(query.Get("signature"))) {
+		return fmt.Errorf("BAD SIGNATURE")
+	}
+	unix, err := strconv.ParseInt(exp, 10, 64)
+	if err != nil {
+		return fmt.Errorf("BAD EXPIRY")
+	}
+	if time.Now().After(time.Unix(unix, 0)) {
+		return fmt.Errorf("LINK EXPIRED")
+	}
+	return nil
+}
+
+func linkSignature(path, expires, secret string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(path + "\n" + expires))
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -2,6 +2,7 @@ package auth
 
 import (
 	"net/http"
+	"net/url"
 	"testing"
 	"time"
 
@@ -106,3 +107,58 @@ func TestMakeRefreshToken(t *testing.T) {
 	t.Log("RToken: ", tkn)
 
 }
+
+func TestValidateSessionJWT(t *testing.T) {
+	newuid, newsid := uuid.New(), uuid.New()
+	token, err := MakeSessionJWT(newuid, newsid, "Secret", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	uid, sid, err := ValidateSessionJWT(token, "Secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if uid != newuid || sid != newsid {
+		t.Errorf("got user %v session %v, want %v %v", uid, sid, newuid, newsid)
+	}
+
+	token, err = MakeJWT(newuid, "Secret", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	uid, sid, err = ValidateSessionJWT(token, "Secret")
+	if err != nil || uid != newuid || sid != uuid.Nil {
+		t.Errorf("got user %v session %v err %v, want %v and no session", uid, sid, err, newuid)
+	}
+}
+
+func TestCheckLink(t *testing.T) {
+	path := "/api/exports/123/download"
+	query, err := url.ParseQuery(SignLink(path, time.Now().Add(time.Hour), "Secret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := CheckLink(path, query, "Secret"); err != nil {
+		t.Errorf("valid link: %v", err)
+	}
+	if err := CheckLink("/api/exports/456/download", query, "Secret"); err == nil {
+		t.Error("link accepted for another path")
+	}
+	if err := CheckLink(path, query, "Secret2"); err == nil {
+		t.Error("link accepted with another secret")
+	}
+
+	tampered, _ := url.ParseQuery(query.Encode())
+	tampered.Set("expires", "99999999999")
+	if err := CheckLink(path, tampered, "Secret"); err == nil {
+		t.Error("link accepted with a changed expiry")
+	}
+
+	expired, err := url.ParseQuery(SignLink(path, time.Now().Add(-time.Minute), "Secret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := CheckLink(path, expired, "Secret"); err == nil {
+		t.Error("expired link accepted")
+	}
+}
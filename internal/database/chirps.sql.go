@@ -7,29 +7,51 @@ package database
 
 import (
 	"context"
+	"database/sql"
+	"encoding/json"
 
 	"github.com/google/uuid"
+	"github.com/lib/pq"
 )
 
 const createChirp = `-- name: CreateChirp :one
-INSERT INTO chirps (id, created_at, updated_at, body,user_id)
+INSERT INTO chirps (id, created_at, updated_at, body,user_id, reply_to_id, status, publish_at, visibility, content_warning, sensitive)
 VALUES (
     gen_random_uuid(),
     NOW(),
     NOW(),
     $1,
-    $2
+    $2,
+    $3,
+    CASE WHEN $4::timestamp IS NULL THEN 'published' ELSE 'scheduled' END,
+    $4,
+    $5,
+    $6,
+    $7
 )
-RETURNING id, created_at, updated_at, body, user_id
+RETURNING id, created_at, updated_at, body, user_id, search_vector, reply_to_id, status, publish_at, visibility, deleted_at, content_warning, sensitive, labels_forced, entities, fanout
 `
 
 type CreateChirpParams struct {
-	Body   string
-	UserID uuid.UUID
+	Body           string
+	UserID         uuid.UUID
+	ReplyToID      uuid.NullUUID
+	PublishAt      sql.NullTime
+	Visibility     string
+	ContentWarning sql.NullString
+	Sensitive      bool
 }
 
 func (q *Queries) CreateChirp(ctx context.Context, arg CreateChirpParams) (Chirp, error) {
-	row := q.db.QueryRowContext(ctx, createChirp, arg.Body, arg.UserID)
+	row := q.db.QueryRowContext(ctx, createChirp,
+		arg.Body,
+		arg.UserID,
+		arg.ReplyToID,
+		arg.PublishAt,
+		arg.Visibility,
+		arg.ContentWarning,
+		arg.Sensitive,
+	)
 	var i Chirp
 	err := row.Scan(
 		&i.ID,
@@ -37,6 +59,17 @@ func (q *Queries) CreateChirp(ctx context.Context, arg CreateChirpParams) (Chirp
 		&i.UpdatedAt,
 		&i.Body,
 		&i.UserID,
+		&i.SearchVector,
+		&i.ReplyToID,
+		&i.Status,
+		&i.PublishAt,
+		&i.Visibility,
+		&i.DeletedAt,
+		&i.ContentWarning,
+		&i.Sensitive,
+		&i.LabelsForced,
+		&i.Entities,
+		&i.Fanout,
 	)
 	return i, err
 }
@@ -50,24 +83,195 @@ func (q *Queries) DeleteAllChirps(ctx context.Context) error {
 	return err
 }
 
-const deleteByIdChirps = `-- name: DeleteByIdChirps :exec
+const deleteChirpsByIds = `-- name: DeleteChirpsByIds :exec
+DELETE FROM chirps
+WHERE chirps.id = ANY($1::uuid[])
+`
+
+func (q *Queries) DeleteChirpsByIds(ctx context.Context, ids []uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, deleteChirpsByIds, pq.Array(ids))
+	return err
+}
+
+const deleteScheduledChirp = `-- name: DeleteScheduledChirp :execrows
 DELETE FROM chirps
 WHERE chirps.id = $1
 AND chirps.user_id = $2
+AND chirps.status = 'scheduled'
+AND chirps.deleted_at IS NULL
 `
 
-type DeleteByIdChirpsParams struct {
+type DeleteScheduledChirpParams struct {
 	ID     uuid.UUID
 	UserID uuid.UUID
 }
 
-func (q *Queries) DeleteByIdChirps(ctx context.Context, arg DeleteByIdChirpsParams) error {
-	_, err := q.db.ExecContext(ctx, deleteByIdChirps, arg.ID, arg.UserID)
-	return err
+func (q *Queries) DeleteScheduledChirp(ctx context.Context, arg DeleteScheduledChirpParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, deleteScheduledChirp, arg.ID, arg.UserID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const forceChirpLabels = `-- name: ForceChirpLabels :one
+UPDATE chirps
+SET content_warning = $1, sensitive = $2, labels_forced = TRUE, updated_at = NOW()
+WHERE chirps.id = $3
+AND chirps.deleted_at IS NULL
+RETURNING id, created_at, updated_at, body, user_id, search_vector, reply_to_id, status, publish_at, visibility, deleted_at, content_warning, sensitive, labels_forced, entities, fanout
+`
+
+type ForceChirpLabelsParams struct {
+	ContentWarning sql.NullString
+	Sensitive      bool
+	ID             uuid.UUID
+}
+
+func (q *Queries) ForceChirpLabels(ctx context.Context, arg ForceChirpLabelsParams) (Chirp, error) {
+	row := q.db.QueryRowContext(ctx, forceChirpLabels, arg.ContentWarning, arg.Sensitive, arg.ID)
+	var i Chirp
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Body,
+		&i.UserID,
+		&i.SearchVector,
+		&i.ReplyToID,
+		&i.Status,
+		&i.PublishAt,
+		&i.Visibility,
+		&i.DeletedAt,
+		&i.ContentWarning,
+		&i.Sensitive,
+		&i.LabelsForced,
+		&i.Entities,
+		&i.Fanout,
+	)
+	return i, err
+}
+
+const publishChirp = `-- name: PublishChirp :one
+UPDATE chirps
+SET status = 'published', created_at = NOW(), updated_at = NOW()
+WHERE chirps.id = $1
+RETURNING id, created_at, updated_at, body, user_id, search_vector, reply_to_id, status, publish_at, visibility, deleted_at, content_warning, sensitive, labels_forced, entities, fanout
+`
+
+func (q *Queries) PublishChirp(ctx context.Context, id uuid.UUID) (Chirp, error) {
+	row := q.db.QueryRowContext(ctx, publishChirp, id)
+	var i Chirp
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Body,
+		&i.UserID,
+		&i.SearchVector,
+		&i.ReplyToID,
+		&i.Status,
+		&i.PublishAt,
+		&i.Visibility,
+		&i.DeletedAt,
+		&i.ContentWarning,
+		&i.Sensitive,
+		&i.LabelsForced,
+		&i.Entities,
+		&i.Fanout,
+	)
+	return i, err
+}
+
+const rescheduleChirp = `-- name: RescheduleChirp :one
+UPDATE chirps
+SET publish_at = $1, updated_at = NOW()
+WHERE chirps.id = $2
+AND chirps.user_id = $3
+AND chirps.status = 'scheduled'
+AND chirps.deleted_at IS NULL
+RETURNING id, created_at, updated_at, body, user_id, search_vector, reply_to_id, status, publish_at, visibility, deleted_at, content_warning, sensitive, labels_forced, entities, fanout
+`
+
+type RescheduleChirpParams struct {
+	PublishAt sql.NullTime
+	ID        uuid.UUID
+	UserID    uuid.UUID
+}
+
+func (q *Queries) RescheduleChirp(ctx context.Context, arg RescheduleChirpParams) (Chirp, error) {
+	row := q.db.QueryRowContext(ctx, rescheduleChirp, arg.PublishAt, arg.ID, arg.UserID)
+	var i Chirp
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Body,
+		&i.UserID,
+		&i.SearchVector,
+		&i.ReplyToID,
+		&i.Status,
+		&i.PublishAt,
+		&i.Visibility,
+		&i.DeletedAt,
+		&i.ContentWarning,
+		&i.Sensitive,
+		&i.LabelsForced,
+		&i.Entities,
+		&i.Fanout,
+	)
+	return i, err
+}
+
+const restoreChirp = `-- name: RestoreChirp :one
+UPDATE chirps
+SET deleted_at = NULL
+WHERE chirps.id = $1
+AND chirps.user_id = $2
+AND chirps.deleted_at > NOW() - make_interval(secs => $3::float8)
+RETURNING id, created_at, updated_at, body, user_id, search_vector, reply_to_id, status, publish_at, visibility, deleted_at, content_warning, sensitive, labels_forced, entities, fanout
+`
+
+type RestoreChirpParams struct {
+	ID               uuid.UUID
+	UserID           uuid.UUID
+	RetentionSeconds float64
+}
+
+func (q *Queries) RestoreChirp(ctx context.Context, arg RestoreChirpParams) (Chirp, error) {
+	row := q.db.QueryRowContext(ctx, restoreChirp, arg.ID, arg.UserID, arg.RetentionSeconds)
+	var i Chirp
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Body,
+		&i.UserID,
+		&i.SearchVector,
+		&i.ReplyToID,
+		&i.Status,
+		&i.PublishAt,
+		&i.Visibility,
+		&i.DeletedAt,
+		&i.ContentWarning,
+		&i.Sensitive,
+		&i.LabelsForced,
+		&i.Entities,
+		&i.Fanout,
+	)
+	return i, err
 }
 
 const selectAllChirps = `-- name: SelectAllChirps :many
-SELECT id, created_at, updated_at, body, user_id FROM chirps 
+SELECT id, created_at, updated_at, body, user_id, search_vector, reply_to_id, status, publish_at, visibility, deleted_at, content_warning, sensitive, labels_forced, entities, fanout FROM chirps 
+WHERE chirps.status = 'published'
+AND chirps.deleted_at IS NULL
+AND chirps.visibility = 'public'
+AND NOT EXISTS (
+    SELECT 1 FROM users
+    WHERE users.id = chirps.user_id
+    AND users.is_protected
+)
 ORDER BY chirps.created_at
 `
 
@@ -86,6 +290,17 @@ func (q *Queries) SelectAllChirps(ctx context.Context) ([]Chirp, error) {
 			&i.UpdatedAt,
 			&i.Body,
 			&i.UserID,
+			&i.SearchVector,
+			&i.ReplyToID,
+			&i.Status,
+			&i.PublishAt,
+			&i.Visibility,
+			&i.DeletedAt,
+			&i.ContentWarning,
+			&i.Sensitive,
+			&i.LabelsForced,
+			&i.Entities,
+			&i.Fanout,
 		); err != nil {
 			return nil, err
 		}
@@ -101,8 +316,16 @@ func (q *Queries) SelectAllChirps(ctx context.Context) ([]Chirp, error) {
 }
 
 const selectAllChirpsUser = `-- name: SelectAllChirpsUser :many
-SELECT id, created_at, updated_at, body, user_id FROM chirps 
+SELECT id, created_at, updated_at, body, user_id, search_vector, reply_to_id, status, publish_at, visibility, deleted_at, content_warning, sensitive, labels_forced, entities, fanout FROM chirps 
 WHERE user_id = $1 
+AND chirps.status = 'published'
+AND chirps.deleted_at IS NULL
+AND chirps.visibility = 'public'
+AND NOT EXISTS (
+    SELECT 1 FROM users
+    WHERE users.id = chirps.user_id
+    AND users.is_protected
+)
 ORDER BY chirps.created_at ASC
 `
 
@@ -121,6 +344,365 @@ func (q *Queries) SelectAllChirpsUser(ctx context.Context, userID uuid.UUID) ([]
 			&i.UpdatedAt,
 			&i.Body,
 			&i.UserID,
+			&i.SearchVector,
+			&i.ReplyToID,
+			&i.Status,
+			&i.PublishAt,
+			&i.Visibility,
+			&i.DeletedAt,
+			&i.ContentWarning,
+			&i.Sensitive,
+			&i.LabelsForced,
+			&i.Entities,
+			&i.Fanout,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const selectChirpsMissingEntities = `-- name: SelectChirpsMissingEntities :many
+SELECT id, created_at, updated_at, body, user_id, search_vector, reply_to_id, status, publish_at, visibility, deleted_at, content_warning, sensitive, labels_forced, entities, fanout FROM chirps
+WHERE chirps.status = 'published'
+AND chirps.entities = '{}'::jsonb
+ORDER BY chirps.id
+LIMIT $1
+`
+
+// Chirps written before entities were stored, for the backfill.
+func (q *Queries) SelectChirpsMissingEntities(ctx context.Context, limit int32) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, selectChirpsMissingEntities, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Chirp
+	for rows.Next() {
+		var i Chirp
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Body,
+			&i.UserID,
+			&i.SearchVector,
+			&i.ReplyToID,
+			&i.Status,
+			&i.PublishAt,
+			&i.Visibility,
+			&i.DeletedAt,
+			&i.ContentWarning,
+			&i.Sensitive,
+			&i.LabelsForced,
+			&i.Entities,
+			&i.Fanout,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const selectChirpsPageAsc = `-- name: SelectChirpsPageAsc :many
+SELECT id, created_at, updated_at, body, user_id, search_vector, reply_to_id, status, publish_at, visibility, deleted_at, content_warning, sensitive, labels_forced, entities, fanout FROM chirps
+WHERE chirps.status = 'published'
+AND chirps.deleted_at IS NULL
+AND chirp_is_visible(chirps.visibility, chirps.id, chirps.user_id, $1)
+AND (NOT $2::bool OR chirps.user_id = $1 OR (NOT chirps.sensitive AND chirps.content_warning IS NULL))
+AND (COALESCE(cardinality($3::uuid[]), 0) > 0 OR NOT chirp_is_muted(chirps.user_id, $1))
+AND (COALESCE(cardinality($3::uuid[]), 0) = 0 OR chirps.user_id = ANY($3::uuid[]))
+AND ($4::text IS NULL OR EXISTS (
+    SELECT 1 FROM chirp_hashtags
+    JOIN hashtags ON hashtags.id = chirp_hashtags.hashtag_id
+    WHERE chirp_hashtags.chirp_id = chirps.id
+    AND hashtags.tag = $4
+))
+AND ($5::timestamp IS NULL OR chirps.created_at >= $5)
+AND ($6::timestamp IS NULL OR chirps.created_at < $6)
+AND ($7::text IS NULL OR chirps.body ILIKE '%' || $7 || '%' ESCAPE '\')
+AND (NOT $8::boolean OR chirps.reply_to_id IS NULL)
+AND ($9::boolean IS NULL OR $9 = EXISTS (
+    SELECT 1 FROM chirp_media
+    WHERE chirp_media.chirp_id = chirps.id
+))
+AND ($10::timestamp IS NULL
+    OR (chirps.created_at, chirps.id) > ($10, $11::uuid))
+ORDER BY chirps.created_at ASC, chirps.id ASC
+LIMIT $12
+`
+
+type SelectChirpsPageAscParams struct {
+	ViewerID        uuid.UUID
+	HideSensitive   bool
+	AuthorIds       []uuid.UUID
+	Tag             sql.NullString
+	Since           sql.NullTime
+	Until           sql.NullTime
+	Contains        sql.NullString
+	ExcludeReplies  bool
+	HasMedia        sql.NullBool
+	CursorCreatedAt sql.NullTime
+	CursorID        uuid.UUID
+	MaxResults      int32
+}
+
+// Muted users only show up when asked for by author_id.
+func (q *Queries) SelectChirpsPageAsc(ctx context.Context, arg SelectChirpsPageAscParams) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, selectChirpsPageAsc,
+		arg.ViewerID,
+		arg.HideSensitive,
+		pq.Array(arg.AuthorIds),
+		arg.Tag,
+		arg.Since,
+		arg.Until,
+		arg.Contains,
+		arg.ExcludeReplies,
+		arg.HasMedia,
+		arg.CursorCreatedAt,
+		arg.CursorID,
+		arg.MaxResults,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Chirp
+	for rows.Next() {
+		var i Chirp
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Body,
+			&i.UserID,
+			&i.SearchVector,
+			&i.ReplyToID,
+			&i.Status,
+			&i.PublishAt,
+			&i.Visibility,
+			&i.DeletedAt,
+			&i.ContentWarning,
+			&i.Sensitive,
+			&i.LabelsForced,
+			&i.Entities,
+			&i.Fanout,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const selectChirpsPageDesc = `-- name: SelectChirpsPageDesc :many
+SELECT id, created_at, updated_at, body, user_id, search_vector, reply_to_id, status, publish_at, visibility, deleted_at, content_warning, sensitive, labels_forced, entities, fanout FROM chirps
+WHERE chirps.status = 'published'
+AND chirps.deleted_at IS NULL
+AND chirp_is_visible(chirps.visibility, chirps.id, chirps.user_id, $1)
+AND (NOT $2::bool OR chirps.user_id = $1 OR (NOT chirps.sensitive AND chirps.content_warning IS NULL))
+AND (COALESCE(cardinality($3::uuid[]), 0) > 0 OR NOT chirp_is_muted(chirps.user_id, $1))
+AND (COALESCE(cardinality($3::uuid[]), 0) = 0 OR chirps.user_id = ANY($3::uuid[]))
+AND ($4::text IS NULL OR EXISTS (
+    SELECT 1 FROM chirp_hashtags
+    JOIN hashtags ON hashtags.id = chirp_hashtags.hashtag_id
+    WHERE chirp_hashtags.chirp_id = chirps.id
+    AND hashtags.tag = $4
+))
+AND ($5::timestamp IS NULL OR chirps.created_at >= $5)
+AND ($6::timestamp IS NULL OR chirps.created_at < $6)
+AND ($7::text IS NULL OR chirps.body ILIKE '%' || $7 || '%' ESCAPE '\')
+AND (NOT $8::boolean OR chirps.reply_to_id IS NULL)
+AND ($9::boolean IS NULL OR $9 = EXISTS (
+    SELECT 1 FROM chirp_media
+    WHERE chirp_media.chirp_id = chirps.id
+))
+AND ($10::timestamp IS NULL
+    OR (chirps.created_at, chirps.id) < ($10, $11::uuid))
+ORDER BY chirps.created_at DESC, chirps.id DESC
+LIMIT $12
+`
+
+type SelectChirpsPageDescParams struct {
+	ViewerID        uuid.UUID
+	HideSensitive   bool
+	AuthorIds       []uuid.UUID
+	Tag             sql.NullString
+	Since           sql.NullTime
+	Until           sql.NullTime
+	Contains        sql.NullString
+	ExcludeReplies  bool
+	HasMedia        sql.NullBool
+	CursorCreatedAt sql.NullTime
+	CursorID        uuid.UUID
+	MaxResults      int32
+}
+
+// Muted users only show up when asked for by author_id.
+func (q *Queries) SelectChirpsPageDesc(ctx context.Context, arg SelectChirpsPageDescParams) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, selectChirpsPageDesc,
+		arg.ViewerID,
+		arg.HideSensitive,
+		pq.Array(arg.AuthorIds),
+		arg.Tag,
+		arg.Since,
+		arg.Until,
+		arg.Contains,
+		arg.ExcludeReplies,
+		arg.HasMedia,
+		arg.CursorCreatedAt,
+		arg.CursorID,
+		arg.MaxResults,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Chirp
+	for rows.Next() {
+		var i Chirp
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Body,
+			&i.UserID,
+			&i.SearchVector,
+			&i.ReplyToID,
+			&i.Status,
+			&i.PublishAt,
+			&i.Visibility,
+			&i.DeletedAt,
+			&i.ContentWarning,
+			&i.Sensitive,
+			&i.LabelsForced,
+			&i.Entities,
+			&i.Fanout,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const selectDueScheduledChirps = `-- name: SelectDueScheduledChirps :many
+SELECT id, created_at, updated_at, body, user_id, search_vector, reply_to_id, status, publish_at, visibility, deleted_at, content_warning, sensitive, labels_forced, entities, fanout FROM chirps
+WHERE chirps.status = 'scheduled'
+AND chirps.deleted_at IS NULL
+AND chirps.publish_at <= NOW()
+ORDER BY chirps.publish_at ASC
+LIMIT $1
+FOR UPDATE SKIP LOCKED
+`
+
+// Rows locked by another instance are skipped rather than waited on, so
+// several schedulers can share the work.
+func (q *Queries) SelectDueScheduledChirps(ctx context.Context, limit int32) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, selectDueScheduledChirps, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Chirp
+	for rows.Next() {
+		var i Chirp
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Body,
+			&i.UserID,
+			&i.SearchVector,
+			&i.ReplyToID,
+			&i.Status,
+			&i.PublishAt,
+			&i.Visibility,
+			&i.DeletedAt,
+			&i.ContentWarning,
+			&i.Sensitive,
+			&i.LabelsForced,
+			&i.Entities,
+			&i.Fanout,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const selectExpiredDeletedChirps = `-- name: SelectExpiredDeletedChirps :many
+SELECT id, created_at, updated_at, body, user_id, search_vector, reply_to_id, status, publish_at, visibility, deleted_at, content_warning, sensitive, labels_forced, entities, fanout FROM chirps
+WHERE chirps.deleted_at <= NOW() - make_interval(secs => $1::float8)
+ORDER BY chirps.deleted_at ASC
+LIMIT $2
+FOR UPDATE SKIP LOCKED
+`
+
+type SelectExpiredDeletedChirpsParams struct {
+	RetentionSeconds float64
+	MaxResults       int32
+}
+
+func (q *Queries) SelectExpiredDeletedChirps(ctx context.Context, arg SelectExpiredDeletedChirpsParams) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, selectExpiredDeletedChirps, arg.RetentionSeconds, arg.MaxResults)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Chirp
+	for rows.Next() {
+		var i Chirp
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Body,
+			&i.UserID,
+			&i.SearchVector,
+			&i.ReplyToID,
+			&i.Status,
+			&i.PublishAt,
+			&i.Visibility,
+			&i.DeletedAt,
+			&i.ContentWarning,
+			&i.Sensitive,
+			&i.LabelsForced,
+			&i.Entities,
+			&i.Fanout,
 		); err != nil {
 			return nil, err
 		}
@@ -136,12 +718,295 @@ func (q *Queries) SelectAllChirpsUser(ctx context.Context, userID uuid.UUID) ([]
 }
 
 const selectOneChirps = `-- name: SelectOneChirps :one
-SELECT id, created_at, updated_at, body, user_id FROM chirps 
+SELECT id, created_at, updated_at, body, user_id, search_vector, reply_to_id, status, publish_at, visibility, deleted_at, content_warning, sensitive, labels_forced, entities, fanout FROM chirps 
+WHERE chirps.id = $1
+AND chirps.status = 'published'
+AND chirps.deleted_at IS NULL
+AND chirp_is_visible(chirps.visibility, chirps.id, chirps.user_id, $2::uuid)
+`
+
+type SelectOneChirpsParams struct {
+	ID       uuid.UUID
+	ViewerID uuid.UUID
+}
+
+func (q *Queries) SelectOneChirps(ctx context.Context, arg SelectOneChirpsParams) (Chirp, error) {
+	row := q.db.QueryRowContext(ctx, selectOneChirps, arg.ID, arg.ViewerID)
+	var i Chirp
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Body,
+		&i.UserID,
+		&i.SearchVector,
+		&i.ReplyToID,
+		&i.Status,
+		&i.PublishAt,
+		&i.Visibility,
+		&i.DeletedAt,
+		&i.ContentWarning,
+		&i.Sensitive,
+		&i.LabelsForced,
+		&i.Entities,
+		&i.Fanout,
+	)
+	return i, err
+}
+
+const selectRepliesPage = `-- name: SelectRepliesPage :many
+SELECT id, created_at, updated_at, body, user_id, search_vector, reply_to_id, status, publish_at, visibility, deleted_at, content_warning, sensitive, labels_forced, entities, fanout FROM chirps
+WHERE chirps.reply_to_id = $1::uuid
+AND chirps.status = 'published'
+AND chirps.deleted_at IS NULL
+AND chirp_is_visible(chirps.visibility, chirps.id, chirps.user_id, $2::uuid)
+AND NOT chirp_is_muted(chirps.user_id, $2::uuid)
+AND (NOT $3::bool OR chirps.user_id = $2::uuid OR (NOT chirps.sensitive AND chirps.content_warning IS NULL))
+AND ($4::timestamp IS NULL
+    OR (chirps.created_at, chirps.id) > ($4, $5::uuid))
+ORDER BY chirps.created_at ASC, chirps.id ASC
+LIMIT $6
+`
+
+type SelectRepliesPageParams struct {
+	ChirpID         uuid.UUID
+	ViewerID        uuid.UUID
+	HideSensitive   bool
+	CursorCreatedAt sql.NullTime
+	CursorID        uuid.UUID
+	MaxResults      int32
+}
+
+// The replies to a chirp the viewer can see, oldest first. Replies from
+// muted users are left out.
+func (q *Queries) SelectRepliesPage(ctx context.Context, arg SelectRepliesPageParams) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, selectRepliesPage,
+		arg.ChirpID,
+		arg.ViewerID,
+		arg.HideSensitive,
+		arg.CursorCreatedAt,
+		arg.CursorID,
+		arg.MaxResults,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Chirp
+	for rows.Next() {
+		var i Chirp
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Body,
+			&i.UserID,
+			&i.SearchVector,
+			&i.ReplyToID,
+			&i.Status,
+			&i.PublishAt,
+			&i.Visibility,
+			&i.DeletedAt,
+			&i.ContentWarning,
+			&i.Sensitive,
+			&i.LabelsForced,
+			&i.Entities,
+			&i.Fanout,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const selectScheduledChirpsUser = `-- name: SelectScheduledChirpsUser :many
+SELECT id, created_at, updated_at, body, user_id, search_vector, reply_to_id, status, publish_at, visibility, deleted_at, content_warning, sensitive, labels_forced, entities, fanout FROM chirps
+WHERE chirps.user_id = $1
+AND chirps.status = 'scheduled'
+AND chirps.deleted_at IS NULL
+ORDER BY chirps.publish_at ASC, chirps.id ASC
+`
+
+func (q *Queries) SelectScheduledChirpsUser(ctx context.Context, userID uuid.UUID) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, selectScheduledChirpsUser, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Chirp
+	for rows.Next() {
+		var i Chirp
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Body,
+			&i.UserID,
+			&i.SearchVector,
+			&i.ReplyToID,
+			&i.Status,
+			&i.PublishAt,
+			&i.Visibility,
+			&i.DeletedAt,
+			&i.ContentWarning,
+			&i.Sensitive,
+			&i.LabelsForced,
+			&i.Entities,
+			&i.Fanout,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const selectTrashChirpsUser = `-- name: SelectTrashChirpsUser :many
+SELECT id, created_at, updated_at, body, user_id, search_vector, reply_to_id, status, publish_at, visibility, deleted_at, content_warning, sensitive, labels_forced, entities, fanout FROM chirps
+WHERE chirps.user_id = $1
+AND chirps.deleted_at > NOW() - make_interval(secs => $2::float8)
+ORDER BY chirps.deleted_at DESC, chirps.id DESC
+`
+
+type SelectTrashChirpsUserParams struct {
+	UserID           uuid.UUID
+	RetentionSeconds float64
+}
+
+func (q *Queries) SelectTrashChirpsUser(ctx context.Context, arg SelectTrashChirpsUserParams) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, selectTrashChirpsUser, arg.UserID, arg.RetentionSeconds)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Chirp
+	for rows.Next() {
+		var i Chirp
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Body,
+			&i.UserID,
+			&i.SearchVector,
+			&i.ReplyToID,
+			&i.Status,
+			&i.PublishAt,
+			&i.Visibility,
+			&i.DeletedAt,
+			&i.ContentWarning,
+			&i.Sensitive,
+			&i.LabelsForced,
+			&i.Entities,
+			&i.Fanout,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const softDeleteChirp = `-- name: SoftDeleteChirp :execrows
+UPDATE chirps
+SET deleted_at = NOW()
 WHERE chirps.id = $1
+AND chirps.user_id = $2
+AND chirps.deleted_at IS NULL
 `
 
-func (q *Queries) SelectOneChirps(ctx context.Context, id uuid.UUID) (Chirp, error) {
-	row := q.db.QueryRowContext(ctx, selectOneChirps, id)
+type SoftDeleteChirpParams struct {
+	ID     uuid.UUID
+	UserID uuid.UUID
+}
+
+func (q *Queries) SoftDeleteChirp(ctx context.Context, arg SoftDeleteChirpParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, softDeleteChirp, arg.ID, arg.UserID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const updateChirpEntities = `-- name: UpdateChirpEntities :one
+UPDATE chirps
+SET entities = $1
+WHERE chirps.id = $2
+RETURNING id, created_at, updated_at, body, user_id, search_vector, reply_to_id, status, publish_at, visibility, deleted_at, content_warning, sensitive, labels_forced, entities, fanout
+`
+
+type UpdateChirpEntitiesParams struct {
+	Entities json.RawMessage
+	ID       uuid.UUID
+}
+
+func (q *Queries) UpdateChirpEntities(ctx context.Context, arg UpdateChirpEntitiesParams) (Chirp, error) {
+	row := q.db.QueryRowContext(ctx, updateChirpEntities, arg.Entities, arg.ID)
+	var i Chirp
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Body,
+		&i.UserID,
+		&i.SearchVector,
+		&i.ReplyToID,
+		&i.Status,
+		&i.PublishAt,
+		&i.Visibility,
+		&i.DeletedAt,
+		&i.ContentWarning,
+		&i.Sensitive,
+		&i.LabelsForced,
+		&i.Entities,
+		&i.Fanout,
+	)
+	return i, err
+}
+
+const updateChirpLabels = `-- name: UpdateChirpLabels :one
+UPDATE chirps
+SET content_warning = $1, sensitive = $2, updated_at = NOW()
+WHERE chirps.id = $3
+AND chirps.user_id = $4
+AND chirps.labels_forced = FALSE
+AND chirps.deleted_at IS NULL
+RETURNING id, created_at, updated_at, body, user_id, search_vector, reply_to_id, status, publish_at, visibility, deleted_at, content_warning, sensitive, labels_forced, entities, fanout
+`
+
+type UpdateChirpLabelsParams struct {
+	ContentWarning sql.NullString
+	Sensitive      bool
+	ID             uuid.UUID
+	UserID         uuid.UUID
+}
+
+func (q *Queries) UpdateChirpLabels(ctx context.Context, arg UpdateChirpLabelsParams) (Chirp, error) {
+	row := q.db.QueryRowContext(ctx, updateChirpLabels,
+		arg.ContentWarning,
+		arg.Sensitive,
+		arg.ID,
+		arg.UserID,
+	)
 	var i Chirp
 	err := row.Scan(
 		&i.ID,
@@ -149,6 +1014,17 @@ func (q *Queries) SelectOneChirps(ctx context.Context, id uuid.UUID) (Chirp, err
 		&i.UpdatedAt,
 		&i.Body,
 		&i.UserID,
+		&i.SearchVector,
+		&i.ReplyToID,
+		&i.Status,
+		&i.PublishAt,
+		&i.Visibility,
+		&i.DeletedAt,
+		&i.ContentWarning,
+		&i.Sensitive,
+		&i.LabelsForced,
+		&i.Entities,
+		&i.Fanout,
 	)
 	return i, err
 }
@@ -6,33 +6,217 @@ package database
 
 import (
 	"database/sql"
+	"encoding/json"
 	"time"
 
 	"github.com/google/uuid"
 )
 
+type Block struct {
+	BlockerID uuid.UUID
+	BlockedID uuid.UUID
+	CreatedAt time.Time
+}
+
 type Chirp struct {
+	ID             uuid.UUID
+	CreatedAt      time.Time
+	UpdatedAt      time.Time
+	Body           string
+	UserID         uuid.UUID
+	SearchVector   interface{}
+	ReplyToID      uuid.NullUUID
+	Status         string
+	PublishAt      sql.NullTime
+	Visibility     string
+	DeletedAt      sql.NullTime
+	ContentWarning sql.NullString
+	Sensitive      bool
+	LabelsForced   bool
+	Entities       json.RawMessage
+	Fanout         string
+}
+
+type ChirpDailyStat struct {
+	ChirpID     uuid.UUID
+	Day         time.Time
+	Views       int64
+	Impressions int64
+}
+
+type ChirpHashtag struct {
+	ChirpID   uuid.UUID
+	HashtagID uuid.UUID
+}
+
+type ChirpImport struct {
+	UserID     uuid.UUID
+	Source     string
+	SourceID   string
+	ChirpID    uuid.NullUUID
+	ImportedAt time.Time
+}
+
+type ChirpMedium struct {
+	ChirpID  uuid.UUID
+	MediaID  uuid.UUID
+	Position int32
+}
+
+type ChirpMention struct {
+	ChirpID     uuid.UUID
+	UserID      uuid.UUID
+	Handle      string
+	StartOffset int32
+	EndOffset   int32
+}
+
+type DataExport struct {
+	ID        uuid.UUID
+	CreatedAt time.Time
+	UserID    uuid.UUID
+	Status    string
+	BlobKey   sql.NullString
+	SizeBytes sql.NullInt64
+	ReadyAt   sql.NullTime
+	ExpiresAt sql.NullTime
+}
+
+type Draft struct {
 	ID        uuid.UUID
 	CreatedAt time.Time
 	UpdatedAt time.Time
+	UserID    uuid.UUID
 	Body      string
+}
+
+type Follow struct {
+	FollowerID uuid.UUID
+	FolloweeID uuid.UUID
+	CreatedAt  time.Time
+}
+
+type FollowRequest struct {
+	RequesterID uuid.UUID
+	TargetID    uuid.UUID
+	CreatedAt   time.Time
+}
+
+type Hashtag struct {
+	ID        uuid.UUID
+	CreatedAt time.Time
+	Tag       string
+}
+
+type List struct {
+	ID        uuid.UUID
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	OwnerID   uuid.UUID
+	Name      string
+	IsPrivate bool
+}
+
+type ListMember struct {
+	ListID    uuid.UUID
+	UserID    uuid.UUID
+	CreatedAt time.Time
+}
+
+type ListSubscription struct {
+	ListID    uuid.UUID
 	UserID    uuid.UUID
+	CreatedAt time.Time
+}
+
+type Medium struct {
+	ID                   uuid.UUID
+	CreatedAt            time.Time
+	UserID               uuid.UUID
+	ContentType          string
+	SizeBytes            int64
+	Width                int32
+	Height               int32
+	BlobKey              string
+	ThumbnailContentType string
+	ThumbnailKey         string
+}
+
+type Mute struct {
+	MuterID   uuid.UUID
+	MutedID   uuid.UUID
+	CreatedAt time.Time
+}
+
+type Notification struct {
+	ID        uuid.UUID
+	CreatedAt time.Time
+	UserID    uuid.UUID
+	Kind      string
+	ActorID   uuid.NullUUID
+	ChirpID   uuid.NullUUID
+	ReadAt    sql.NullTime
+	ExportID  uuid.NullUUID
+}
+
+type Pin struct {
+	UserID    uuid.UUID
+	ChirpID   uuid.UUID
+	CreatedAt time.Time
+	Position  int32
+}
+
+type Poll struct {
+	ChirpID   uuid.UUID
+	CreatedAt time.Time
+	ClosesAt  time.Time
+}
+
+type PollOption struct {
+	ID       uuid.UUID
+	ChirpID  uuid.UUID
+	Position int32
+	Text     string
+}
+
+type PollVote struct {
+	ChirpID   uuid.UUID
+	UserID    uuid.UUID
+	OptionID  uuid.UUID
+	CreatedAt time.Time
 }
 
 type RefreshToken struct {
-	Token     string
-	CreatedAt sql.NullTime
-	UpdatedAt sql.NullTime
+	Token           string
+	CreatedAt       sql.NullTime
+	UpdatedAt       sql.NullTime
+	UserID          uuid.UUID
+	ExpiresAt       sql.NullTime
+	RevokedAt       sql.NullTime
+	SessionID       uuid.UUID
+	AuthenticatedAt time.Time
+}
+
+type TimelineEntry struct {
 	UserID    uuid.UUID
-	ExpiresAt sql.NullTime
-	RevokedAt sql.NullTime
+	ChirpID   uuid.UUID
+	AuthorID  uuid.UUID
+	CreatedAt time.Time
 }
 
 type User struct {
-	ID             uuid.UUID
-	CreatedAt      time.Time
-	UpdatedAt      time.Time
-	Email          string
-	HashedPassword string
-	IsChirpyRed    bool
+	ID                  uuid.UUID
+	CreatedAt           time.Time
+	UpdatedAt           time.Time
+	Email               string
+	HashedPassword      string
+	IsChirpyRed         bool
+	Handle              sql.NullString
+	IsModerator         bool
+	SensitiveContent    string
+	IsProtected         bool
+	DisplayName         string
+	Bio                 string
+	AvatarMediaID       uuid.NullUUID
+	DeletionScheduledAt sql.NullTime
 }
@@ -20,7 +20,7 @@ VALUES (
     $2,
     NOW() + INTERVAL '60 DAYS'
 )
-RETURNING token, created_at, updated_at, user_id, expires_at, revoked_at
+RETURNING token, created_at, updated_at, user_id, expires_at, revoked_at, session_id, authenticated_at
 `
 
 type InsertRefreshTokenParams struct {
@@ -38,10 +38,30 @@ func (q *Queries) InsertRefreshToken(ctx context.Context, arg InsertRefreshToken
 		&i.UserID,
 		&i.ExpiresAt,
 		&i.RevokedAt,
+		&i.SessionID,
+		&i.AuthenticatedAt,
 	)
 	return i, err
 }
 
+const revokeOtherRefreshTokens = `-- name: RevokeOtherRefreshTokens :exec
+UPDATE refresh_tokens
+SET revoked_at = NOW(), updated_at = NOW()
+WHERE user_id = $1
+AND session_id <> $2
+AND revoked_at IS NULL
+`
+
+type RevokeOtherRefreshTokensParams struct {
+	UserID    uuid.UUID
+	SessionID uuid.UUID
+}
+
+func (q *Queries) RevokeOtherRefreshTokens(ctx context.Context, arg RevokeOtherRefreshTokensParams) error {
+	_, err := q.db.ExecContext(ctx, revokeOtherRefreshTokens, arg.UserID, arg.SessionID)
+	return err
+}
+
 const revokeRefreshToken = `-- name: RevokeRefreshToken :exec
 
 UPDATE refresh_tokens
@@ -55,7 +75,7 @@ func (q *Queries) RevokeRefreshToken(ctx context.Context, token string) error {
 }
 
 const selectRefreshToken = `-- name: SelectRefreshToken :one
-SELECT token, created_at, updated_at, user_id, expires_at, revoked_at FROM refresh_tokens
+SELECT token, created_at, updated_at, user_id, expires_at, revoked_at, session_id, authenticated_at FROM refresh_tokens
 WHERE token = $1
 `
 
@@ -69,6 +89,35 @@ func (q *Queries) SelectRefreshToken(ctx context.Context, token string) (Refresh
 		&i.UserID,
 		&i.ExpiresAt,
 		&i.RevokedAt,
+		&i.SessionID,
+		&i.AuthenticatedAt,
+	)
+	return i, err
+}
+
+const selectSession = `-- name: SelectSession :one
+SELECT token, created_at, updated_at, user_id, expires_at, revoked_at, session_id, authenticated_at FROM refresh_tokens
+WHERE session_id = $1
+AND user_id = $2
+`
+
+type SelectSessionParams struct {
+	SessionID uuid.UUID
+	UserID    uuid.UUID
+}
+
+func (q *Queries) SelectSession(ctx context.Context, arg SelectSessionParams) (RefreshToken, error) {
+	row := q.db.QueryRowContext(ctx, selectSession, arg.SessionID, arg.UserID)
+	var i RefreshToken
+	err := row.Scan(
+		&i.Token,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.UserID,
+		&i.ExpiresAt,
+		&i.RevokedAt,
+		&i.SessionID,
+		&i.AuthenticatedAt,
 	)
 	return i, err
 }
@@ -7,29 +7,33 @@ package database
 
 import (
 	"context"
+	"database/sql"
 
 	"github.com/google/uuid"
+	"github.com/lib/pq"
 )
 
 const createUser = `-- name: CreateUser :one
-INSERT INTO users (id, created_at, updated_at, email, hashed_password)
+INSERT INTO users (id, created_at, updated_at, email, hashed_password, handle)
 VALUES (
     gen_random_uuid(),
     NOW(),
     NOW(),
     $1,
-    $2
+    $2,
+    $3
 )
-RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red
+RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red, handle, is_moderator, sensitive_content, is_protected, display_name, bio, avatar_media_id, deletion_scheduled_at
 `
 
 type CreateUserParams struct {
 	Email          string
 	HashedPassword string
+	Handle         sql.NullString
 }
 
 func (q *Queries) CreateUser(ctx context.Context, arg CreateUserParams) (User, error) {
-	row := q.db.QueryRowContext(ctx, createUser, arg.Email, arg.HashedPassword)
+	row := q.db.QueryRowContext(ctx, createUser, arg.Email, arg.HashedPassword, arg.Handle)
 	var i User
 	err := row.Scan(
 		&i.ID,
@@ -38,6 +42,14 @@ func (q *Queries) CreateUser(ctx context.Context, arg CreateUserParams) (User, e
 		&i.Email,
 		&i.HashedPassword,
 		&i.IsChirpyRed,
+		&i.Handle,
+		&i.IsModerator,
+		&i.SensitiveContent,
+		&i.IsProtected,
+		&i.DisplayName,
+		&i.Bio,
+		&i.AvatarMediaID,
+		&i.DeletionScheduledAt,
 	)
 	return i, err
 }
@@ -52,8 +64,92 @@ func (q *Queries) DeleteAllUsers(ctx context.Context) error {
 	return err
 }
 
+const lockUser = `-- name: LockUser :exec
+SELECT users.id FROM users
+WHERE users.id = $1
+FOR UPDATE
+`
+
+// Holds the user row until the transaction ends, for checks like a cap on
+// pins that count rows before inserting.
+func (q *Queries) LockUser(ctx context.Context, id uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, lockUser, id)
+	return err
+}
+
+const lockUserProtected = `-- name: LockUserProtected :one
+SELECT users.is_protected FROM users
+WHERE users.id = $1
+FOR UPDATE
+`
+
+// Like LockUser, returning whether the account is protected as of the lock,
+// so a follow can't race a change of the setting.
+func (q *Queries) LockUserProtected(ctx context.Context, id uuid.UUID) (bool, error) {
+	row := q.db.QueryRowContext(ctx, lockUserProtected, id)
+	var is_protected bool
+	err := row.Scan(&is_protected)
+	return is_protected, err
+}
+
+const selectUserByHandle = `-- name: SelectUserByHandle :one
+SELECT id, created_at, updated_at, email, hashed_password, is_chirpy_red, handle, is_moderator, sensitive_content, is_protected, display_name, bio, avatar_media_id, deletion_scheduled_at
+FROM users
+WHERE LOWER(users.handle) = LOWER($1)
+`
+
+func (q *Queries) SelectUserByHandle(ctx context.Context, handle string) (User, error) {
+	row := q.db.QueryRowContext(ctx, selectUserByHandle, handle)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Email,
+		&i.HashedPassword,
+		&i.IsChirpyRed,
+		&i.Handle,
+		&i.IsModerator,
+		&i.SensitiveContent,
+		&i.IsProtected,
+		&i.DisplayName,
+		&i.Bio,
+		&i.AvatarMediaID,
+		&i.DeletionScheduledAt,
+	)
+	return i, err
+}
+
+const selectUserById = `-- name: SelectUserById :one
+SELECT id, created_at, updated_at, email, hashed_password, is_chirpy_red, handle, is_moderator, sensitive_content, is_protected, display_name, bio, avatar_media_id, deletion_scheduled_at
+FROM users
+WHERE users.id = $1
+`
+
+func (q *Queries) SelectUserById(ctx context.Context, id uuid.UUID) (User, error) {
+	row := q.db.QueryRowContext(ctx, selectUserById, id)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Email,
+		&i.HashedPassword,
+		&i.IsChirpyRed,
+		&i.Handle,
+		&i.IsModerator,
+		&i.SensitiveContent,
+		&i.IsProtected,
+		&i.DisplayName,
+		&i.Bio,
+		&i.AvatarMediaID,
+		&i.DeletionScheduledAt,
+	)
+	return i, err
+}
+
 const selectUserByMail = `-- name: SelectUserByMail :one
-SELECT id, created_at, updated_at, email, hashed_password, is_chirpy_red
+SELECT id, created_at, updated_at, email, hashed_password, is_chirpy_red, handle, is_moderator, sensitive_content, is_protected, display_name, bio, avatar_media_id, deletion_scheduled_at
 FROM users
 WHERE users.email = $1
 `
@@ -68,6 +164,126 @@ func (q *Queries) SelectUserByMail(ctx context.Context, email string) (User, err
 		&i.Email,
 		&i.HashedPassword,
 		&i.IsChirpyRed,
+		&i.Handle,
+		&i.IsModerator,
+		&i.SensitiveContent,
+		&i.IsProtected,
+		&i.DisplayName,
+		&i.Bio,
+		&i.AvatarMediaID,
+		&i.DeletionScheduledAt,
+	)
+	return i, err
+}
+
+const selectUsersByHandles = `-- name: SelectUsersByHandles :many
+SELECT id, created_at, updated_at, email, hashed_password, is_chirpy_red, handle, is_moderator, sensitive_content, is_protected, display_name, bio, avatar_media_id, deletion_scheduled_at
+FROM users
+WHERE LOWER(users.handle) = ANY($1::text[])
+`
+
+func (q *Queries) SelectUsersByHandles(ctx context.Context, handles []string) ([]User, error) {
+	rows, err := q.db.QueryContext(ctx, selectUsersByHandles, pq.Array(handles))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []User
+	for rows.Next() {
+		var i User
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Email,
+			&i.HashedPassword,
+			&i.IsChirpyRed,
+			&i.Handle,
+			&i.IsModerator,
+			&i.SensitiveContent,
+			&i.IsProtected,
+			&i.DisplayName,
+			&i.Bio,
+			&i.AvatarMediaID,
+			&i.DeletionScheduledAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const updateProtected = `-- name: UpdateProtected :one
+UPDATE users
+SET is_protected = $1, updated_at = NOW()
+WHERE id = $2
+RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red, handle, is_moderator, sensitive_content, is_protected, display_name, bio, avatar_media_id, deletion_scheduled_at
+`
+
+type UpdateProtectedParams struct {
+	IsProtected bool
+	ID          uuid.UUID
+}
+
+func (q *Queries) UpdateProtected(ctx context.Context, arg UpdateProtectedParams) (User, error) {
+	row := q.db.QueryRowContext(ctx, updateProtected, arg.IsProtected, arg.ID)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Email,
+		&i.HashedPassword,
+		&i.IsChirpyRed,
+		&i.Handle,
+		&i.IsModerator,
+		&i.SensitiveContent,
+		&i.IsProtected,
+		&i.DisplayName,
+		&i.Bio,
+		&i.AvatarMediaID,
+		&i.DeletionScheduledAt,
+	)
+	return i, err
+}
+
+const updateSensitiveContentPreference = `-- name: UpdateSensitiveContentPreference :one
+UPDATE users
+SET sensitive_content = $1, updated_at = NOW()
+WHERE id = $2
+RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red, handle, is_moderator, sensitive_content, is_protected, display_name, bio, avatar_media_id, deletion_scheduled_at
+`
+
+type UpdateSensitiveContentPreferenceParams struct {
+	SensitiveContent string
+	ID               uuid.UUID
+}
+
+func (q *Queries) UpdateSensitiveContentPreference(ctx context.Context, arg UpdateSensitiveContentPreferenceParams) (User, error) {
+	row := q.db.QueryRowContext(ctx, updateSensitiveContentPreference, arg.SensitiveContent, arg.ID)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Email,
+		&i.HashedPassword,
+		&i.IsChirpyRed,
+		&i.Handle,
+		&i.IsModerator,
+		&i.SensitiveContent,
+		&i.IsProtected,
+		&i.DisplayName,
+		&i.Bio,
+		&i.AvatarMediaID,
+		&i.DeletionScheduledAt,
 	)
 	return i, err
 }
@@ -77,7 +293,7 @@ const updateToRedUserByUUID = `-- name: UpdateToRedUserByUUID :one
 UPDATE users
 SET is_chirpy_red = true
 WHERE id = $1
-RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red
+RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red, handle, is_moderator, sensitive_content, is_protected, display_name, bio, avatar_media_id, deletion_scheduled_at
 `
 
 func (q *Queries) UpdateToRedUserByUUID(ctx context.Context, id uuid.UUID) (User, error) {
@@ -90,6 +306,48 @@ func (q *Queries) UpdateToRedUserByUUID(ctx context.Context, id uuid.UUID) (User
 		&i.Email,
 		&i.HashedPassword,
 		&i.IsChirpyRed,
+		&i.Handle,
+		&i.IsModerator,
+		&i.SensitiveContent,
+		&i.IsProtected,
+		&i.DisplayName,
+		&i.Bio,
+		&i.AvatarMediaID,
+		&i.DeletionScheduledAt,
+	)
+	return i, err
+}
+
+const updateUserEmail = `-- name: UpdateUserEmail :one
+UPDATE users
+SET email = $1, updated_at = NOW()
+WHERE id = $2
+RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red, handle, is_moderator, sensitive_content, is_protected, display_name, bio, avatar_media_id, deletion_scheduled_at
+`
+
+type UpdateUserEmailParams struct {
+	Email string
+	ID    uuid.UUID
+}
+
+func (q *Queries) UpdateUserEmail(ctx context.Context, arg UpdateUserEmailParams) (User, error) {
+	row := q.db.QueryRowContext(ctx, updateUserEmail, arg.Email, arg.ID)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Email,
+		&i.HashedPassword,
+		&i.IsChirpyRed,
+		&i.Handle,
+		&i.IsModerator,
+		&i.SensitiveContent,
+		&i.IsProtected,
+		&i.DisplayName,
+		&i.Bio,
+		&i.AvatarMediaID,
+		&i.DeletionScheduledAt,
 	)
 	return i, err
 }
@@ -102,7 +360,7 @@ email = $1,
 hashed_password = $2,
 updated_at = NOW()
 WHERE id = $3
-RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red
+RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red, handle, is_moderator, sensitive_content, is_protected, display_name, bio, avatar_media_id, deletion_scheduled_at
 `
 
 type UpdateUserMailPassByUUIDParams struct {
@@ -121,6 +379,95 @@ func (q *Queries) UpdateUserMailPassByUUID(ctx context.Context, arg UpdateUserMa
 		&i.Email,
 		&i.HashedPassword,
 		&i.IsChirpyRed,
+		&i.Handle,
+		&i.IsModerator,
+		&i.SensitiveContent,
+		&i.IsProtected,
+		&i.DisplayName,
+		&i.Bio,
+		&i.AvatarMediaID,
+		&i.DeletionScheduledAt,
+	)
+	return i, err
+}
+
+const updateUserPassword = `-- name: UpdateUserPassword :one
+UPDATE users
+SET hashed_password = $1, updated_at = NOW()
+WHERE id = $2
+RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red, handle, is_moderator, sensitive_content, is_protected, display_name, bio, avatar_media_id, deletion_scheduled_at
+`
+
+type UpdateUserPasswordParams struct {
+	HashedPassword string
+	ID             uuid.UUID
+}
+
+func (q *Queries) UpdateUserPassword(ctx context.Context, arg UpdateUserPasswordParams) (User, error) {
+	row := q.db.QueryRowContext(ctx, updateUserPassword, arg.HashedPassword, arg.ID)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Email,
+		&i.HashedPassword,
+		&i.IsChirpyRed,
+		&i.Handle,
+		&i.IsModerator,
+		&i.SensitiveContent,
+		&i.IsProtected,
+		&i.DisplayName,
+		&i.Bio,
+		&i.AvatarMediaID,
+		&i.DeletionScheduledAt,
+	)
+	return i, err
+}
+
+const updateUserProfile = `-- name: UpdateUserProfile :one
+UPDATE users
+SET handle = $1,
+display_name = $2,
+bio = $3,
+avatar_media_id = $4,
+updated_at = NOW()
+WHERE id = $5
+RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red, handle, is_moderator, sensitive_content, is_protected, display_name, bio, avatar_media_id, deletion_scheduled_at
+`
+
+type UpdateUserProfileParams struct {
+	Handle        sql.NullString
+	DisplayName   string
+	Bio           string
+	AvatarMediaID uuid.NullUUID
+	ID            uuid.UUID
+}
+
+func (q *Queries) UpdateUserProfile(ctx context.Context, arg UpdateUserProfileParams) (User, error) {
+	row := q.db.QueryRowContext(ctx, updateUserProfile,
+		arg.Handle,
+		arg.DisplayName,
+		arg.Bio,
+		arg.AvatarMediaID,
+		arg.ID,
+	)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Email,
+		&i.HashedPassword,
+		&i.IsChirpyRed,
+		&i.Handle,
+		&i.IsModerator,
+		&i.SensitiveContent,
+		&i.IsProtected,
+		&i.DisplayName,
+		&i.Bio,
+		&i.AvatarMediaID,
+		&i.DeletionScheduledAt,
 	)
 	return i, err
 }
@@ -0,0 +1,208 @@
+package entities
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf16"
+)
+
+const MaxHandleLength = 30
+const MaxHashtagLength = 100
+
+// Span locates an entity in the text it was parsed from, both in runes
+// (code points) and in UTF-16 code units, which is what JavaScript and most
+// mobile platforms index strings by. Start is inclusive and End exclusive.
+type Span struct {
+	Start      int
+	End        int
+	StartUTF16 int
+	EndUTF16   int
+}
+
+type URL struct {
+	Span
+	URL string
+}
+
+type Mention struct {
+	Span
+	// Handle is written as in the text, without the '@'.
+	Handle string
+}
+
+type Hashtag struct {
+	Span
+	// Text is written as in the text, without the '#'. Tag is its normalized
+	// (lowercase) form.
+	Text string
+	Tag  string
+}
+
+type Entities struct {
+	URLs     []URL
+	Mentions []Mention
+	Hashtags []Hashtag
+}
+
+// Parse finds the URLs, @mentions and #hashtags in a text, each in order of
+// appearance. Mentions and hashtags inside a URL are part of the URL.
+//
+//   - A URL starts with http://, https:// or www. that isn't glued to a
+//     preceding letter or digit, and runs until whitespace. Trailing
+//     punctuation and unbalanced closing brackets are left out, so "(see
+//     https://example.com)." doesn't swallow the ")." The host needs a dot.
+//   - A mention is '@' followed by 1 to MaxHandleLength ASCII letters, digits
+//     or underscores, not preceded by one of those (so e-mail addresses don't
+//     count). A longer run isn't a mention at all.
+//   - A hashtag is '#' followed by letters, digits, marks or underscores
+//     including at least one letter, not preceded by one of those, and at most
+//     MaxHashtagLength bytes long.
+func Parse(text string) Entities {
+	runes := []rune(text)
+	utf16At := make([]int, len(runes)+1)
+	for i, r := range runes {
+		utf16At[i+1] = utf16At[i] + utf16.RuneLen(r)
+	}
+	span := func(start, end int) Span {
+		return Span{Start: start, End: end, StartUTF16: utf16At[start], EndUTF16: utf16At[end]}
+	}
+
+	ret := Entities{URLs: []URL{}, Mentions: []Mention{}, Hashtags: []Hashtag{}}
+	for i := 0; i < len(runes); i++ {
+		if end := urlEnd(runes, i); end > i {
+			ret.URLs = append(ret.URLs, URL{Span: span(i, end), URL: string(runes[i:end])})
+			i = end - 1
+			continue
+		}
+
+		switch runes[i] {
+		case '@':
+			if i > 0 && isHandleRune(runes[i-1]) {
+				continue
+			}
+			j := i + 1
+			for j < len(runes) && isHandleRune(runes[j]) {
+				j++
+			}
+			if n := j - i - 1; n > 0 && n <= MaxHandleLength {
+				ret.Mentions = append(ret.Mentions, Mention{Span: span(i, j), Handle: string(runes[i+1 : j])})
+			}
+			i = j - 1
+		case '#':
+			if i > 0 && isHashtagRune(runes[i-1]) {
+				continue
+			}
+			j := i + 1
+			hasLetter := false
+			for j < len(runes) && isHashtagRune(runes[j]) {
+				if unicode.IsLetter(runes[j]) {
+					hasLetter = true
+				}
+				j++
+			}
+			text := string(runes[i+1 : j])
+			if hasLetter && len(text) <= MaxHashtagLength {
+				ret.Hashtags = append(ret.Hashtags, Hashtag{Span: span(i, j), Text: text, Tag: strings.ToLower(text)})
+			}
+			i = j - 1
+		}
+	}
+	return ret
+}
+
+// Tags returns the normalized hashtags in order of first appearance, each
+// once however often it is used.
+func (e Entities) Tags() []string {
+	tags := []string{}
+	seen := map[string]bool{}
+	for _, h := range e.Hashtags {
+		if seen[h.Tag] {
+			continue
+		}
+		seen[h.Tag] = true
+		tags = append(tags, h.Tag)
+	}
+	return tags
+}
+
+func isHandleRune(r rune) bool {
+	return r == '_' || (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9')
+}
+
+func isHashtagRune(r rune) bool {
+	return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r) || unicode.Is(unicode.M, r)
+}
+
+var urlPrefixes = []string{"https://", "http://", "www."}
+
+// urlEnd returns where the URL starting at runes[i] ends, or i when there is
+// no URL there.
+func urlEnd(runes []rune, i int) int {
+	if i > 0 && (unicode.IsLetter(runes[i-1]) || unicode.IsDigit(runes[i-1])) {
+		return i
+	}
+	prefix := ""
+	for _, p := range urlPrefixes {
+		if hasPrefixFold(runes[i:], p) {
+			prefix = p
+			break
+		}
+	}
+	if prefix == "" {
+		return i
+	}
+
+	j := i
+	for j < len(runes) && !unicode.IsSpace(runes[j]) && !strings.ContainsRune(`<>"`, runes[j]) {
+		j++
+	}
+	// Drop trailing punctuation, and closing brackets that weren't opened
+	// inside the URL.
+	for j > i {
+		last := runes[j-1]
+		if strings.ContainsRune(".,:;!?'*", last) {
+			j--
+			continue
+		}
+		if open, ok := closingBrackets[last]; ok && count(runes[i:j], open) < count(runes[i:j], last) {
+			j--
+			continue
+		}
+		break
+	}
+
+	hostStart := i + len(prefix)
+	if prefix == "www." {
+		hostStart = i
+	}
+	hostEnd := hostStart
+	for hostEnd < j && !strings.ContainsRune("/?#", runes[hostEnd]) {
+		hostEnd++
+	}
+	host := string(runes[hostStart:hostEnd])
+	dot := strings.Index(host, ".")
+	if dot <= 0 || dot == len(host)-1 || strings.HasSuffix(host, ".") {
+		return i
+	}
+	return j
+}
+
+var closingBrackets = map[rune]rune{')': '(', ']': '[', '}': '{'}
+
+func hasPrefixFold(runes []rune, prefix string) bool {
+	p := []rune(prefix)
+	if len(runes) < len(p) {
+		return false
+	}
+	return strings.EqualFold(string(runes[:len(p)]), prefix)
+}
+
+func count(runes []rune, r rune) int {
+	n := 0
+	for _, x := range runes {
+		if x == r {
+			n++
+		}
+	}
+	return n
+}
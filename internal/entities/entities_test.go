@@ -0,0 +1,200 @@
+package entities
+
+import (
+	"slices"
+	"strings"
+	"testing"
+	"unicode/utf16"
+)
+
+type want struct {
+	urls     []string
+	mentions []string
+	hashtags []string
+}
+
+func texts(e Entities) want {
+	got := want{urls: []string{}, mentions: []string{}, hashtags: []string{}}
+	for _, u := range e.URLs {
+		got.urls = append(got.urls, u.URL)
+	}
+	for _, m := range e.Mentions {
+		got.mentions = append(got.mentions, m.Handle)
+	}
+	for _, h := range e.Hashtags {
+		got.hashtags = append(got.hashtags, h.Tag)
+	}
+	return got
+}
+
+var corpus = []struct {
+	name string
+	text string
+	want want
+}{
+	{"empty", "", want{}},
+	{"plain text", "just a chirp", want{}},
+	{"one of each", "hey @bob look at https://example.com #golang", want{
+		urls: []string{"https://example.com"}, mentions: []string{"bob"}, hashtags: []string{"golang"},
+	}},
+
+	// URLs
+	{"http", "http://example.com/path?q=1&r=2#frag", want{urls: []string{"http://example.com/path?q=1&r=2#frag"}}},
+	{"www", "go to www.example.org now", want{urls: []string{"www.example.org"}}},
+	{"uppercase scheme", "HTTPS://Example.COM/A", want{urls: []string{"HTTPS://Example.COM/A"}}},
+	{"trailing period", "see https://example.com.", want{urls: []string{"https://example.com"}}},
+	{"trailing ellipsis", "wow https://example.com/x...", want{urls: []string{"https://example.com/x"}}},
+	{"trailing comma and question", "https://a.io/b, or https://c.io/d?", want{urls: []string{"https://a.io/b", "https://c.io/d"}}},
+	{"wrapped in parens", "(see https://example.com/page)", want{urls: []string{"https://example.com/page"}}},
+	{"parens inside", "https://en.wikipedia.org/wiki/Go_(programming_language)", want{urls: []string{"https://en.wikipedia.org/wiki/Go_(programming_language)"}}},
+	{"parens inside and outside", "(https://en.wikipedia.org/wiki/Go_(lang)).", want{urls: []string{"https://en.wikipedia.org/wiki/Go_(lang)"}}},
+	{"quoted", `"https://example.com"`, want{urls: []string{"https://example.com"}}},
+	{"angle brackets", "<https://example.com>", want{urls: []string{"https://example.com"}}},
+	{"two urls back to back", "https://a.com https://b.com", want{urls: []string{"https://a.com", "https://b.com"}}},
+	{"scheme only", "https:// is not a link", want{}},
+	{"no dot in host", "http://localhost:8080/x", want{}},
+	{"glued to a word", "xhttps://example.com", want{}},
+	{"www without domain", "www. nope", want{}},
+	{"mention and hashtag inside url", "https://example.com/@bob/#tag", want{urls: []string{"https://example.com/@bob/#tag"}}},
+	{"url with userinfo", "https://user@example.com/", want{urls: []string{"https://user@example.com/"}}},
+	{"url after newline", "line\nhttps://example.com", want{urls: []string{"https://example.com"}}},
+
+	// Mentions
+	{"mention at start", "@alice hi", want{mentions: []string{"alice"}}},
+	{"mention punctuation", "thanks @alice, @bob! and @carol.", want{mentions: []string{"alice", "bob", "carol"}}},
+	{"mention case kept", "@Alice_99", want{mentions: []string{"Alice_99"}}},
+	{"email is not a mention", "mail bob@example.com", want{}},
+	{"lone at", "meet @ noon", want{}},
+	{"double at", "@@alice", want{mentions: []string{"alice"}}},
+	{"mention max length", "@abcdefghijklmnopqrstuvwxyz0123", want{mentions: []string{"abcdefghijklmnopqrstuvwxyz0123"}}},
+	{"mention too long", "@abcdefghijklmnopqrstuvwxyz01234", want{}},
+	{"mention then non ascii", "@bob日本", want{mentions: []string{"bob"}}},
+	{"mention in parens", "(@bob)", want{mentions: []string{"bob"}}},
+	{"mention after emoji", "😀@bob", want{mentions: []string{"bob"}}},
+
+	// Hashtags
+	{"hashtag punctuation", "#go, #rust! #zig.", want{hashtags: []string{"go", "rust", "zig"}}},
+	{"hashtag lowercased", "#GoLang", want{hashtags: []string{"golang"}}},
+	{"hashtag digits only", "#1 and #2024", want{}},
+	{"hashtag digits and letters", "#2024goals", want{hashtags: []string{"2024goals"}}},
+	{"hashtag glued to word", "c#sharp", want{}},
+	{"hashtag html entity", "&#123;", want{}},
+	{"double hash", "##go", want{hashtags: []string{"go"}}},
+	{"hashtag underscore", "#go_lang", want{hashtags: []string{"go_lang"}}},
+	{"hashtag cjk", "今日は #日本語 です", want{hashtags: []string{"日本語"}}},
+	{"hashtag korean", "#한국어", want{hashtags: []string{"한국어"}}},
+	{"hashtag combining mark", "#cafe\u0301!", want{hashtags: []string{"cafe\u0301"}}},
+	{"hashtag hindi", "#हिन्दी", want{hashtags: []string{"हिन्दी"}}},
+	{"hashtag ends at emoji", "#go🚀fast", want{hashtags: []string{"go"}}},
+	{"hashtag after emoji", "🚀#go", want{hashtags: []string{"go"}}},
+	{"repeated hashtags kept", "#go #Go", want{hashtags: []string{"go", "go"}}},
+
+	// Mixed scripts and emoji shifting UTF-16 offsets.
+	{"emoji everywhere", "👨‍👩‍👧 @mom 🎉 #family https://ex.am/🎉", want{
+		urls: []string{"https://ex.am/🎉"}, mentions: []string{"mom"}, hashtags: []string{"family"},
+	}},
+	{"flags", "🇪🇸🇫🇷 #euro", want{hashtags: []string{"euro"}}},
+	{"cjk punctuation", "見て：https://example.jp。@taro、#東京", want{
+		urls: []string{"https://example.jp。@taro、#東京"},
+	}},
+	{"cjk with spaces", "見て https://example.jp @taro #東京", want{
+		urls: []string{"https://example.jp"}, mentions: []string{"taro"}, hashtags: []string{"東京"},
+	}},
+}
+
+func TestParseCorpus(t *testing.T) {
+	for _, c := range corpus {
+		t.Run(c.name, func(t *testing.T) {
+			got := texts(Parse(c.text))
+			for _, pair := range []struct {
+				kind      string
+				got, want []string
+			}{
+				{"urls", got.urls, c.want.urls},
+				{"mentions", got.mentions, c.want.mentions},
+				{"hashtags", got.hashtags, c.want.hashtags},
+			} {
+				if !slices.Equal(pair.got, pair.want) && (len(pair.got) != 0 || len(pair.want) != 0) {
+					t.Errorf("%s = %q, want %q", pair.kind, pair.got, pair.want)
+				}
+			}
+		})
+	}
+}
+
+// TestParseOffsets checks that every span of every corpus entry points back at
+// the entity's own text, counting in runes and in UTF-16 code units.
+func TestParseOffsets(t *testing.T) {
+	for _, c := range corpus {
+		runes := []rune(c.text)
+		units := utf16.Encode(runes)
+		check := func(kind string, s Span, text string) {
+			if got := string(runes[s.Start:s.End]); got != text {
+				t.Errorf("%s: %s rune span [%d,%d) = %q, want %q", c.name, kind, s.Start, s.End, got, text)
+			}
+			if got := string(utf16.Decode(units[s.StartUTF16:s.EndUTF16])); got != text {
+				t.Errorf("%s: %s utf16 span [%d,%d) = %q, want %q", c.name, kind, s.StartUTF16, s.EndUTF16, got, text)
+			}
+		}
+		e := Parse(c.text)
+		for _, u := range e.URLs {
+			check("url", u.Span, u.URL)
+		}
+		for _, m := range e.Mentions {
+			check("mention", m.Span, "@"+m.Handle)
+		}
+		for _, h := range e.Hashtags {
+			check("hashtag", h.Span, "#"+h.Text)
+		}
+	}
+}
+
+func TestParseUTF16OffsetsDifferFromRunes(t *testing.T) {
+	e := Parse("😀 @bob")
+	if len(e.Mentions) != 1 {
+		t.Fatalf("mentions = %v", e.Mentions)
+	}
+	m := e.Mentions[0]
+	if m.Start != 2 || m.End != 6 {
+		t.Errorf("rune span = [%d,%d), want [2,6)", m.Start, m.End)
+	}
+	if m.StartUTF16 != 3 || m.EndUTF16 != 7 {
+		t.Errorf("utf16 span = [%d,%d), want [3,7)", m.StartUTF16, m.EndUTF16)
+	}
+}
+
+// TestParseTags covers what ends up in the hashtag index: which runs count as
+// tags, the length cap (in bytes, not runes) and dropping repeats.
+func TestParseTags(t *testing.T) {
+	for _, c := range []struct {
+		name string
+		text string
+		want []string
+	}{
+		{"digits only", "#1", []string{}},
+		{"digits then letter", "#1a", []string{"1a"}},
+		{"underscore only", "#_", []string{}},
+		{"underscore and letter", "#_a", []string{"_a"}},
+		{"cap", "#" + strings.Repeat("a", MaxHashtagLength), []string{strings.Repeat("a", MaxHashtagLength)}},
+		{"over cap", "#" + strings.Repeat("a", MaxHashtagLength+1), []string{}},
+		{"cap in bytes", "#" + strings.Repeat("é", MaxHashtagLength/2), []string{strings.Repeat("é", MaxHashtagLength/2)}},
+		{"over cap in bytes", "#" + strings.Repeat("é", MaxHashtagLength/2+1), []string{}},
+		{"over cap keeps the rest", "#" + strings.Repeat("a", MaxHashtagLength+1) + " #go", []string{"go"}},
+		{"repeat", "#go #go", []string{"go"}},
+		{"repeat in other case", "#Go #GO #go", []string{"go"}},
+		{"first appearance order", "#b #a #B #c #a", []string{"b", "a", "c"}},
+		{"repeat inside url not counted", "#go https://example.com/#go #rust", []string{"go", "rust"}},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			if got := Parse(c.text).Tags(); !slices.Equal(got, c.want) {
+				t.Errorf("Tags(%q) = %q, want %q", c.text, got, c.want)
+			}
+		})
+	}
+}
+
+func TestParseNeverPanics(t *testing.T) {
+	for _, text := range []string{"@", "#", "www.", "https://", "(((https://a.b", "\xff\xfe#go", "@\x00", "https://a.b)))"} {
+		Parse(text)
+	}
+}
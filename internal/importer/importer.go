@@ -0,0 +1,229 @@
+package importer
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Row is one chirp read from an archive. Line is its 1-based position: the
+// line for CSV, the array index plus one for JSON. A row that can't be used
+// has Err set and is reported on its own without failing the others.
+type Row struct {
+	Line            int
+	SourceID        string
+	Body            string
+	CreatedAt       time.Time
+	ReplyToSourceID string
+	Err             error
+}
+
+// The names each field goes by, in the order they're looked for. The first
+// one is what MarshalJSON writes; the others cover Twitter/X archives.
+var (
+	idKeys      = []string{"id", "id_str", "source_id"}
+	bodyKeys    = []string{"body", "text", "full_text"}
+	createdKeys = []string{"created_at", "timestamp"}
+	replyKeys   = []string{"in_reply_to_id", "in_reply_to_status_id_str", "in_reply_to_status_id"}
+)
+
+var timeLayouts = []string{time.RFC3339Nano, time.RubyDate, "2006-01-02 15:04:05", "2006-01-02T15:04:05"}
+
+// DetectFormat returns "csv" or "json" from a file name or content type,
+// defaulting to JSON.
+func DetectFormat(name, contentType string) string {
+	if strings.EqualFold(filepath.Ext(name), ".csv") || strings.HasPrefix(contentType, "text/csv") {
+		return "csv"
+	}
+	return "json"
+}
+
+// Parse reads every row of a JSON or CSV archive. It only fails when the
+// archive as a whole can't be read.
+//
+// JSON is an array of objects, each either the row itself or wrapping it in
+// a "tweet" object, optionally behind a "window.YTD.tweets.part0 = " style
+// prefix like in Twitter/X archives. CSV needs a header row naming the
+// columns.
+func Parse(r io.Reader, format string) ([]Row, error) {
+	switch format {
+	case "json":
+		return parseJSON(r)
+	case "csv":
+		return parseCSV(r)
+	}
+	return nil, fmt.Errorf("UNKNOWN FORMAT %q", format)
+}
+
+// SortByCreatedAt puts rows oldest first, keeping the archive order between
+// rows with the same time, so replies come after the chirps they answer even
+// across batches. Rows with Err set have no time and end up at the front.
+func SortByCreatedAt(rows []Row) {
+	slices.SortStableFunc(rows, func(a, b Row) int {
+		return a.CreatedAt.Compare(b.CreatedAt)
+	})
+}
+
+func parseJSON(r io.Reader) ([]Row, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	data = bytes.TrimSpace(data)
+	if len(data) > 0 && data[0] != '[' {
+		eq := bytes.IndexByte(data, '=')
+		if eq < 0 {
+			return nil, errors.New("archive is not a JSON array")
+		}
+		data = bytes.TrimSpace(data[eq+1:])
+	}
+
+	items := []json.RawMessage{}
+	err = json.Unmarshal(data, &items)
+	if err != nil {
+		return nil, fmt.Errorf("archive is not a JSON array: %w", err)
+	}
+	rows := []Row{}
+	for i, item := range items {
+		fields, err := decodeObject(item)
+		if err != nil {
+			rows = append(rows, Row{Line: i + 1, Err: errors.New("row is not a JSON object")})
+			continue
+		}
+		if wrapped, ok := fields["tweet"]; ok {
+			if fields, err = decodeObject(wrapped); err != nil {
+				rows = append(rows, Row{Line: i + 1, Err: errors.New("tweet is not a JSON object")})
+				continue
+			}
+		}
+		values := map[string]string{}
+		for k, v := range fields {
+			values[k] = jsonString(v)
+		}
+		rows = append(rows, newRow(i+1, values))
+	}
+	return rows, nil
+}
+
+func decodeObject(data json.RawMessage) (map[string]json.RawMessage, error) {
+	fields := map[string]json.RawMessage{}
+	err := json.Unmarshal(data, &fields)
+	return fields, err
+}
+
+// jsonString reads strings as they are and numbers as written, so a
+// numeric id isn't rounded through a float64.
+func jsonString(v json.RawMessage) string {
+	s := ""
+	if json.Unmarshal(v, &s) == nil {
+		return s
+	}
+	n := json.Number("")
+	if json.Unmarshal(v, &n) == nil {
+		return n.String()
+	}
+	return ""
+}
+
+func parseCSV(r io.Reader) ([]Row, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	header, err := cr.Read()
+	if err != nil {
+		return nil, fmt.Errorf("archive has no CSV header: %w", err)
+	}
+	for i := range header {
+		header[i] = strings.ToLower(strings.TrimSpace(header[i]))
+	}
+
+	rows := []Row{}
+	for {
+		record, err := cr.Read()
+		if err == io.EOF {
+			break
+		}
+		var perr *csv.ParseError
+		if errors.As(err, &perr) {
+			// The reader can't resync after a broken quote, so stop here.
+			rows = append(rows, Row{Line: perr.StartLine, Err: perr.Err})
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		line, _ := cr.FieldPos(0)
+		if len(record) != len(header) {
+			rows = append(rows, Row{Line: line, Err: fmt.Errorf("row has %d columns, the header has %d", len(record), len(header))})
+			continue
+		}
+		values := map[string]string{}
+		for i, v := range record {
+			values[header[i]] = v
+		}
+		rows = append(rows, newRow(line, values))
+	}
+	return rows, nil
+}
+
+func lookup(values map[string]string, keys []string) string {
+	for _, k := range keys {
+		if v := strings.TrimSpace(values[k]); v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+func newRow(line int, values map[string]string) Row {
+	row := Row{
+		Line:            line,
+		SourceID:        lookup(values, idKeys),
+		ReplyToSourceID: lookup(values, replyKeys),
+	}
+	// The body keeps its spacing, only the other fields are trimmed.
+	for _, k := range bodyKeys {
+		if values[k] != "" {
+			row.Body = values[k]
+			break
+		}
+	}
+	if row.SourceID == "" {
+		row.Err = errors.New("row has no id")
+		return row
+	}
+	created := lookup(values, createdKeys)
+	if created == "" {
+		row.Err = errors.New("row has no created_at")
+		return row
+	}
+	for _, layout := range timeLayouts {
+		t, err := time.Parse(layout, created)
+		if err == nil {
+			row.CreatedAt = t.UTC()
+			return row
+		}
+	}
+	row.Err = fmt.Errorf("created_at %q is not a known time format", created)
+	return row
+}
+
+// MarshalJSON writes a row the way Parse reads it back, which is how the CLI
+// sends batches to the server.
+func (r Row) MarshalJSON() ([]byte, error) {
+	out := map[string]string{
+		idKeys[0]:      r.SourceID,
+		bodyKeys[0]:    r.Body,
+		createdKeys[0]: r.CreatedAt.Format(time.RFC3339Nano),
+	}
+	if r.ReplyToSourceID != "" {
+		out[replyKeys[0]] = r.ReplyToSourceID
+	}
+	return json.Marshal(out)
+}
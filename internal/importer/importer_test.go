@@ -0,0 +1,159 @@
+package importer
+
+import (
+	"encoding/json"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseJSON(t *testing.T) {
+	archive := `[
+		{"id": "1", "body": "hello", "created_at": "2020-01-02T03:04:05Z"},
+		{"id": 1234567890123456789, "text": "big id", "created_at": "2020-01-02 03:04:05", "in_reply_to_id": "1"},
+		{"body": "no id", "created_at": "2020-01-02T03:04:05Z"},
+		{"id": "4", "body": "bad date", "created_at": "yesterday"},
+		"not an object"
+	]`
+	rows, err := Parse(strings.NewReader(archive), "json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 5 {
+		t.Fatalf("got %d rows, want 5", len(rows))
+	}
+	want := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	if r := rows[0]; r.Err != nil || r.Line != 1 || r.SourceID != "1" || r.Body != "hello" || !r.CreatedAt.Equal(want) {
+		t.Errorf("row 1 = %+v", r)
+	}
+	if r := rows[1]; r.Err != nil || r.SourceID != "1234567890123456789" || r.Body != "big id" || r.ReplyToSourceID != "1" || !r.CreatedAt.Equal(want) {
+		t.Errorf("row 2 = %+v", r)
+	}
+	for i := 2; i < 5; i++ {
+		if rows[i].Err == nil || rows[i].Line != i+1 {
+			t.Errorf("row %d = %+v, want an error", i+1, rows[i])
+		}
+	}
+}
+
+func TestParseTwitterArchive(t *testing.T) {
+	archive := `window.YTD.tweets.part0 = [
+		{"tweet": {"id_str": "99", "full_text": "from X", "created_at": "Wed Oct 10 20:19:24 +0000 2018", "in_reply_to_status_id_str": "98"}}
+	]`
+	rows, err := Parse(strings.NewReader(archive), "json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 1 {
+		t.Fatalf("got %d rows, want 1", len(rows))
+	}
+	r := rows[0]
+	want := time.Date(2018, 10, 10, 20, 19, 24, 0, time.UTC)
+	if r.Err != nil || r.SourceID != "99" || r.Body != "from X" || r.ReplyToSourceID != "98" || !r.CreatedAt.Equal(want) {
+		t.Errorf("row = %+v", r)
+	}
+}
+
+func TestParseJSONNotAnArray(t *testing.T) {
+	for _, archive := range []string{`{"id": "1"}`, `nonsense`, `x = {`} {
+		if _, err := Parse(strings.NewReader(archive), "json"); err == nil {
+			t.Errorf("Parse(%q) succeeded", archive)
+		}
+	}
+}
+
+func TestParseCSV(t *testing.T) {
+	archive := "ID,Text,Created_At\n" +
+		"1,\"hello, world\",2020-01-02T03:04:05Z\n" +
+		"2,too few\n" +
+		"3,\"multi\nline\",2020-01-02T03:04:05Z\n" +
+		",no id,2020-01-02T03:04:05Z\n"
+	rows, err := Parse(strings.NewReader(archive), "csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 4 {
+		t.Fatalf("got %d rows, want 4", len(rows))
+	}
+	if r := rows[0]; r.Err != nil || r.Line != 2 || r.SourceID != "1" || r.Body != "hello, world" {
+		t.Errorf("row 1 = %+v", r)
+	}
+	if r := rows[1]; r.Err == nil || r.Line != 3 {
+		t.Errorf("row 2 = %+v, want a column count error on line 3", r)
+	}
+	if r := rows[2]; r.Err != nil || r.Line != 4 || r.Body != "multi\nline" {
+		t.Errorf("row 3 = %+v", r)
+	}
+	if r := rows[3]; r.Err == nil || r.Line != 6 {
+		t.Errorf("row 4 = %+v, want a missing id error on line 6", r)
+	}
+}
+
+func TestParseCSVBrokenQuote(t *testing.T) {
+	archive := "id,body,created_at\n1,ok,2020-01-02T03:04:05Z\n2,\"broken,2020-01-02T03:04:05Z\n"
+	rows, err := Parse(strings.NewReader(archive), "csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 2 || rows[0].Err != nil || rows[1].Err == nil {
+		t.Errorf("rows = %+v, want one good row then an error", rows)
+	}
+}
+
+func TestMarshalJSONRoundTrip(t *testing.T) {
+	in := []Row{
+		{SourceID: "7", Body: "  spaced  ", CreatedAt: time.Date(2019, 5, 6, 7, 8, 9, 123000000, time.UTC), ReplyToSourceID: "6"},
+		{SourceID: "8", Body: "plain", CreatedAt: time.Date(2019, 5, 6, 7, 8, 9, 0, time.UTC)},
+	}
+	data, err := json.Marshal(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := Parse(strings.NewReader(string(data)), "json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range in {
+		got, want := out[i], in[i]
+		if got.Err != nil || got.SourceID != want.SourceID || got.Body != want.Body || got.ReplyToSourceID != want.ReplyToSourceID || !got.CreatedAt.Equal(want.CreatedAt) {
+			t.Errorf("row %d = %+v, want %+v", i+1, got, want)
+		}
+	}
+}
+
+func TestSortByCreatedAt(t *testing.T) {
+	day := func(d int) time.Time { return time.Date(2020, 1, d, 0, 0, 0, 0, time.UTC) }
+	rows := []Row{
+		{Line: 1, SourceID: "3", CreatedAt: day(3)},
+		{Line: 2, SourceID: "2b", CreatedAt: day(2)},
+		{Line: 3, SourceID: "bad", Err: errors.New("row has no created_at")},
+		{Line: 4, SourceID: "2a", CreatedAt: day(2)},
+		{Line: 5, SourceID: "1", CreatedAt: day(1)},
+	}
+	SortByCreatedAt(rows)
+	got := []string{}
+	for _, row := range rows {
+		got = append(got, row.SourceID)
+	}
+	want := []string{"bad", "1", "2b", "2a", "3"}
+	if !slices.Equal(got, want) {
+		t.Errorf("order = %v, want %v", got, want)
+	}
+}
+
+func TestDetectFormat(t *testing.T) {
+	cases := []struct{ name, contentType, want string }{
+		{"tweets.csv", "", "csv"},
+		{"TWEETS.CSV", "", "csv"},
+		{"", "text/csv; charset=utf-8", "csv"},
+		{"tweets.js", "", "json"},
+		{"", "application/json", "json"},
+	}
+	for _, c := range cases {
+		if got := DetectFormat(c.name, c.contentType); got != c.want {
+			t.Errorf("DetectFormat(%q, %q) = %q, want %q", c.name, c.contentType, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,328 @@
+package media
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"net/http"
+)
+
+const MaxUploadBytes = 5 << 20
+const MaxDimension = 8000
+const ThumbnailMaxSide = 320
+
+// Every GIF frame is decoded into its own bitmap, so animations are capped
+// both in frames and in the pixels of all frames together.
+const MaxGIFFrames = 500
+const MaxGIFPixels = 100_000_000
+
+var ErrUnsupportedType = errors.New("unsupported media type")
+var ErrTooLarge = errors.New("image dimensions too large")
+var ErrTooManyFrames = errors.New("animated GIF too long")
+
+type Image struct {
+	ContentType string
+	Data        []byte
+	Width       int
+	Height      int
+}
+
+type Processed struct {
+	Original  Image
+	Thumbnail Image
+}
+
+// Process validates an uploaded image and prepares it for storage. The type
+// is sniffed from the content, never trusted from the client. The image is
+// decoded and re-encoded, which drops EXIF and any other metadata (JPEG
+// orientation is applied to the pixels first), and a thumbnail no larger than
+// ThumbnailMaxSide on either side is generated.
+func Process(data []byte) (Processed, error) {
+	contentType := http.DetectContentType(data)
+	switch contentType {
+	case "image/jpeg", "image/png", "image/gif":
+	default:
+		return Processed{}, ErrUnsupportedType
+	}
+
+	// Check the declared size before decoding so a tiny file can't make us
+	// allocate a gigantic bitmap.
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return Processed{}, ErrUnsupportedType
+	}
+	if cfg.Width > MaxDimension || cfg.Height > MaxDimension {
+		return Processed{}, ErrTooLarge
+	}
+
+	var first image.Image
+	var out bytes.Buffer
+	switch contentType {
+	case "image/jpeg":
+		img, err := jpeg.Decode(bytes.NewReader(data))
+		if err != nil {
+			return Processed{}, ErrUnsupportedType
+		}
+		first = orient(img, jpegOrientation(data))
+		err = jpeg.Encode(&out, first, &jpeg.Options{Quality: 90})
+		if err != nil {
+			return Processed{}, err
+		}
+	case "image/png":
+		img, err := png.Decode(bytes.NewReader(data))
+		if err != nil {
+			return Processed{}, ErrUnsupportedType
+		}
+		first = img
+		err = png.Encode(&out, img)
+		if err != nil {
+			return Processed{}, err
+		}
+	case "image/gif":
+		frames, pixels, err := gifFrames(data)
+		if err != nil {
+			return Processed{}, ErrUnsupportedType
+		}
+		if frames > MaxGIFFrames || pixels > MaxGIFPixels {
+			return Processed{}, ErrTooManyFrames
+		}
+		g, err := gif.DecodeAll(bytes.NewReader(data))
+		if err != nil || len(g.Image) == 0 {
+			return Processed{}, ErrUnsupportedType
+		}
+		first = g.Image[0]
+		err = gif.EncodeAll(&out, g)
+		if err != nil {
+			return Processed{}, err
+		}
+	}
+
+	bounds := first.Bounds()
+	ret := Processed{
+		Original: Image{
+			ContentType: contentType,
+			Data:        out.Bytes(),
+			Width:       bounds.Dx(),
+			Height:      bounds.Dy(),
+		},
+	}
+
+	thumb := thumbnail(first, ThumbnailMaxSide)
+	var thumbOut bytes.Buffer
+	thumbType := "image/png"
+	if contentType == "image/jpeg" {
+		thumbType = "image/jpeg"
+		err = jpeg.Encode(&thumbOut, thumb, &jpeg.Options{Quality: 80})
+	} else {
+		err = png.Encode(&thumbOut, thumb)
+	}
+	if err != nil {
+		return Processed{}, err
+	}
+	ret.Thumbnail = Image{
+		ContentType: thumbType,
+		Data:        thumbOut.Bytes(),
+		Width:       thumb.Bounds().Dx(),
+		Height:      thumb.Bounds().Dy(),
+	}
+	return ret, nil
+}
+
+// gifFrames walks the blocks of a GIF without decoding it and returns how
+// many frames it has and how many pixels they add up to.
+func gifFrames(data []byte) (int, int64, error) {
+	errMalformed := errors.New("malformed GIF")
+	// Header and logical screen descriptor.
+	i := 13
+	if len(data) < i {
+		return 0, 0, errMalformed
+	}
+	if data[10]&0x80 != 0 {
+		i += 3 << ((data[10] & 0x07) + 1)
+	}
+	skipSubBlocks := func() error {
+		for {
+			if i >= len(data) {
+				return errMalformed
+			}
+			size := int(data[i])
+			i += 1 + size
+			if size == 0 {
+				return nil
+			}
+		}
+	}
+
+	frames, pixels := 0, int64(0)
+	for i < len(data) {
+		switch data[i] {
+		case 0x21: // extension: introducer, label, data sub-blocks
+			i += 2
+			if err := skipSubBlocks(); err != nil {
+				return 0, 0, err
+			}
+		case 0x2C: // image descriptor, then LZW code size and data sub-blocks
+			if i+10 > len(data) {
+				return 0, 0, errMalformed
+			}
+			w := int64(binary.LittleEndian.Uint16(data[i+5:]))
+			h := int64(binary.LittleEndian.Uint16(data[i+7:]))
+			packed := data[i+9]
+			i += 10
+			if packed&0x80 != 0 {
+				i += 3 << ((packed & 0x07) + 1)
+			}
+			i++
+			if err := skipSubBlocks(); err != nil {
+				return 0, 0, err
+			}
+			frames++
+			pixels += w * h
+		case 0x3B: // trailer
+			return frames, pixels, nil
+		default:
+			return 0, 0, errMalformed
+		}
+	}
+	return 0, 0, errMalformed
+}
+
+// thumbnail scales src down (never up) so its longest side is at most
+// maxSide, averaging every source pixel that falls into a target pixel.
+func thumbnail(src image.Image, maxSide int) *image.RGBA {
+	b := src.Bounds()
+	w, h := b.Dx(), b.Dy()
+	tw, th := w, h
+	if w >= h && w > maxSide {
+		tw, th = maxSide, max(1, h*maxSide/w)
+	} else if h > w && h > maxSide {
+		tw, th = max(1, w*maxSide/h), maxSide
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, tw, th))
+	if tw == w && th == h {
+		draw.Draw(dst, dst.Bounds(), src, b.Min, draw.Src)
+		return dst
+	}
+
+	for y := 0; y < th; y++ {
+		y0, y1 := b.Min.Y+y*h/th, b.Min.Y+(y+1)*h/th
+		for x := 0; x < tw; x++ {
+			x0, x1 := b.Min.X+x*w/tw, b.Min.X+(x+1)*w/tw
+			var r, g, bl, a, n uint64
+			for sy := y0; sy < max(y1, y0+1); sy++ {
+				for sx := x0; sx < max(x1, x0+1); sx++ {
+					cr, cg, cb, ca := src.At(sx, sy).RGBA()
+					r, g, bl, a = r+uint64(cr), g+uint64(cg), bl+uint64(cb), a+uint64(ca)
+					n++
+				}
+			}
+			dst.SetRGBA64(x, y, color.RGBA64{R: uint16(r / n), G: uint16(g / n), B: uint16(bl / n), A: uint16(a / n)})
+		}
+	}
+	return dst
+}
+
+// jpegOrientation returns the EXIF orientation (1-8) of a JPEG, or 1 when it
+// has none.
+func jpegOrientation(data []byte) int {
+	if len(data) < 4 || data[0] != 0xFF || data[1] != 0xD8 {
+		return 1
+	}
+	i := 2
+	for i+4 <= len(data) {
+		if data[i] != 0xFF {
+			return 1
+		}
+		marker := data[i+1]
+		size := int(binary.BigEndian.Uint16(data[i+2:]))
+		if marker == 0xDA || size < 2 || i+2+size > len(data) {
+			// Start of scan: no more metadata segments.
+			return 1
+		}
+		segment := data[i+4 : i+2+size]
+		if marker == 0xE1 && bytes.HasPrefix(segment, []byte("Exif\x00\x00")) {
+			return exifOrientation(segment[6:])
+		}
+		i += 2 + size
+	}
+	return 1
+}
+
+func exifOrientation(tiff []byte) int {
+	if len(tiff) < 8 {
+		return 1
+	}
+	var order binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return 1
+	}
+	ifd := int(order.Uint32(tiff[4:]))
+	if ifd+2 > len(tiff) {
+		return 1
+	}
+	entries := int(order.Uint16(tiff[ifd:]))
+	for e := 0; e < entries; e++ {
+		entry := ifd + 2 + e*12
+		if entry+12 > len(tiff) {
+			return 1
+		}
+		if order.Uint16(tiff[entry:]) == 0x0112 {
+			o := int(order.Uint16(tiff[entry+8:]))
+			if o < 1 || o > 8 {
+				return 1
+			}
+			return o
+		}
+	}
+	return 1
+}
+
+// orient applies an EXIF orientation to the pixels so the image still looks
+// right once the metadata is gone.
+func orient(src image.Image, orientation int) image.Image {
+	if orientation == 1 {
+		return src
+	}
+	b := src.Bounds()
+	w, h := b.Dx(), b.Dy()
+	dw, dh := w, h
+	if orientation >= 5 {
+		dw, dh = h, w
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			var dx, dy int
+			switch orientation {
+			case 2:
+				dx, dy = w-1-x, y
+			case 3:
+				dx, dy = w-1-x, h-1-y
+			case 4:
+				dx, dy = x, h-1-y
+			case 5:
+				dx, dy = y, x
+			case 6:
+				dx, dy = h-1-y, x
+			case 7:
+				dx, dy = h-1-y, w-1-x
+			case 8:
+				dx, dy = y, w-1-x
+			}
+			dst.Set(dx, dy, src.At(b.Min.X+x, b.Min.Y+y))
+		}
+	}
+	return dst
+}
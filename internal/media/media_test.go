@@ -0,0 +1,158 @@
+package media
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"testing"
+)
+
+func encodePNG(t *testing.T, w, h int) []byte {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			img.Set(x, y, color.RGBA{R: uint8(x), G: uint8(y), B: 200, A: 255})
+		}
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// encodeGIF encodes an animation of the given number of w x h frames.
+func encodeGIF(t *testing.T, frames, w, h int) []byte {
+	t.Helper()
+	g := &gif.GIF{}
+	for range frames {
+		g.Image = append(g.Image, image.NewPaletted(image.Rect(0, 0, w, h), color.Palette{color.Black, color.White}))
+		g.Delay = append(g.Delay, 10)
+	}
+	var buf bytes.Buffer
+	if err := gif.EncodeAll(&buf, g); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// jpegWithOrientation encodes a w x h JPEG and splices in an EXIF APP1
+// segment carrying the given orientation.
+func jpegWithOrientation(t *testing.T, w, h int, orientation uint16) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, image.NewRGBA(image.Rect(0, 0, w, h)), nil); err != nil {
+		t.Fatal(err)
+	}
+	data := buf.Bytes()
+
+	tiff := []byte("MM\x00\x2a\x00\x00\x00\x08")
+	tiff = binary.BigEndian.AppendUint16(tiff, 1)
+	tiff = binary.BigEndian.AppendUint16(tiff, 0x0112)
+	tiff = binary.BigEndian.AppendUint16(tiff, 3)
+	tiff = binary.BigEndian.AppendUint32(tiff, 1)
+	tiff = binary.BigEndian.AppendUint16(tiff, orientation)
+	tiff = append(tiff, 0, 0, 0, 0, 0, 0)
+	segment := append([]byte("Exif\x00\x00"), tiff...)
+
+	app1 := []byte{0xFF, 0xE1}
+	app1 = binary.BigEndian.AppendUint16(app1, uint16(len(segment)+2))
+	app1 = append(app1, segment...)
+
+	out := append([]byte{}, data[:2]...)
+	out = append(out, app1...)
+	return append(out, data[2:]...)
+}
+
+func TestProcessPNG(t *testing.T) {
+	p, err := Process(encodePNG(t, 640, 320))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Original.ContentType != "image/png" || p.Original.Width != 640 || p.Original.Height != 320 {
+		t.Errorf("original = %s %dx%d", p.Original.ContentType, p.Original.Width, p.Original.Height)
+	}
+	if p.Thumbnail.Width != 320 || p.Thumbnail.Height != 160 {
+		t.Errorf("thumbnail = %dx%d, want 320x160", p.Thumbnail.Width, p.Thumbnail.Height)
+	}
+}
+
+func TestProcessSmallImageKeepsSize(t *testing.T) {
+	p, err := Process(encodePNG(t, 10, 20))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Thumbnail.Width != 10 || p.Thumbnail.Height != 20 {
+		t.Errorf("thumbnail = %dx%d, want 10x20", p.Thumbnail.Width, p.Thumbnail.Height)
+	}
+}
+
+func TestProcessStripsExifAndAppliesOrientation(t *testing.T) {
+	data := jpegWithOrientation(t, 40, 20, 6)
+	if jpegOrientation(data) != 6 {
+		t.Fatalf("test image orientation = %d, want 6", jpegOrientation(data))
+	}
+
+	p, err := Process(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Original.Width != 20 || p.Original.Height != 40 {
+		t.Errorf("original = %dx%d, want 20x40", p.Original.Width, p.Original.Height)
+	}
+	if bytes.Contains(p.Original.Data, []byte("Exif")) {
+		t.Error("original still contains EXIF data")
+	}
+	if jpegOrientation(p.Original.Data) != 1 {
+		t.Error("original still carries an orientation")
+	}
+}
+
+func TestProcessRejectsNonImages(t *testing.T) {
+	_, err := Process([]byte("<html><script>alert(1)</script></html>"))
+	if !errors.Is(err, ErrUnsupportedType) {
+		t.Errorf("err = %v, want ErrUnsupportedType", err)
+	}
+}
+
+func TestProcessRejectsHugeDimensions(t *testing.T) {
+	_, err := Process(encodePNG(t, MaxDimension+1, 1))
+	if !errors.Is(err, ErrTooLarge) {
+		t.Errorf("err = %v, want ErrTooLarge", err)
+	}
+}
+
+func TestGIFFrames(t *testing.T) {
+	frames, pixels, err := gifFrames(encodeGIF(t, 3, 20, 10))
+	if err != nil || frames != 3 || pixels != 600 {
+		t.Errorf("gifFrames = %d, %d, %v, want 3, 600, nil", frames, pixels, err)
+	}
+	data := encodeGIF(t, 2, 20, 10)
+	_, _, err = gifFrames(data[:len(data)-1])
+	if err == nil {
+		t.Error("gifFrames accepted a GIF without its trailer")
+	}
+}
+
+func TestProcessGIF(t *testing.T) {
+	p, err := Process(encodeGIF(t, 3, 20, 10))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Original.ContentType != "image/gif" || p.Original.Width != 20 || p.Original.Height != 10 {
+		t.Errorf("original = %s %dx%d", p.Original.ContentType, p.Original.Width, p.Original.Height)
+	}
+}
+
+func TestProcessRejectsLongGIFs(t *testing.T) {
+	_, err := Process(encodeGIF(t, MaxGIFFrames+1, 1, 1))
+	if !errors.Is(err, ErrTooManyFrames) {
+		t.Errorf("err = %v, want ErrTooManyFrames", err)
+	}
+}
@@ -0,0 +1,271 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/Serux/chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+const maxContentWarningLength = 100
+const defaultSensitiveContent = "collapse"
+
+// sensitiveContentModes are the ways a user can have chirps with a content
+// warning or the sensitive flag shown in lists: as is, collapsed behind the
+// warning (clients get "collapsed": true), or left out altogether. Users
+// always see their own chirps.
+var sensitiveContentModes = []string{"show", "collapse", "hide"}
+
+type chirpLabelsRequestJson struct {
+	ContentWarning string `json:"content_warning"`
+	Sensitive      bool   `json:"sensitive"`
+}
+
+// parseContentWarning validates an optional content warning, an empty one
+// means no warning.
+func parseContentWarning(value string) (sql.NullString, error) {
+	warning := strings.TrimSpace(value)
+	if warning == "" {
+		return sql.NullString{}, nil
+	}
+	if utf8.RuneCountInString(warning) > maxContentWarningLength {
+		return sql.NullString{}, fmt.Errorf("content_warning can be at most %d characters", maxContentWarningLength)
+	}
+	return sql.NullString{String: removeProfanity(warning), Valid: true}, nil
+}
+
+// sensitiveContentPreference returns how viewer wants sensitive chirps shown.
+// Anonymous viewers get the default, and so do tokens of users that no
+// longer exist, such as erased accounts whose access token hasn't expired.
+func (cfg *apiConfig) sensitiveContentPreference(ctx context.Context, viewer uuid.UUID) (string, error) {
+	if viewer == uuid.Nil {
+		return defaultSensitiveContent, nil
+	}
+	user, err := cfg.queries.SelectUserById(ctx, viewer)
+	if errors.Is(err, sql.ErrNoRows) {
+		return defaultSensitiveContent, nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return user.SensitiveContent, nil
+}
+
+// patchChirpHandler lets the author change the content warning and sensitive
+// flag of a chirp, unless a moderator has labelled it already. Fields left
+// out keep their value, an empty content_warning removes it. It lives on the
+// chirp itself because PUT /api/chirps/{chirpID}/labels would clash with
+// PUT /api/chirps/scheduled/{chirpID}.
+func (cfg *apiConfig) patchChirpHandler(rw http.ResponseWriter, r *http.Request) {
+	type requestJson struct {
+		ContentWarning *string `json:"content_warning"`
+		Sensitive      *bool   `json:"sensitive"`
+	}
+
+	uidtok, err := cfg.authenticatedUserId(r)
+	if err != nil {
+		respondWithError(rw, http.StatusUnauthorized, "Something went wrong validating JWT")
+		return
+	}
+	chirpId, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		respondWithError(rw, http.StatusNotFound, "Chirp not found")
+		return
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	params := requestJson{}
+	err = decoder.Decode(&params)
+	if err != nil {
+		respondWithError(rw, http.StatusBadRequest, "Something went wrong decoding input")
+		return
+	}
+	if params.ContentWarning == nil && params.Sensitive == nil {
+		respondWithError(rw, http.StatusBadRequest, "Nothing to update, send content_warning or sensitive")
+		return
+	}
+
+	chirp, err := cfg.queries.SelectOneChirps(r.Context(), database.SelectOneChirpsParams{ID: chirpId, ViewerID: uidtok})
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(rw, http.StatusNotFound, "Chirp not found")
+		return
+	}
+	if err != nil {
+		respondWithError(rw, http.StatusInternalServerError, "Something went wrong getting chirp")
+		return
+	}
+	if chirp.UserID != uidtok {
+		respondWithError(rw, http.StatusForbidden, "You can only label your own chirps")
+		return
+	}
+	if chirp.LabelsForced {
+		respondWithError(rw, http.StatusForbidden, "This chirp was labelled by a moderator")
+		return
+	}
+
+	warning := chirp.ContentWarning
+	if params.ContentWarning != nil {
+		warning, err = parseContentWarning(*params.ContentWarning)
+		if err != nil {
+			respondWithError(rw, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+	sensitive := chirp.Sensitive
+	if params.Sensitive != nil {
+		sensitive = *params.Sensitive
+	}
+
+	chirp, err = cfg.queries.UpdateChirpLabels(r.Context(), database.UpdateChirpLabelsParams{
+		ContentWarning: warning,
+		Sensitive:      sensitive,
+		ID:             chirpId,
+		UserID:         uidtok,
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(rw, http.StatusForbidden, "This chirp was labelled by a moderator")
+		return
+	}
+	if err != nil {
+		respondWithError(rw, http.StatusInternalServerError, "Something went wrong labelling chirp")
+		return
+	}
+
+	ret, err := cfg.chirpsToJson(r.Context(), uidtok, []database.Chirp{chirp})
+	if err != nil {
+		respondWithError(rw, http.StatusInternalServerError, "Something went wrong loading chirp")
+		return
+	}
+	respondWithJSON(rw, http.StatusOK, ret[0])
+}
+
+// putModerationLabelsHandler lets a moderator set the labels of any chirp and
+// locks them so the author can't take them off again.
+func (cfg *apiConfig) putModerationLabelsHandler(rw http.ResponseWriter, r *http.Request) {
+	uidtok, err := cfg.authenticatedUserId(r)
+	if err != nil {
+		respondWithError(rw, http.StatusUnauthorized, "Something went wrong validating JWT")
+		return
+	}
+	user, err := cfg.queries.SelectUserById(r.Context(), uidtok)
+	if err != nil || !user.IsModerator {
+		respondWithError(rw, http.StatusForbidden, "Only moderators can do that")
+		return
+	}
+	chirpId, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		respondWithError(rw, http.StatusNotFound, "Chirp not found")
+		return
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	params := chirpLabelsRequestJson{}
+	err = decoder.Decode(&params)
+	if err != nil {
+		respondWithError(rw, http.StatusBadRequest, "Something went wrong decoding input")
+		return
+	}
+	warning, err := parseContentWarning(params.ContentWarning)
+	if err != nil {
+		respondWithError(rw, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	chirp, err := cfg.queries.ForceChirpLabels(r.Context(), database.ForceChirpLabelsParams{
+		ContentWarning: warning,
+		Sensitive:      params.Sensitive,
+		ID:             chirpId,
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(rw, http.StatusNotFound, "Chirp not found")
+		return
+	}
+	if err != nil {
+		respondWithError(rw, http.StatusInternalServerError, "Something went wrong labelling chirp")
+		return
+	}
+
+	ret, err := cfg.chirpsToJson(r.Context(), uidtok, []database.Chirp{chirp})
+	if err != nil {
+		respondWithError(rw, http.StatusInternalServerError, "Something went wrong loading chirp")
+		return
+	}
+	respondWithJSON(rw, http.StatusOK, ret[0])
+}
+
+// putPreferencesHandler updates the preferences sent, leaving out the others.
+func (cfg *apiConfig) putPreferencesHandler(rw http.ResponseWriter, r *http.Request) {
+	type requestJson struct {
+		SensitiveContent *string `json:"sensitive_content"`
+		IsProtected      *bool   `json:"is_protected"`
+	}
+	type responseJson struct {
+		SensitiveContent string `json:"sensitive_content"`
+		IsProtected      bool   `json:"is_protected"`
+	}
+
+	uidtok, err := cfg.authenticatedUserId(r)
+	if err != nil {
+		respondWithError(rw, http.StatusUnauthorized, "Something went wrong validating JWT")
+		return
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	params := requestJson{}
+	err = decoder.Decode(&params)
+	if err != nil {
+		respondWithError(rw, http.StatusBadRequest, "Something went wrong decoding input")
+		return
+	}
+	if params.SensitiveContent == nil && params.IsProtected == nil {
+		respondWithError(rw, http.StatusBadRequest, "No preferences to update")
+		return
+	}
+	if params.SensitiveContent != nil && !slices.Contains(sensitiveContentModes, *params.SensitiveContent) {
+		respondWithError(rw, http.StatusBadRequest, "sensitive_content must be show, collapse or hide")
+		return
+	}
+
+	tx, err := cfg.db.BeginTx(r.Context(), nil)
+	if err != nil {
+		respondWithError(rw, http.StatusInternalServerError, "Something went wrong saving preferences")
+		return
+	}
+	defer tx.Rollback()
+	q := cfg.queries.WithTx(tx)
+
+	user, err := q.SelectUserById(r.Context(), uidtok)
+	if err != nil {
+		respondWithError(rw, http.StatusInternalServerError, "Something went wrong saving preferences")
+		return
+	}
+	if params.SensitiveContent != nil {
+		user, err = q.UpdateSensitiveContentPreference(r.Context(), database.UpdateSensitiveContentPreferenceParams{SensitiveContent: *params.SensitiveContent, ID: uidtok})
+		if err != nil {
+			respondWithError(rw, http.StatusInternalServerError, "Something went wrong saving preferences")
+			return
+		}
+	}
+	if params.IsProtected != nil && *params.IsProtected != user.IsProtected {
+		user, err = setProtected(r.Context(), q, uidtok, *params.IsProtected)
+		if err != nil {
+			respondWithError(rw, http.StatusInternalServerError, "Something went wrong saving preferences")
+			return
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		respondWithError(rw, http.StatusInternalServerError, "Something went wrong saving preferences")
+		return
+	}
+	respondWithJSON(rw, http.StatusOK, responseJson{SensitiveContent: user.SensitiveContent, IsProtected: user.IsProtected})
+}
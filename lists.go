@@ -0,0 +1,359 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/Serux/chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+const maxListNameLength = 25
+const maxListMembers = 500
+
+type listJson struct {
+	Id        string `json:"id"`
+	CreatedAt string `json:"created_at"`
+	UpdatedAt string `json:"updated_at"`
+	OwnerId   string `json:"owner_id"`
+	Name      string `json:"name"`
+	IsPrivate bool   `json:"is_private"`
+}
+
+type listMemberJson struct {
+	UserId  string `json:"user_id"`
+	Handle  string `json:"handle"`
+	AddedAt string `json:"added_at"`
+}
+
+func listToJson(l database.List) listJson {
+	return listJson{
+		Id:        l.ID.String(),
+		CreatedAt: l.CreatedAt.Format(time.RFC3339),
+		UpdatedAt: l.UpdatedAt.Format(time.RFC3339),
+		OwnerId:   l.OwnerID.String(),
+		Name:      l.Name,
+		IsPrivate: l.IsPrivate,
+	}
+}
+
+// visibleList loads the {listID} of the request as seen by viewer, writing
+// the error response if there is none. Private lists only exist for their
+// owner.
+func (cfg *apiConfig) visibleList(rw http.ResponseWriter, r *http.Request, viewer uuid.UUID) (database.List, bool) {
+	listId, err := uuid.Parse(r.PathValue("listID"))
+	if err != nil {
+		respondWithError(rw, http.StatusNotFound, "List not found")
+		return database.List{}, false
+	}
+	list, err := cfg.queries.SelectOneList(r.Context(), listId)
+	if errors.Is(err, sql.ErrNoRows) || (err == nil && list.IsPrivate && list.OwnerID != viewer) {
+		respondWithError(rw, http.StatusNotFound, "List not found")
+		return database.List{}, false
+	}
+	if err != nil {
+		respondWithError(rw, http.StatusInternalServerError, "Something went wrong getting list")
+		return database.List{}, false
+	}
+	return list, true
+}
+
+// ownedList is visibleList for endpoints only the owner may use.
+func (cfg *apiConfig) ownedList(rw http.ResponseWriter, r *http.Request, viewer uuid.UUID) (database.List, bool) {
+	list, ok := cfg.visibleList(rw, r, viewer)
+	if !ok {
+		return database.List{}, false
+	}
+	if list.OwnerID != viewer {
+		respondWithError(rw, http.StatusForbidden, "Only the owner can change a list")
+		return database.List{}, false
+	}
+	return list, true
+}
+
+func (cfg *apiConfig) postListsHandler(rw http.ResponseWriter, r *http.Request) {
+	type requestJson struct {
+		Name      string `json:"name"`
+		IsPrivate bool   `json:"is_private"`
+	}
+
+	uidtok, err := cfg.authenticatedUserId(r)
+	if err != nil {
+		respondWithError(rw, http.StatusUnauthorized, "Something went wrong validating JWT")
+		return
+	}
+	decoder := json.NewDecoder(r.Body)
+	params := requestJson{}
+	err = decoder.Decode(&params)
+	if err != nil {
+		respondWithError(rw, http.StatusBadRequest, "Something went wrong decoding input")
+		return
+	}
+	name := strings.TrimSpace(params.Name)
+	if name == "" || utf8.RuneCountInString(name) > maxListNameLength {
+		respondWithError(rw, http.StatusBadRequest, fmt.Sprintf("List names must be between 1 and %d characters", maxListNameLength))
+		return
+	}
+
+	list, err := cfg.queries.CreateList(r.Context(), database.CreateListParams{OwnerID: uidtok, Name: removeProfanity(name), IsPrivate: params.IsPrivate})
+	if err != nil {
+		respondWithError(rw, http.StatusInternalServerError, "Something went wrong creating list")
+		return
+	}
+	respondWithJSON(rw, http.StatusCreated, listToJson(list))
+}
+
+// getListsHandler returns the user's own lists followed by the public lists
+// they subscribed to.
+func (cfg *apiConfig) getListsHandler(rw http.ResponseWriter, r *http.Request) {
+	uidtok, err := cfg.authenticatedUserId(r)
+	if err != nil {
+		respondWithError(rw, http.StatusUnauthorized, "Something went wrong validating JWT")
+		return
+	}
+
+	lists, err := cfg.queries.SelectListsUser(r.Context(), uidtok)
+	if err != nil {
+		respondWithError(rw, http.StatusInternalServerError, "Something went wrong getting lists")
+		return
+	}
+	ret := []listJson{}
+	for _, l := range lists {
+		ret = append(ret, listToJson(l))
+	}
+	respondWithJSON(rw, http.StatusOK, ret)
+}
+
+func (cfg *apiConfig) getListHandler(rw http.ResponseWriter, r *http.Request) {
+	list, ok := cfg.visibleList(rw, r, cfg.viewerId(r))
+	if !ok {
+		return
+	}
+	respondWithJSON(rw, http.StatusOK, listToJson(list))
+}
+
+func (cfg *apiConfig) deleteListHandler(rw http.ResponseWriter, r *http.Request) {
+	uidtok, err := cfg.authenticatedUserId(r)
+	if err != nil {
+		respondWithError(rw, http.StatusUnauthorized, "Something went wrong validating JWT")
+		return
+	}
+	list, ok := cfg.ownedList(rw, r, uidtok)
+	if !ok {
+		return
+	}
+
+	_, err = cfg.queries.DeleteList(r.Context(), database.DeleteListParams{ID: list.ID, OwnerID: uidtok})
+	if err != nil {
+		respondWithError(rw, http.StatusInternalServerError, "Something went wrong deleting list")
+		return
+	}
+	rw.WriteHeader(http.StatusNoContent)
+}
+
+func (cfg *apiConfig) getListMembersHandler(rw http.ResponseWriter, r *http.Request) {
+	list, ok := cfg.visibleList(rw, r, cfg.viewerId(r))
+	if !ok {
+		return
+	}
+
+	members, err := cfg.queries.SelectListMembers(r.Context(), list.ID)
+	if err != nil {
+		respondWithError(rw, http.StatusInternalServerError, "Something went wrong getting list members")
+		return
+	}
+	ret := []listMemberJson{}
+	for _, m := range members {
+		ret = append(ret, listMemberJson{
+			UserId:  m.User.ID.String(),
+			Handle:  m.User.Handle.String,
+			AddedAt: m.AddedAt.Format(time.RFC3339),
+		})
+	}
+	respondWithJSON(rw, http.StatusOK, ret)
+}
+
+// postListMemberHandler adds a user to a list. Adding somebody already on
+// the list succeeds without doing anything; a full list answers 409.
+func (cfg *apiConfig) postListMemberHandler(rw http.ResponseWriter, r *http.Request) {
+	uidtok, err := cfg.authenticatedUserId(r)
+	if err != nil {
+		respondWithError(rw, http.StatusUnauthorized, "Something went wrong validating JWT")
+		return
+	}
+	list, ok := cfg.ownedList(rw, r, uidtok)
+	if !ok {
+		return
+	}
+	member, ok := cfg.targetUser(rw, r)
+	if !ok {
+		return
+	}
+
+	// AddListMember counts the members before adding one, so two requests at
+	// once would both see room for it without the lock.
+	tx, err := cfg.db.BeginTx(r.Context(), nil)
+	if err != nil {
+		respondWithError(rw, http.StatusInternalServerError, "Something went wrong adding list member")
+		return
+	}
+	defer tx.Rollback()
+	q := cfg.queries.WithTx(tx)
+
+	err = q.LockList(r.Context(), list.ID)
+	if err != nil {
+		respondWithError(rw, http.StatusInternalServerError, "Something went wrong adding list member")
+		return
+	}
+	added, err := q.AddListMember(r.Context(), database.AddListMemberParams{ListID: list.ID, UserID: member.ID, MaxMembers: maxListMembers})
+	if err != nil {
+		respondWithError(rw, http.StatusInternalServerError, "Something went wrong adding list member")
+		return
+	}
+	if added == 0 {
+		// Either already a member or the list is full.
+		isMember, err := q.IsListMember(r.Context(), database.IsListMemberParams{ListID: list.ID, UserID: member.ID})
+		if err != nil {
+			respondWithError(rw, http.StatusInternalServerError, "Something went wrong adding list member")
+			return
+		}
+		if !isMember {
+			respondWithError(rw, http.StatusConflict, fmt.Sprintf("Lists can have at most %d members", maxListMembers))
+			return
+		}
+	}
+	err = tx.Commit()
+	if err != nil {
+		respondWithError(rw, http.StatusInternalServerError, "Something went wrong adding list member")
+		return
+	}
+	rw.WriteHeader(http.StatusNoContent)
+}
+
+func (cfg *apiConfig) deleteListMemberHandler(rw http.ResponseWriter, r *http.Request) {
+	uidtok, err := cfg.authenticatedUserId(r)
+	if err != nil {
+		respondWithError(rw, http.StatusUnauthorized, "Something went wrong validating JWT")
+		return
+	}
+	list, ok := cfg.ownedList(rw, r, uidtok)
+	if !ok {
+		return
+	}
+	memberId, err := uuid.Parse(r.PathValue("userID"))
+	if err != nil {
+		respondWithError(rw, http.StatusNotFound, "User not found")
+		return
+	}
+
+	_, err = cfg.queries.RemoveListMember(r.Context(), database.RemoveListMemberParams{ListID: list.ID, UserID: memberId})
+	if err != nil {
+		respondWithError(rw, http.StatusInternalServerError, "Something went wrong removing list member")
+		return
+	}
+	rw.WriteHeader(http.StatusNoContent)
+}
+
+// postListSubscriptionHandler subscribes the user to somebody else's public
+// list, so it shows up in their GET /api/lists.
+func (cfg *apiConfig) postListSubscriptionHandler(rw http.ResponseWriter, r *http.Request) {
+	uidtok, err := cfg.authenticatedUserId(r)
+	if err != nil {
+		respondWithError(rw, http.StatusUnauthorized, "Something went wrong validating JWT")
+		return
+	}
+	list, ok := cfg.visibleList(rw, r, uidtok)
+	if !ok {
+		return
+	}
+	if list.OwnerID == uidtok {
+		respondWithError(rw, http.StatusBadRequest, "You can't subscribe to your own list")
+		return
+	}
+
+	_, err = cfg.queries.SubscribeList(r.Context(), database.SubscribeListParams{ListID: list.ID, UserID: uidtok})
+	if err != nil {
+		respondWithError(rw, http.StatusInternalServerError, "Something went wrong subscribing to list")
+		return
+	}
+	rw.WriteHeader(http.StatusNoContent)
+}
+
+func (cfg *apiConfig) deleteListSubscriptionHandler(rw http.ResponseWriter, r *http.Request) {
+	uidtok, err := cfg.authenticatedUserId(r)
+	if err != nil {
+		respondWithError(rw, http.StatusUnauthorized, "Something went wrong validating JWT")
+		return
+	}
+	listId, err := uuid.Parse(r.PathValue("listID"))
+	if err != nil {
+		respondWithError(rw, http.StatusNotFound, "List not found")
+		return
+	}
+
+	_, err = cfg.queries.UnsubscribeList(r.Context(), database.UnsubscribeListParams{ListID: listId, UserID: uidtok})
+	if err != nil {
+		respondWithError(rw, http.StatusInternalServerError, "Something went wrong unsubscribing from list")
+		return
+	}
+	rw.WriteHeader(http.StatusNoContent)
+}
+
+// getListTimelineHandler serves the chirps of a list's members newest first,
+// in the same shape and with the same cursors as the home timeline.
+func (cfg *apiConfig) getListTimelineHandler(rw http.ResponseWriter, r *http.Request) {
+	viewer := cfg.viewerId(r)
+	list, ok := cfg.visibleList(rw, r, viewer)
+	if !ok {
+		return
+	}
+	limit, err := pageLimit(r)
+	if err != nil {
+		respondWithError(rw, http.StatusBadRequest, err.Error())
+		return
+	}
+	params := database.SelectListTimelinePageParams{ListID: list.ID, ViewerID: viewer, MaxResults: int32(limit + 1)}
+	if r.URL.Query().Has("cursor") {
+		cursor, err := parseChirpCursor(r.URL.Query().Get("cursor"))
+		if err != nil || cursor.backwards {
+			respondWithError(rw, http.StatusBadRequest, "Invalid cursor")
+			return
+		}
+		params.CursorCreatedAt = sql.NullTime{Time: cursor.createdAt, Valid: true}
+		params.CursorID = cursor.id
+	}
+	sensitiveContent, err := cfg.sensitiveContentPreference(r.Context(), viewer)
+	if err != nil {
+		respondWithError(rw, http.StatusInternalServerError, "Something went wrong getting preferences")
+		return
+	}
+	params.HideSensitive = sensitiveContent == "hide"
+
+	chirps, err := cfg.queries.SelectListTimelinePage(r.Context(), params)
+	if err != nil {
+		respondWithError(rw, http.StatusInternalServerError, "Something went wrong getting list timeline")
+		return
+	}
+	hasMore := len(chirps) > limit
+	if hasMore {
+		chirps = chirps[:limit]
+	}
+
+	ret, err := cfg.chirpsToJson(r.Context(), viewer, chirps)
+	if err != nil {
+		respondWithError(rw, http.StatusInternalServerError, "Something went wrong loading chirps")
+		return
+	}
+	cfg.recordImpressions(viewer, ret)
+
+	if hasMore {
+		setPageLinks(rw, r, chirpCursor(chirps[len(chirps)-1], false), "")
+	}
+	respondWithJSON(rw, http.StatusOK, ret)
+}
@@ -1,18 +1,26 @@
 package main
 
 import (
+	"context"
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
+	"net/url"
 	"os"
-	"sort"
+	"os/signal"
+	"slices"
+	"strconv"
 	"strings"
+	"sync"
 	"sync/atomic"
+	"syscall"
 	"time"
 
 	//	"github.com/Serux/chirpy/internal/auth"
 	"github.com/Serux/chirpy/internal/auth"
+	"github.com/Serux/chirpy/internal/blob"
 	"github.com/Serux/chirpy/internal/database"
 	"github.com/google/uuid"
 	"github.com/joho/godotenv"
@@ -23,23 +31,143 @@ type apiConfig struct {
 	fileserverHits atomic.Int32
 	jwtSecret      string
 	polkaKey       string
+	db             *sql.DB
 	queries        *database.Queries
+	blobs          blob.BlobStore
+	trends         trendsCache
+	views          viewCounter
 }
 
 type fullChirpJsonDb struct {
-	Id        string `json:"id"`
-	CreatedAt string `json:"created_at"`
-	UpdatedAt string `json:"updated_at"`
-	Body      string `json:"body"`
-	UserId    string `json:"user_id"`
+	Id             string            `json:"id"`
+	CreatedAt      string            `json:"created_at"`
+	UpdatedAt      string            `json:"updated_at"`
+	Body           string            `json:"body"`
+	UserId         string            `json:"user_id"`
+	ReplyToId      string            `json:"reply_to_id,omitempty"`
+	Visibility     string            `json:"visibility"`
+	PublishAt      string            `json:"publish_at,omitempty"`
+	DeletedAt      string            `json:"deleted_at,omitempty"`
+	Entities       chirpEntitiesJson `json:"entities"`
+	Media          []mediaJson       `json:"media"`
+	Poll           *pollJson         `json:"poll,omitempty"`
+	Pinned         bool              `json:"pinned,omitempty"`
+	ContentWarning string            `json:"content_warning,omitempty"`
+	Sensitive      bool              `json:"sensitive"`
+	Collapsed      bool              `json:"collapsed,omitempty"`
 }
 
 type userMailJsonDb struct {
-	Id          string `json:"id"`
-	CreatedAt   string `json:"created_at"`
-	UpdatedAt   string `json:"updated_at"`
-	Email       string `json:"email"`
-	IsChirpyRed bool   `json:"is_chirpy_red"`
+	Id             string `json:"id"`
+	CreatedAt      string `json:"created_at"`
+	UpdatedAt      string `json:"updated_at"`
+	Email          string `json:"email"`
+	Handle         string `json:"handle"`
+	DisplayName    string `json:"display_name"`
+	Bio            string `json:"bio"`
+	AvatarUrl      string `json:"avatar_url,omitempty"`
+	IsChirpyRed    bool   `json:"is_chirpy_red"`
+	FollowersCount int64  `json:"followers_count"`
+	FollowingCount int64  `json:"following_count"`
+}
+
+// chirpsToJson builds the API representation of chirps as seen by viewer
+// (uuid.Nil when anonymous), loading the media and polls of the whole batch
+// with a single query each.
+func (cfg *apiConfig) chirpsToJson(ctx context.Context, viewer uuid.UUID, chirps []database.Chirp) ([]fullChirpJsonDb, error) {
+	ids := []uuid.UUID{}
+	for _, ch := range chirps {
+		ids = append(ids, ch.ID)
+	}
+	chirpMedia, err := cfg.queries.SelectMediaForChirps(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	mediaByChirp := map[uuid.UUID][]mediaJson{}
+	for _, m := range chirpMedia {
+		mediaByChirp[m.ChirpID] = append(mediaByChirp[m.ChirpID], mediaToJson(m.Medium))
+	}
+
+	polls, err := cfg.pollsForChirps(ctx, viewer, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	sensitiveContent, err := cfg.sensitiveContentPreference(ctx, viewer)
+	if err != nil {
+		return nil, err
+	}
+
+	ret := []fullChirpJsonDb{}
+	for _, ch := range chirps {
+		entities, err := decodeChirpEntities(ch.Entities)
+		if err != nil {
+			return nil, err
+		}
+		chMedia := mediaByChirp[ch.ID]
+		if chMedia == nil {
+			chMedia = []mediaJson{}
+		}
+		chJson := fullChirpJsonDb{
+			Id:         ch.ID.String(),
+			CreatedAt:  ch.CreatedAt.Format(time.RFC3339),
+			UpdatedAt:  ch.UpdatedAt.Format(time.RFC3339),
+			Body:       ch.Body,
+			UserId:     ch.UserID.String(),
+			Visibility: ch.Visibility,
+			Entities:   entities,
+			Media:      chMedia,
+			Poll:       polls[ch.ID],
+		}
+		if ch.ReplyToID.Valid {
+			chJson.ReplyToId = ch.ReplyToID.UUID.String()
+		}
+		if ch.Status == "scheduled" {
+			chJson.PublishAt = ch.PublishAt.Time.Format(time.RFC3339)
+		}
+		if ch.ContentWarning.Valid {
+			chJson.ContentWarning = ch.ContentWarning.String
+		}
+		chJson.Sensitive = ch.Sensitive
+		if (ch.Sensitive || ch.ContentWarning.Valid) && ch.UserID != viewer && sensitiveContent != "show" {
+			chJson.Collapsed = true
+		}
+		if ch.DeletedAt.Valid {
+			chJson.DeletedAt = ch.DeletedAt.Time.Format(time.RFC3339)
+		}
+		ret = append(ret, chJson)
+	}
+	return ret, nil
+}
+
+// authenticatedUserId returns the user the request's bearer JWT belongs to.
+func (cfg *apiConfig) authenticatedUserId(r *http.Request) (uuid.UUID, error) {
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		return uuid.UUID{}, err
+	}
+	return auth.ValidateJWT(token, cfg.jwtSecret)
+}
+
+// authenticatedSession is authenticatedUserId that also returns the login
+// session the access token belongs to, uuid.Nil for tokens issued before
+// sessions were tracked.
+func (cfg *apiConfig) authenticatedSession(r *http.Request) (uuid.UUID, uuid.UUID, error) {
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		return uuid.UUID{}, uuid.UUID{}, err
+	}
+	return auth.ValidateSessionJWT(token, cfg.jwtSecret)
+}
+
+// viewerId is the user a public endpoint is being viewed as, or uuid.Nil when
+// the request isn't authenticated.
+func (cfg *apiConfig) viewerId(r *http.Request) uuid.UUID {
+	uid, err := cfg.authenticatedUserId(r)
+	if err != nil {
+		return uuid.Nil
+	}
+	return uid
 }
 
 func (cfg *apiConfig) middlewareMetricsInc(next http.Handler) http.Handler {
@@ -85,6 +213,7 @@ func (cfg *apiConfig) postUsersHandler(rw http.ResponseWriter, r *http.Request)
 	type requestJson struct {
 		Email    string `json:"email"`
 		Password string `json:"password"`
+		Handle   string `json:"handle"`
 	}
 
 	decoder := json.NewDecoder(r.Body)
@@ -95,8 +224,20 @@ func (cfg *apiConfig) postUsersHandler(rw http.ResponseWriter, r *http.Request)
 		respondWithError(rw, http.StatusInternalServerError, "Something went wrong decoding input")
 		return
 	}
+	if params.Handle != "" && !handleRegexp.MatchString(params.Handle) {
+		respondWithError(rw, http.StatusBadRequest, "Handle must be 1 to 30 letters, digits or underscores")
+		return
+	}
+	if reservedEmail(params.Email) {
+		respondWithError(rw, http.StatusBadRequest, "Email is reserved")
+		return
+	}
 
-	user, err := cfg.queries.CreateUser(r.Context(), database.CreateUserParams{Email: params.Email, HashedPassword: params.Password})
+	user, err := cfg.queries.CreateUser(r.Context(), database.CreateUserParams{
+		Email:          params.Email,
+		HashedPassword: params.Password,
+		Handle:         sql.NullString{String: params.Handle, Valid: params.Handle != ""},
+	})
 	if err != nil {
 		respondWithError(rw, http.StatusInternalServerError, "Something went wrong creating user")
 		return
@@ -107,25 +248,52 @@ func (cfg *apiConfig) postUsersHandler(rw http.ResponseWriter, r *http.Request)
 		CreatedAt:   user.CreatedAt.Format(time.RFC3339),
 		UpdatedAt:   user.UpdatedAt.Format(time.RFC3339),
 		Email:       user.Email,
+		Handle:      user.Handle.String,
 		IsChirpyRed: user.IsChirpyRed,
 	}
 
 	respondWithJSON(rw, http.StatusCreated, ret)
 }
 
+// putUsersHandler replaces both the email and the password. PATCH
+// /api/users/me can change them one at a time.
 func (cfg *apiConfig) putUsersHandler(rw http.ResponseWriter, r *http.Request) {
 	type requestJson struct {
 		Email    string `json:"email"`
 		Password string `json:"password"`
 	}
 
+	decoder := json.NewDecoder(r.Body)
+	params := requestJson{}
+
+	err := decoder.Decode(&params)
+	if err != nil {
+		respondWithError(rw, http.StatusInternalServerError, "Something went wrong decoding input")
+		return
+	}
+
+	cfg.updateAccount(rw, r, accountChangesJson{Email: &params.Email, Password: &params.Password}, false)
+}
+
+func (cfg *apiConfig) postChirpsHandler(rw http.ResponseWriter, r *http.Request) {
+	type requestJson struct {
+		Body       string           `json:"body"`
+		ReplyToId  string           `json:"reply_to_id"`
+		MediaIds   []string         `json:"media_ids"`
+		PublishAt  string           `json:"publish_at"`
+		Poll       *pollRequestJson `json:"poll"`
+		Visibility string           `json:"visibility"`
+
+		ContentWarning string `json:"content_warning"`
+		Sensitive      bool   `json:"sensitive"`
+	}
+
 	token, err := auth.GetBearerToken(r.Header)
 	if err != nil {
 		respondWithError(rw, http.StatusUnauthorized, "Something went wrong geting JWT")
 		return
 	}
 	uidtok, err := auth.ValidateJWT(token, cfg.jwtSecret)
-
 	if err != nil {
 		respondWithError(rw, http.StatusUnauthorized, "Something went wrong validating JWT")
 		return
@@ -140,107 +308,326 @@ func (cfg *apiConfig) putUsersHandler(rw http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	user, err := cfg.queries.UpdateUserMailPassByUUID(r.Context(), database.UpdateUserMailPassByUUIDParams{Email: params.Email, HashedPassword: params.Password, ID: uidtok})
+	replyTo := uuid.NullUUID{}
+	if params.ReplyToId != "" {
+		parentId, err := uuid.Parse(params.ReplyToId)
+		if err != nil {
+			respondWithError(rw, http.StatusBadRequest, "Invalid reply_to_id")
+			return
+		}
+		parent, err := cfg.queries.SelectOneChirps(r.Context(), database.SelectOneChirpsParams{ID: parentId, ViewerID: uidtok})
+		if err != nil {
+			respondWithError(rw, http.StatusNotFound, "Chirp to reply to not found")
+			return
+		}
+		replyTo = uuid.NullUUID{UUID: parent.ID, Valid: true}
+	}
+
+	body, err := cleanChirpBody(params.Body)
 	if err != nil {
-		respondWithError(rw, http.StatusInternalServerError, "Something went wrong creating user")
+		respondWithError(rw, http.StatusBadRequest, err.Error())
 		return
 	}
 
-	ret := userMailJsonDb{
-		Id:          user.ID.String(),
-		CreatedAt:   user.CreatedAt.Format(time.RFC3339),
-		UpdatedAt:   user.UpdatedAt.Format(time.RFC3339),
-		Email:       user.Email,
-		IsChirpyRed: user.IsChirpyRed,
+	visibility, err := parseChirpVisibility(params.Visibility)
+	if err != nil {
+		respondWithError(rw, http.StatusBadRequest, err.Error())
+		return
 	}
 
-	respondWithJSON(rw, http.StatusOK, ret)
-}
+	contentWarning, err := parseContentWarning(params.ContentWarning)
+	if err != nil {
+		respondWithError(rw, http.StatusBadRequest, err.Error())
+		return
+	}
 
-func (cfg *apiConfig) postChirpsHandler(rw http.ResponseWriter, r *http.Request) {
-	type requestJson struct {
-		Body string `json:"body"`
+	publishAt := sql.NullTime{}
+	if params.PublishAt != "" {
+		t, err := parsePublishAt(params.PublishAt)
+		if err != nil {
+			respondWithError(rw, http.StatusBadRequest, err.Error())
+			return
+		}
+		publishAt = sql.NullTime{Time: t, Valid: true}
 	}
 
-	token, err := auth.GetBearerToken(r.Header)
+	var pollOptions []string
+	var pollClosesAt time.Time
+	if params.Poll != nil {
+		opensAt := time.Now()
+		if publishAt.Valid {
+			opensAt = publishAt.Time
+		}
+		pollOptions, pollClosesAt, err = parsePoll(*params.Poll, opensAt)
+		if err != nil {
+			respondWithError(rw, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+
+	attachments, err := cfg.selectAttachableMedia(r.Context(), uidtok, params.MediaIds)
 	if err != nil {
-		respondWithError(rw, http.StatusUnauthorized, "Something went wrong geting JWT")
+		respondWithError(rw, http.StatusBadRequest, err.Error())
 		return
 	}
-	uidtok, err := auth.ValidateJWT(token, cfg.jwtSecret)
+
+	// The chirp, its media, poll and index go in together, so a failure
+	// halfway doesn't leave a published chirp missing some of them.
+	tx, err := cfg.db.BeginTx(r.Context(), nil)
 	if err != nil {
-		respondWithError(rw, http.StatusUnauthorized, "Something went wrong validating JWT")
+		respondWithError(rw, http.StatusInternalServerError, "Something went wrong creating chirp")
 		return
 	}
+	defer tx.Rollback()
+	q := cfg.queries.WithTx(tx)
 
-	decoder := json.NewDecoder(r.Body)
-	params := requestJson{}
+	chirp, err := q.CreateChirp(r.Context(), database.CreateChirpParams{Body: body, UserID: uidtok, ReplyToID: replyTo, PublishAt: publishAt, Visibility: visibility, ContentWarning: contentWarning, Sensitive: params.Sensitive})
+	if err != nil {
+		respondWithError(rw, http.StatusInternalServerError, "Something went wrong creating user")
+		return
+	}
 
-	err = decoder.Decode(&params)
+	for i, m := range attachments {
+		err = q.AttachChirpMedia(r.Context(), database.AttachChirpMediaParams{ChirpID: chirp.ID, MediaID: m.ID, Position: int32(i)})
+		if err != nil {
+			respondWithError(rw, http.StatusInternalServerError, "Something went wrong attaching media")
+			return
+		}
+	}
+
+	if pollOptions != nil {
+		err = createPoll(r.Context(), q, chirp.ID, pollOptions, pollClosesAt)
+		if err != nil {
+			respondWithError(rw, http.StatusInternalServerError, "Something went wrong creating poll")
+			return
+		}
+	}
+
+	// Scheduled chirps are indexed by the scheduler when they get published,
+	// so nobody is notified about a chirp they can't see yet.
+	if chirp.Status == "published" {
+		chirp, err = indexChirpEntities(r.Context(), q, chirp)
+		if err != nil {
+			respondWithError(rw, http.StatusInternalServerError, "Something went wrong indexing chirp")
+			return
+		}
+	}
+
+	err = tx.Commit()
 	if err != nil {
-		respondWithError(rw, http.StatusInternalServerError, "Something went wrong decoding input")
+		respondWithError(rw, http.StatusInternalServerError, "Something went wrong creating chirp")
 		return
 	}
 
-	chirp, err := cfg.queries.CreateChirp(r.Context(), database.CreateChirpParams{Body: params.Body, UserID: uidtok})
+	ret, err := cfg.chirpsToJson(r.Context(), uidtok, []database.Chirp{chirp})
 	if err != nil {
-		respondWithError(rw, http.StatusInternalServerError, "Something went wrong creating user")
+		respondWithError(rw, http.StatusInternalServerError, "Something went wrong loading chirp")
 		return
 	}
 
-	ret := fullChirpJsonDb{
-		Id:        chirp.ID.String(),
-		CreatedAt: chirp.CreatedAt.Format(time.RFC3339),
-		UpdatedAt: chirp.UpdatedAt.Format(time.RFC3339),
-		Body:      chirp.Body,
-		UserId:    chirp.UserID.String(),
+	respondWithJSON(rw, http.StatusCreated, ret[0])
+}
+
+var chirpListParams = []string{"sort", "limit", "cursor", "author_id", "tag", "since", "until", "exclude_replies", "has_media", "contains", "include_pins"}
+
+const maxAuthorFilters = 50
+const maxContainsLength = 140
+
+// parseChirpFilters validates the filters of GET /api/chirps. Unknown
+// parameters are rejected rather than ignored so typos don't silently return
+// unfiltered chirps.
+func parseChirpFilters(query url.Values) (database.SelectChirpsPageAscParams, error) {
+	params := database.SelectChirpsPageAscParams{AuthorIds: []uuid.UUID{}}
+
+	for name := range query {
+		if !slices.Contains(chirpListParams, name) {
+			return params, fmt.Errorf("Unknown query parameter %q", name)
+		}
 	}
 
-	respondWithJSON(rw, http.StatusCreated, ret)
+	for _, value := range query["author_id"] {
+		for _, id := range strings.Split(value, ",") {
+			authorId, err := uuid.Parse(strings.TrimSpace(id))
+			if err != nil {
+				return params, fmt.Errorf("Invalid author_id %q", id)
+			}
+			params.AuthorIds = append(params.AuthorIds, authorId)
+		}
+	}
+	if len(params.AuthorIds) > maxAuthorFilters {
+		return params, fmt.Errorf("At most %d author_id values are allowed", maxAuthorFilters)
+	}
+
+	if query.Has("tag") {
+		params.Tag = sql.NullString{String: normalizeHashtag(query.Get("tag")), Valid: true}
+	}
+
+	if query.Has("since") {
+		since, err := parseTimeBound(query.Get("since"), false)
+		if err != nil {
+			return params, fmt.Errorf("since must be a date (YYYY-MM-DD) or RFC3339 time")
+		}
+		params.Since = sql.NullTime{Time: since, Valid: true}
+	}
+	if query.Has("until") {
+		until, err := parseTimeBound(query.Get("until"), true)
+		if err != nil {
+			return params, fmt.Errorf("until must be a date (YYYY-MM-DD) or RFC3339 time")
+		}
+		params.Until = sql.NullTime{Time: until, Valid: true}
+	}
+	if params.Since.Valid && params.Until.Valid && !params.Since.Time.Before(params.Until.Time) {
+		return params, fmt.Errorf("since must be before until")
+	}
+
+	if query.Has("exclude_replies") {
+		excludeReplies, err := strconv.ParseBool(query.Get("exclude_replies"))
+		if err != nil {
+			return params, fmt.Errorf("exclude_replies must be true or false")
+		}
+		params.ExcludeReplies = excludeReplies
+	}
+	if query.Has("has_media") {
+		hasMedia, err := strconv.ParseBool(query.Get("has_media"))
+		if err != nil {
+			return params, fmt.Errorf("has_media must be true or false")
+		}
+		params.HasMedia = sql.NullBool{Bool: hasMedia, Valid: true}
+	}
+
+	if query.Has("contains") {
+		contains := query.Get("contains")
+		if contains == "" || len([]rune(contains)) > maxContainsLength {
+			return params, fmt.Errorf("contains must be 1 to %d characters", maxContainsLength)
+		}
+		// The text is matched literally, so LIKE wildcards have to be escaped.
+		escaped := strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(contains)
+		params.Contains = sql.NullString{String: escaped, Valid: true}
+	}
+
+	return params, nil
 }
+
 func (cfg *apiConfig) getChirpsHandler(rw http.ResponseWriter, r *http.Request) {
 	query := r.URL.Query()
-	var chirp []database.Chirp
-	var err error
+
 	sortString := "asc"
 	if query.Has("sort") {
 		sortString = query.Get("sort")
 	}
+	if sortString != "asc" && sortString != "desc" {
+		respondWithError(rw, http.StatusBadRequest, "sort must be asc or desc")
+		return
+	}
 
-	if query.Has("author_id") {
-		chirp, err = cfg.queries.SelectAllChirpsUser(r.Context(), uuid.MustParse(query.Get("author_id")))
+	limit, err := pageLimit(r)
+	if err != nil {
+		respondWithError(rw, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	params, err := parseChirpFilters(query)
+	if err != nil {
+		respondWithError(rw, http.StatusBadRequest, err.Error())
+		return
+	}
+	params.ViewerID = cfg.viewerId(r)
+	sensitiveContent, err := cfg.sensitiveContentPreference(r.Context(), params.ViewerID)
+	if err != nil {
+		respondWithError(rw, http.StatusInternalServerError, "Something went wrong getting preferences")
+		return
+	}
+	params.HideSensitive = sensitiveContent == "hide"
+
+	// Pins are shown above the first page of a single author's chirps.
+	includePins := false
+	if query.Has("include_pins") {
+		includePins, err = strconv.ParseBool(query.Get("include_pins"))
 		if err != nil {
-			respondWithError(rw, http.StatusInternalServerError, "Something went wrong creating user")
+			respondWithError(rw, http.StatusBadRequest, "include_pins must be true or false")
 			return
 		}
-	} else {
-		chirp, err = cfg.queries.SelectAllChirps(r.Context())
+		if includePins && len(params.AuthorIds) != 1 {
+			respondWithError(rw, http.StatusBadRequest, "include_pins needs exactly one author_id")
+			return
+		}
+	}
+
+	// One extra row tells us whether there is another page in that direction.
+	params.MaxResults = int32(limit + 1)
+
+	backwards := false
+	if query.Has("cursor") {
+		cursor, err := parseChirpCursor(query.Get("cursor"))
 		if err != nil {
-			respondWithError(rw, http.StatusInternalServerError, "Something went wrong creating user")
+			respondWithError(rw, http.StatusBadRequest, "Invalid cursor")
 			return
 		}
+		params.CursorCreatedAt = sql.NullTime{Time: cursor.createdAt, Valid: true}
+		params.CursorID = cursor.id
+		backwards = cursor.backwards
 	}
 
-	ret := []fullChirpJsonDb{}
-	//ret2:= slices.SortedFunc(ret,func(fcjd1, fcjd2 fullChirpJsonDb) int {strings.Compare(fcjd1.CreatedAt, fcjd2.CreatedAt)})
-	//chirp2 := slices.SortedFunc[database.Chirp](chirp,func(c1, c2 database.Chirp) int {})
-	var sortfun func(i, j int) bool
-	fmt.Println(sortString)
-	if sortString == "asc" {
-		sortfun = func(i, j int) bool { return chirp[i].CreatedAt.Compare(chirp[j].CreatedAt) < 0 }
+	// Going back a page walks the index the other way and flips the rows afterwards.
+	var chirp []database.Chirp
+	if (sortString == "asc") != backwards {
+		chirp, err = cfg.queries.SelectChirpsPageAsc(r.Context(), params)
 	} else {
-		sortfun = func(i, j int) bool { return chirp[i].CreatedAt.Compare(chirp[j].CreatedAt) > 0 }
-	}
-	sort.Slice(chirp, sortfun)
-	//slices.SortFunc(chirp,sortfun)
-	for _, ch := range chirp {
-		ret = append(ret, fullChirpJsonDb{
-			Id:        ch.ID.String(),
-			CreatedAt: ch.CreatedAt.Format(time.RFC3339),
-			UpdatedAt: ch.UpdatedAt.Format(time.RFC3339),
-			Body:      ch.Body,
-			UserId:    ch.UserID.String(),
+		chirp, err = cfg.queries.SelectChirpsPageDesc(r.Context(), database.SelectChirpsPageDescParams(params))
+	}
+	if err != nil {
+		respondWithError(rw, http.StatusInternalServerError, "Something went wrong getting chirps")
+		return
+	}
+
+	hasMore := len(chirp) > limit
+	if hasMore {
+		chirp = chirp[:limit]
+	}
+	if backwards {
+		slices.Reverse(chirp)
+	}
+
+	ret, err := cfg.chirpsToJson(r.Context(), cfg.viewerId(r), chirp)
+	if err != nil {
+		respondWithError(rw, http.StatusInternalServerError, "Something went wrong loading chirps")
+		return
+	}
+
+	// Pins go through the same filters as the list, and are taken out of the
+	// page below them so they don't show up twice.
+	if includePins && !query.Has("cursor") {
+		pins, err := cfg.pinnedChirpsToJson(r.Context(), database.SelectPinnedChirpsUserParams{
+			UserID:         params.AuthorIds[0],
+			ViewerID:       params.ViewerID,
+			HideSensitive:  params.HideSensitive,
+			Tag:            params.Tag,
+			Since:          params.Since,
+			Until:          params.Until,
+			Contains:       params.Contains,
+			ExcludeReplies: params.ExcludeReplies,
+			HasMedia:       params.HasMedia,
 		})
+		if err != nil {
+			respondWithError(rw, http.StatusInternalServerError, "Something went wrong loading pins")
+			return
+		}
+		ret = slices.DeleteFunc(ret, func(ch fullChirpJsonDb) bool {
+			return slices.ContainsFunc(pins, func(pin fullChirpJsonDb) bool { return pin.Id == ch.Id })
+		})
+		ret = append(pins, ret...)
+	}
+	cfg.recordImpressions(params.ViewerID, ret)
+
+	if len(chirp) > 0 {
+		next, prev := "", ""
+		if hasMore || backwards {
+			next = chirpCursor(chirp[len(chirp)-1], false)
+		}
+		if (hasMore && backwards) || (!backwards && query.Has("cursor")) {
+			prev = chirpCursor(chirp[0], true)
+		}
+		setPageLinks(rw, r, next, prev)
 	}
 
 	respondWithJSON(rw, http.StatusOK, ret)
@@ -254,20 +641,84 @@ func (cfg *apiConfig) getChirpHandler(rw http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	ch, err := cfg.queries.SelectOneChirps(r.Context(), uid)
+	ch, err := cfg.queries.SelectOneChirps(r.Context(), database.SelectOneChirpsParams{ID: uid, ViewerID: cfg.viewerId(r)})
 	if err != nil {
 		respondWithError(rw, http.StatusNotFound, "Chirp not found")
 		return
 	}
 
-	ret := fullChirpJsonDb{
-		Id:        ch.ID.String(),
-		CreatedAt: ch.CreatedAt.Format(time.RFC3339),
-		UpdatedAt: ch.UpdatedAt.Format(time.RFC3339),
-		Body:      ch.Body,
-		UserId:    ch.UserID.String(),
+	ret, err := cfg.chirpsToJson(r.Context(), cfg.viewerId(r), []database.Chirp{ch})
+	if err != nil {
+		respondWithError(rw, http.StatusInternalServerError, "Something went wrong loading chirp")
+		return
 	}
+	cfg.recordView(cfg.viewerId(r), ret[0])
 
+	respondWithJSON(rw, http.StatusOK, ret[0])
+}
+
+// getRepliesHandler serves a chirp's thread: the replies the viewer can see,
+// oldest first, a page at a time with a next link. A chirp the viewer can't
+// see has no thread either.
+func (cfg *apiConfig) getRepliesHandler(rw http.ResponseWriter, r *http.Request) {
+	chirpId, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		respondWithError(rw, http.StatusNotFound, "Chirp not found")
+		return
+	}
+	viewer := cfg.viewerId(r)
+	_, err = cfg.queries.SelectOneChirps(r.Context(), database.SelectOneChirpsParams{ID: chirpId, ViewerID: viewer})
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(rw, http.StatusNotFound, "Chirp not found")
+		return
+	}
+	if err != nil {
+		respondWithError(rw, http.StatusInternalServerError, "Something went wrong getting chirp")
+		return
+	}
+
+	limit, err := pageLimit(r)
+	if err != nil {
+		respondWithError(rw, http.StatusBadRequest, err.Error())
+		return
+	}
+	sensitiveContent, err := cfg.sensitiveContentPreference(r.Context(), viewer)
+	if err != nil {
+		respondWithError(rw, http.StatusInternalServerError, "Something went wrong getting preferences")
+		return
+	}
+	params := database.SelectRepliesPageParams{
+		ChirpID:       chirpId,
+		ViewerID:      viewer,
+		HideSensitive: sensitiveContent == "hide",
+		MaxResults:    int32(limit + 1),
+	}
+	if r.URL.Query().Has("cursor") {
+		cursor, err := parseChirpCursor(r.URL.Query().Get("cursor"))
+		if err != nil || cursor.backwards {
+			respondWithError(rw, http.StatusBadRequest, "Invalid cursor")
+			return
+		}
+		params.CursorCreatedAt = sql.NullTime{Time: cursor.createdAt, Valid: true}
+		params.CursorID = cursor.id
+	}
+
+	replies, err := cfg.queries.SelectRepliesPage(r.Context(), params)
+	if err != nil {
+		respondWithError(rw, http.StatusInternalServerError, "Something went wrong getting replies")
+		return
+	}
+	next := ""
+	if len(replies) > limit {
+		replies = replies[:limit]
+		next = chirpCursor(replies[limit-1], false)
+	}
+	ret, err := cfg.chirpsToJson(r.Context(), viewer, replies)
+	if err != nil {
+		respondWithError(rw, http.StatusInternalServerError, "Something went wrong loading replies")
+		return
+	}
+	setPageLinks(rw, r, next, "")
 	respondWithJSON(rw, http.StatusOK, ret)
 }
 
@@ -292,7 +743,7 @@ func (cfg *apiConfig) deleteChirpHandler(rw http.ResponseWriter, r *http.Request
 		return
 	}
 
-	ch, err := cfg.queries.SelectOneChirps(r.Context(), uid)
+	ch, err := cfg.queries.SelectOneChirps(r.Context(), database.SelectOneChirpsParams{ID: uid, ViewerID: uidtok})
 	if err != nil {
 		respondWithError(rw, http.StatusNotFound, "Chirp not found")
 		return
@@ -302,9 +753,34 @@ func (cfg *apiConfig) deleteChirpHandler(rw http.ResponseWriter, r *http.Request
 		return
 	}
 
-	err = cfg.queries.DeleteByIdChirps(r.Context(), database.DeleteByIdChirpsParams{ID: uid, UserID: uidtok})
+	// Deleted chirps go to the trash, where they can be restored until the
+	// purge worker removes them for good. Their pins go in the same
+	// transaction, so a trashed chirp never keeps a pin slot.
+	tx, err := cfg.db.BeginTx(r.Context(), nil)
 	if err != nil {
-		respondWithError(rw, 403, "Error deleting CHIRP")
+		respondWithError(rw, http.StatusInternalServerError, "Something went wrong deleting chirp")
+		return
+	}
+	defer tx.Rollback()
+	q := cfg.queries.WithTx(tx)
+
+	deleted, err := q.SoftDeleteChirp(r.Context(), database.SoftDeleteChirpParams{ID: uid, UserID: uidtok})
+	if err != nil {
+		respondWithError(rw, http.StatusInternalServerError, "Something went wrong deleting chirp")
+		return
+	}
+	if deleted == 0 {
+		respondWithError(rw, http.StatusNotFound, "Chirp not found")
+		return
+	}
+	err = q.DeleteChirpPins(r.Context(), uid)
+	if err != nil {
+		respondWithError(rw, http.StatusInternalServerError, "Something went wrong deleting chirp")
+		return
+	}
+	err = tx.Commit()
+	if err != nil {
+		respondWithError(rw, http.StatusInternalServerError, "Something went wrong deleting chirp")
 		return
 	}
 
@@ -324,6 +800,8 @@ func (cfg *apiConfig) loginHandler(rw http.ResponseWriter, r *http.Request) {
 		Token        string `json:"token"`
 		RefreshToken string `json:"refresh_token"`
 		IsChirpyRed  bool   `json:"is_chirpy_red"`
+
+		DeletionCancelled bool `json:"deletion_cancelled,omitempty"`
 	}
 
 	decoder := json.NewDecoder(r.Body)
@@ -336,7 +814,7 @@ func (cfg *apiConfig) loginHandler(rw http.ResponseWriter, r *http.Request) {
 	}
 
 	user, err := cfg.queries.SelectUserByMail(r.Context(), params.Email)
-	if err != nil {
+	if err != nil || user.ID == ghostUserId {
 		respondWithError(rw, http.StatusUnauthorized, "Incorrect email or password")
 		return
 	}
@@ -347,11 +825,13 @@ func (cfg *apiConfig) loginHandler(rw http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	expires := time.Hour
-	token, err := auth.MakeJWT(user.ID, cfg.jwtSecret, expires)
-	if err != nil {
-		respondWithError(rw, http.StatusInternalServerError, "Error creating JWT")
-		return
+	// Logging in during the grace period keeps the account.
+	if user.DeletionScheduledAt.Valid {
+		err = cfg.queries.CancelUserDeletion(r.Context(), user.ID)
+		if err != nil {
+			respondWithError(rw, http.StatusInternalServerError, "Something went wrong cancelling account deletion")
+			return
+		}
 	}
 
 	rtoken, err := auth.MakeRefreshToken()
@@ -360,12 +840,19 @@ func (cfg *apiConfig) loginHandler(rw http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	_, err = cfg.queries.InsertRefreshToken(r.Context(), database.InsertRefreshTokenParams{Token: rtoken, UserID: user.ID})
+	rt, err := cfg.queries.InsertRefreshToken(r.Context(), database.InsertRefreshTokenParams{Token: rtoken, UserID: user.ID})
 	if err != nil {
 		respondWithError(rw, http.StatusInternalServerError, "Error inserting token"+err.Error())
 		return
 	}
 
+	expires := time.Hour
+	token, err := auth.MakeSessionJWT(user.ID, rt.SessionID, cfg.jwtSecret, expires)
+	if err != nil {
+		respondWithError(rw, http.StatusInternalServerError, "Error creating JWT")
+		return
+	}
+
 	ret := responseJson{
 		Id:           user.ID.String(),
 		CreatedAt:    user.CreatedAt.Format(time.RFC3339),
@@ -374,6 +861,8 @@ func (cfg *apiConfig) loginHandler(rw http.ResponseWriter, r *http.Request) {
 		Token:        token,
 		RefreshToken: rtoken,
 		IsChirpyRed:  user.IsChirpyRed,
+
+		DeletionCancelled: user.DeletionScheduledAt.Valid,
 	}
 
 	respondWithJSON(rw, http.StatusOK, ret)
@@ -401,7 +890,7 @@ func (cfg *apiConfig) refreshHandler(rw http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	newtoken, err := auth.MakeJWT(rt.UserID, cfg.jwtSecret, time.Hour)
+	newtoken, err := auth.MakeSessionJWT(rt.UserID, rt.SessionID, cfg.jwtSecret, time.Hour)
 	if err != nil {
 		respondWithError(rw, http.StatusInternalServerError, "Error creating JWT")
 		return
@@ -479,6 +968,29 @@ func (cfg *apiConfig) postpolkaHookHandler(rw http.ResponseWriter, r *http.Reque
 	respondWithJSON(rw, 204, nil)
 }
 
+// openDB connects to Postgres with every session in UTC. TIMESTAMP columns
+// hold UTC wall-clock times, whether written with NOW() or passed from Go,
+// so they compare and fall on days the same way everywhere.
+func openDB(dbURL string) (*sql.DB, error) {
+	return sql.Open("postgres", withConnParam(dbURL, "timezone", "UTC"))
+}
+
+// withConnParam adds a setting to a URL or key=value connection string.
+// lib/pq sends settings it doesn't know itself to the server as run-time
+// parameters.
+func withConnParam(dbURL, key, value string) string {
+	if strings.HasPrefix(dbURL, "postgres://") || strings.HasPrefix(dbURL, "postgresql://") {
+		u, err := url.Parse(dbURL)
+		if err == nil {
+			q := u.Query()
+			q.Set(key, value)
+			u.RawQuery = q.Encode()
+			return u.String()
+		}
+	}
+	return dbURL + " " + key + "=" + value
+}
+
 func main() {
 	fmt.Println("Start Server")
 	godotenv.Load()
@@ -488,19 +1000,33 @@ func main() {
 
 	fmt.Println("Load ENV")
 
-	db, err := sql.Open("postgres", dbURL)
+	db, err := openDB(dbURL)
 	if err != nil {
 		fmt.Println("ERROR OPENING DB", err)
 		return
 	}
 	dbQueries := database.New(db)
 
+	// Uploads and export archives live here, so it has to survive restarts.
+	mediaDir := os.Getenv("MEDIA_DIR")
+	if mediaDir == "" {
+		fmt.Println("MEDIA_DIR IS NOT SET")
+		return
+	}
+	blobs, err := blob.NewLocalStore(mediaDir)
+	if err != nil {
+		fmt.Println("ERROR OPENING MEDIA STORE", err)
+		return
+	}
+
 	mux := http.NewServeMux()
 	apiConf := apiConfig{}
 
+	apiConf.db = db
 	apiConf.queries = dbQueries
 	apiConf.jwtSecret = jwtSecret
 	apiConf.polkaKey = polkaKey
+	apiConf.blobs = blobs
 
 	//APP FILESERVER
 	appFileServerHandler := http.StripPrefix("/app", http.FileServer(http.Dir(".")))
@@ -510,6 +1036,29 @@ func main() {
 	mux.HandleFunc("GET /api/healthz", healthzHandler)
 	mux.HandleFunc("POST /api/users", apiConf.postUsersHandler)
 	mux.HandleFunc("PUT /api/users", apiConf.putUsersHandler)
+	mux.HandleFunc("PATCH /api/users/me", apiConf.patchUserHandler)
+	mux.HandleFunc("DELETE /api/users/me", apiConf.deleteUserHandler)
+	mux.HandleFunc("GET /api/users/{idOrHandle}", apiConf.getProfileHandler)
+	mux.HandleFunc("PUT /api/users/me/profile", apiConf.putProfileHandler)
+	mux.HandleFunc("PUT /api/users/me/pins", apiConf.putPinsHandler)
+	mux.HandleFunc("POST /api/users/me/pins/{chirpID}", apiConf.postPinHandler)
+	mux.HandleFunc("DELETE /api/users/me/pins/{chirpID}", apiConf.deletePinHandler)
+	mux.HandleFunc("POST /api/users/{userID}/follow", apiConf.postFollowHandler)
+	mux.HandleFunc("DELETE /api/users/{userID}/follow", apiConf.deleteFollowHandler)
+	mux.HandleFunc("GET /api/users/{userID}/followers", apiConf.getFollowersHandler)
+	mux.HandleFunc("GET /api/users/{userID}/following", apiConf.getFollowingHandler)
+	mux.HandleFunc("POST /api/users/{userID}/block", apiConf.postBlockHandler)
+	mux.HandleFunc("DELETE /api/users/{userID}/block", apiConf.deleteBlockHandler)
+	mux.HandleFunc("POST /api/users/{userID}/mute", apiConf.postMuteHandler)
+	mux.HandleFunc("DELETE /api/users/{userID}/mute", apiConf.deleteMuteHandler)
+	mux.HandleFunc("PUT /api/users/me/preferences", apiConf.putPreferencesHandler)
+	mux.HandleFunc("GET /api/users/me/analytics", apiConf.getAnalyticsHandler)
+	mux.HandleFunc("POST /api/users/me/export", apiConf.postExportHandler)
+	mux.HandleFunc("GET /api/users/me/exports", apiConf.getExportsHandler)
+	mux.HandleFunc("GET /api/exports/{exportID}/download", apiConf.downloadExportHandler)
+	mux.HandleFunc("GET /api/follow-requests", apiConf.getFollowRequestsHandler)
+	mux.HandleFunc("POST /api/follow-requests/{userID}/approve", apiConf.approveFollowRequestHandler)
+	mux.HandleFunc("POST /api/follow-requests/{userID}/reject", apiConf.rejectFollowRequestHandler)
 
 	mux.HandleFunc("POST /api/login", apiConf.loginHandler)
 	mux.HandleFunc("POST /api/refresh", apiConf.refreshHandler)
@@ -518,8 +1067,45 @@ func main() {
 	mux.HandleFunc("POST /api/validate_chirp", validateChirpHandler)
 	mux.HandleFunc("POST /api/chirps", apiConf.postChirpsHandler)
 	mux.HandleFunc("GET /api/chirps", apiConf.getChirpsHandler)
+	mux.HandleFunc("POST /api/chirps/import", apiConf.postImportHandler)
+	mux.HandleFunc("GET /api/chirps/scheduled", apiConf.getScheduledChirpsHandler)
+	mux.HandleFunc("PUT /api/chirps/scheduled/{chirpID}", apiConf.putScheduledChirpHandler)
+	mux.HandleFunc("DELETE /api/chirps/scheduled/{chirpID}", apiConf.deleteScheduledChirpHandler)
 	mux.HandleFunc("GET /api/chirps/{chirpID}", apiConf.getChirpHandler)
+	mux.HandleFunc("GET /api/chirps/{chirpID}/replies", apiConf.getRepliesHandler)
 	mux.HandleFunc("DELETE /api/chirps/{chirpID}", apiConf.deleteChirpHandler)
+	mux.HandleFunc("PATCH /api/chirps/{chirpID}", apiConf.patchChirpHandler)
+	mux.HandleFunc("PUT /api/moderation/chirps/{chirpID}/labels", apiConf.putModerationLabelsHandler)
+	mux.HandleFunc("POST /api/chirps/{chirpID}/restore", apiConf.restoreChirpHandler)
+	mux.HandleFunc("GET /api/trash", apiConf.getTrashHandler)
+	mux.HandleFunc("POST /api/chirps/{chirpID}/poll/votes", apiConf.postPollVoteHandler)
+
+	mux.HandleFunc("POST /api/drafts", apiConf.postDraftsHandler)
+	mux.HandleFunc("GET /api/drafts", apiConf.getDraftsHandler)
+	mux.HandleFunc("GET /api/drafts/{draftID}", apiConf.getDraftHandler)
+	mux.HandleFunc("PUT /api/drafts/{draftID}", apiConf.putDraftHandler)
+	mux.HandleFunc("DELETE /api/drafts/{draftID}", apiConf.deleteDraftHandler)
+	mux.HandleFunc("POST /api/drafts/{draftID}/publish", apiConf.publishDraftHandler)
+
+	mux.HandleFunc("POST /api/media", apiConf.postMediaHandler)
+	mux.HandleFunc("GET /api/media/{mediaID}", apiConf.getMediaHandler)
+	mux.HandleFunc("GET /api/media/{mediaID}/thumbnail", apiConf.getMediaThumbnailHandler)
+
+	mux.HandleFunc("GET /api/trends", apiConf.getTrendsHandler)
+	mux.HandleFunc("GET /api/search/chirps", apiConf.searchChirpsHandler)
+	mux.HandleFunc("GET /api/timeline/home", apiConf.getHomeTimelineHandler)
+	mux.HandleFunc("GET /api/notifications", apiConf.getNotificationsHandler)
+
+	mux.HandleFunc("POST /api/lists", apiConf.postListsHandler)
+	mux.HandleFunc("GET /api/lists", apiConf.getListsHandler)
+	mux.HandleFunc("GET /api/lists/{listID}", apiConf.getListHandler)
+	mux.HandleFunc("DELETE /api/lists/{listID}", apiConf.deleteListHandler)
+	mux.HandleFunc("GET /api/lists/{listID}/members", apiConf.getListMembersHandler)
+	mux.HandleFunc("POST /api/lists/{listID}/members/{userID}", apiConf.postListMemberHandler)
+	mux.HandleFunc("DELETE /api/lists/{listID}/members/{userID}", apiConf.deleteListMemberHandler)
+	mux.HandleFunc("POST /api/lists/{listID}/subscription", apiConf.postListSubscriptionHandler)
+	mux.HandleFunc("DELETE /api/lists/{listID}/subscription", apiConf.deleteListSubscriptionHandler)
+	mux.HandleFunc("GET /api/lists/{listID}/timeline", apiConf.getListTimelineHandler)
 
 	mux.HandleFunc("POST /api/polka/webhooks", apiConf.postpolkaHookHandler)
 
@@ -527,9 +1113,50 @@ func main() {
 	mux.HandleFunc("GET /admin/metrics", apiConf.metricsHandler)
 	mux.HandleFunc("POST /admin/reset", apiConf.resetHandler)
 
+	//WORKERS
+	// Workers run until the server has stopped, so the last requests still
+	// get their views flushed and their chirps fanned out.
+	workersCtx, stopWorkers := context.WithCancel(context.Background())
+	workers := sync.WaitGroup{}
+	startWorker := func(run func(context.Context, time.Duration), every time.Duration) {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			run(workersCtx, every)
+		}()
+	}
+	startWorker(apiConf.runTrendsWorker, time.Minute)
+	startWorker(apiConf.runScheduler, 10*time.Second)
+	startWorker(apiConf.runPurgeWorker, time.Hour)
+	startWorker(apiConf.runEntitiesBackfill, time.Minute)
+	startWorker(apiConf.runViewsFlusher, time.Minute)
+	startWorker(apiConf.runFanoutWorker, 5*time.Second)
+	startWorker(apiConf.runDeletionWorker, time.Hour)
+	startWorker(apiConf.runExportWorker, 30*time.Second)
+
 	//START SERVER
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 	server := http.Server{Handler: mux, Addr: ":8080"}
-	server.ListenAndServe()
+	go func() {
+		err := server.ListenAndServe()
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
+			fmt.Println("ERROR STARTING SERVER", err)
+			stop()
+		}
+	}()
+
+	//STOP SERVER
+	<-ctx.Done()
+	fmt.Println("Stop Server")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	err = server.Shutdown(shutdownCtx)
+	if err != nil {
+		fmt.Println("ERROR STOPPING SERVER", err)
+	}
+	stopWorkers()
+	workers.Wait()
 }
 
 func healthzHandler(rw http.ResponseWriter, _ *http.Request) {
@@ -553,16 +1180,24 @@ func validateChirpHandler(rw http.ResponseWriter, r *http.Request) {
 		respondWithError(rw, http.StatusInternalServerError, "Something went wrong")
 		return
 	}
-	if len(params.Body) > 140 {
-		respondWithError(rw, http.StatusBadRequest, "Chirp is too long")
+	newBody, err := cleanChirpBody(params.Body)
+	if err != nil {
+		respondWithError(rw, http.StatusBadRequest, err.Error())
 		return
 	}
 
-	newBody := removeProfanity(params.Body)
-
 	respondWithJSON(rw, http.StatusOK, responseJson{CleanedBody: newBody})
 
 }
+
+// cleanChirpBody is the validation every chirp body goes through before it is
+// stored, wherever it comes from.
+func cleanChirpBody(body string) (string, error) {
+	if len(body) > 140 {
+		return "", errors.New("Chirp is too long")
+	}
+	return removeProfanity(body), nil
+}
 func removeProfanity(chirp string) string {
 	profanes := []string{"kerfuffle", "sharbert", "fornax"}
 	words := strings.Fields(chirp)
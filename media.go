@@ -0,0 +1,233 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Serux/chirpy/internal/blob"
+	"github.com/Serux/chirpy/internal/database"
+	"github.com/Serux/chirpy/internal/media"
+	"github.com/google/uuid"
+)
+
+const maxChirpMedia = 4
+
+// Room for the multipart boundaries and headers around the file itself.
+const multipartOverheadBytes = 64 << 10
+
+type mediaJson struct {
+	Id           string `json:"id"`
+	CreatedAt    string `json:"created_at"`
+	ContentType  string `json:"content_type"`
+	SizeBytes    int64  `json:"size_bytes"`
+	Width        int32  `json:"width"`
+	Height       int32  `json:"height"`
+	Url          string `json:"url"`
+	ThumbnailUrl string `json:"thumbnail_url"`
+}
+
+func mediaToJson(m database.Medium) mediaJson {
+	return mediaJson{
+		Id:           m.ID.String(),
+		CreatedAt:    m.CreatedAt.Format(time.RFC3339),
+		ContentType:  m.ContentType,
+		SizeBytes:    m.SizeBytes,
+		Width:        m.Width,
+		Height:       m.Height,
+		Url:          "/api/media/" + m.ID.String(),
+		ThumbnailUrl: "/api/media/" + m.ID.String() + "/thumbnail",
+	}
+}
+
+// selectAttachableMedia checks that the media ids sent with a new chirp are
+// the author's own uploads and not attached to another chirp yet, and returns
+// them in the order they were given.
+func (cfg *apiConfig) selectAttachableMedia(ctx context.Context, userId uuid.UUID, mediaIds []string) ([]database.Medium, error) {
+	if len(mediaIds) > maxChirpMedia {
+		return nil, fmt.Errorf("A chirp can have at most %d media", maxChirpMedia)
+	}
+	ids := []uuid.UUID{}
+	for _, mediaId := range mediaIds {
+		id, err := uuid.Parse(mediaId)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid media id %q", mediaId)
+		}
+		ids = append(ids, id)
+	}
+	if len(ids) == 0 {
+		return []database.Medium{}, nil
+	}
+
+	found, err := cfg.queries.SelectAttachableMedia(ctx, database.SelectAttachableMediaParams{Ids: ids, UserID: userId})
+	if err != nil {
+		return nil, err
+	}
+	byId := map[uuid.UUID]database.Medium{}
+	for _, m := range found {
+		byId[m.ID] = m
+	}
+
+	ret := []database.Medium{}
+	seen := map[uuid.UUID]bool{}
+	for _, id := range ids {
+		m, ok := byId[id]
+		if !ok || seen[id] {
+			return nil, fmt.Errorf("Media %s is not one of your unattached uploads", id)
+		}
+		seen[id] = true
+		ret = append(ret, m)
+	}
+	return ret, nil
+}
+
+func (cfg *apiConfig) postMediaHandler(rw http.ResponseWriter, r *http.Request) {
+	uidtok, err := cfg.authenticatedUserId(r)
+	if err != nil {
+		respondWithError(rw, http.StatusUnauthorized, "Something went wrong validating JWT")
+		return
+	}
+
+	r.Body = http.MaxBytesReader(rw, r.Body, media.MaxUploadBytes+multipartOverheadBytes)
+	file, _, err := r.FormFile("file")
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			respondWithError(rw, http.StatusRequestEntityTooLarge, "File is too large")
+			return
+		}
+		respondWithError(rw, http.StatusBadRequest, "Expected a multipart form with a file field")
+		return
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(io.LimitReader(file, media.MaxUploadBytes+1))
+	if err != nil {
+		respondWithError(rw, http.StatusBadRequest, "Something went wrong reading file")
+		return
+	}
+	if len(data) > media.MaxUploadBytes {
+		respondWithError(rw, http.StatusRequestEntityTooLarge, "File is too large")
+		return
+	}
+
+	processed, err := media.Process(data)
+	if errors.Is(err, media.ErrUnsupportedType) {
+		respondWithError(rw, http.StatusUnsupportedMediaType, "Only JPEG, PNG and GIF images are supported")
+		return
+	}
+	if errors.Is(err, media.ErrTooLarge) {
+		respondWithError(rw, http.StatusBadRequest, fmt.Sprintf("Images can be at most %dx%d", media.MaxDimension, media.MaxDimension))
+		return
+	}
+	if errors.Is(err, media.ErrTooManyFrames) {
+		respondWithError(rw, http.StatusBadRequest, fmt.Sprintf("Animated GIFs can have at most %d frames and %d million pixels in all", media.MaxGIFFrames, media.MaxGIFPixels/1_000_000))
+		return
+	}
+	if err != nil {
+		respondWithError(rw, http.StatusInternalServerError, "Something went wrong processing image")
+		return
+	}
+
+	id := uuid.New()
+	blobKey := "media/" + id.String()
+	thumbnailKey := "media/" + id.String() + "-thumbnail"
+
+	err = cfg.blobs.Put(r.Context(), blobKey, bytes.NewReader(processed.Original.Data))
+	if err != nil {
+		respondWithError(rw, http.StatusInternalServerError, "Something went wrong storing image")
+		return
+	}
+	err = cfg.blobs.Put(r.Context(), thumbnailKey, bytes.NewReader(processed.Thumbnail.Data))
+	if err != nil {
+		cfg.blobs.Delete(r.Context(), blobKey)
+		respondWithError(rw, http.StatusInternalServerError, "Something went wrong storing thumbnail")
+		return
+	}
+
+	m, err := cfg.queries.CreateMedia(r.Context(), database.CreateMediaParams{
+		ID:                   id,
+		UserID:               uidtok,
+		ContentType:          processed.Original.ContentType,
+		SizeBytes:            int64(len(processed.Original.Data)),
+		Width:                int32(processed.Original.Width),
+		Height:               int32(processed.Original.Height),
+		BlobKey:              blobKey,
+		ThumbnailContentType: processed.Thumbnail.ContentType,
+		ThumbnailKey:         thumbnailKey,
+	})
+	if err != nil {
+		cfg.blobs.Delete(r.Context(), blobKey)
+		cfg.blobs.Delete(r.Context(), thumbnailKey)
+		respondWithError(rw, http.StatusInternalServerError, "Something went wrong saving media")
+		return
+	}
+
+	respondWithJSON(rw, http.StatusCreated, mediaToJson(m))
+}
+
+func (cfg *apiConfig) getMediaHandler(rw http.ResponseWriter, r *http.Request) {
+	cfg.serveMedia(rw, r, false)
+}
+
+func (cfg *apiConfig) getMediaThumbnailHandler(rw http.ResponseWriter, r *http.Request) {
+	cfg.serveMedia(rw, r, true)
+}
+
+func (cfg *apiConfig) serveMedia(rw http.ResponseWriter, r *http.Request, thumbnail bool) {
+	id, err := uuid.Parse(r.PathValue("mediaID"))
+	if err != nil {
+		respondWithError(rw, http.StatusNotFound, "Media not found")
+		return
+	}
+	// Media anybody else can't see is a 404 like media that doesn't exist,
+	// so the id doesn't give away that it's there.
+	row, err := cfg.queries.SelectVisibleMedia(r.Context(), database.SelectVisibleMediaParams{ID: id, ViewerID: cfg.viewerId(r)})
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(rw, http.StatusNotFound, "Media not found")
+		return
+	}
+	if err != nil {
+		respondWithError(rw, http.StatusInternalServerError, "Something went wrong getting media")
+		return
+	}
+	m := row.Medium
+
+	key, contentType := m.BlobKey, m.ContentType
+	if thumbnail {
+		key, contentType = m.ThumbnailKey, m.ThumbnailContentType
+	}
+	content, err := cfg.blobs.Get(r.Context(), key)
+	if errors.Is(err, blob.ErrNotFound) {
+		respondWithError(rw, http.StatusNotFound, "Media not found")
+		return
+	}
+	if err != nil {
+		respondWithError(rw, http.StatusInternalServerError, "Something went wrong reading media")
+		return
+	}
+	defer content.Close()
+
+	rw.Header().Set("Content-Type", contentType)
+	rw.Header().Set("X-Content-Type-Options", "nosniff")
+	// Stored media never changes, a new upload always gets a new id, but who
+	// can see it does when its chirp is trashed or the account protected. So
+	// shared caches only keep public media, and only for a while.
+	if row.Public {
+		rw.Header().Set("Cache-Control", "public, max-age=3600")
+	} else {
+		rw.Header().Set("Cache-Control", "private, max-age=3600")
+		rw.Header().Set("Vary", "Authorization")
+	}
+	if !thumbnail {
+		rw.Header().Set("Content-Length", strconv.FormatInt(m.SizeBytes, 10))
+	}
+	rw.WriteHeader(http.StatusOK)
+	io.Copy(rw, content)
+}
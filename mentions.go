@@ -0,0 +1,143 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/Serux/chirpy/internal/database"
+	"github.com/Serux/chirpy/internal/entities"
+	"github.com/google/uuid"
+)
+
+const notificationsPageSize = 100
+
+var handleRegexp = regexp.MustCompile(`^[A-Za-z0-9_]{1,30}$`)
+
+type notificationJson struct {
+	Id        string `json:"id"`
+	CreatedAt string `json:"created_at"`
+	Kind      string `json:"kind"`
+	ActorId   string `json:"actor_id,omitempty"`
+	ChirpId   string `json:"chirp_id,omitempty"`
+	ExportId  string `json:"export_id,omitempty"`
+	Read      bool   `json:"read"`
+}
+
+// indexChirpMentions resolves the @handles parsed from a chirp body to users
+// and stores them with their offsets, returning the mentioned users by
+// lowercase handle. Handles that don't belong to anybody, or to somebody on
+// either side of a block with the author, stay plain text. Users mentioned for
+// the first time in this chirp get a notification, so re-indexing an edited
+// chirp doesn't notify everybody again. It takes the queries to run on so
+// callers can make it part of a transaction.
+func indexChirpMentions(ctx context.Context, q *database.Queries, chirp database.Chirp, mentions []entities.Mention) (map[string]mentionEntityJson, error) {
+	ret := map[string]mentionEntityJson{}
+	previous, err := q.SelectMentionsForChirps(ctx, []uuid.UUID{chirp.ID})
+	if err != nil {
+		return nil, err
+	}
+	alreadyNotified := map[uuid.UUID]bool{}
+	for _, m := range previous {
+		alreadyNotified[m.UserID] = true
+	}
+	blockedIds, err := q.SelectBlockedUserIds(ctx, chirp.UserID)
+	if err != nil {
+		return nil, err
+	}
+	blocked := map[uuid.UUID]bool{}
+	for _, id := range blockedIds {
+		blocked[id] = true
+	}
+
+	err = q.DeleteChirpMentions(ctx, chirp.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(mentions) == 0 {
+		return ret, nil
+	}
+	handles := []string{}
+	for _, m := range mentions {
+		handles = append(handles, strings.ToLower(m.Handle))
+	}
+	users, err := q.SelectUsersByHandles(ctx, handles)
+	if err != nil {
+		return nil, err
+	}
+	usersByHandle := map[string]database.User{}
+	for _, u := range users {
+		usersByHandle[strings.ToLower(u.Handle.String)] = u
+	}
+
+	for _, m := range mentions {
+		user, ok := usersByHandle[strings.ToLower(m.Handle)]
+		if !ok || blocked[user.ID] {
+			continue
+		}
+		ret[strings.ToLower(m.Handle)] = mentionEntityJson{UserId: user.ID.String(), Handle: user.Handle.String}
+		err = q.InsertChirpMention(ctx, database.InsertChirpMentionParams{
+			ChirpID:     chirp.ID,
+			UserID:      user.ID,
+			Handle:      user.Handle.String,
+			StartOffset: int32(m.Start),
+			EndOffset:   int32(m.End),
+		})
+		if err != nil {
+			return nil, err
+		}
+		if alreadyNotified[user.ID] || user.ID == chirp.UserID {
+			continue
+		}
+		alreadyNotified[user.ID] = true
+		_, err = q.InsertNotification(ctx, database.InsertNotificationParams{
+			UserID:  user.ID,
+			Kind:    "mention",
+			ActorID: uuid.NullUUID{UUID: chirp.UserID, Valid: true},
+			ChirpID: uuid.NullUUID{UUID: chirp.ID, Valid: true},
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return ret, nil
+}
+
+func (cfg *apiConfig) getNotificationsHandler(rw http.ResponseWriter, r *http.Request) {
+	uidtok, err := cfg.authenticatedUserId(r)
+	if err != nil {
+		respondWithError(rw, http.StatusUnauthorized, "Something went wrong validating JWT")
+		return
+	}
+
+	notifications, err := cfg.queries.SelectNotificationsUser(r.Context(), database.SelectNotificationsUserParams{UserID: uidtok, Limit: notificationsPageSize})
+	if err != nil {
+		respondWithError(rw, http.StatusInternalServerError, "Something went wrong getting notifications")
+		return
+	}
+
+	ret := []notificationJson{}
+	for _, n := range notifications {
+		nj := notificationJson{
+			Id:        n.ID.String(),
+			CreatedAt: n.CreatedAt.Format(time.RFC3339),
+			Kind:      n.Kind,
+			Read:      n.ReadAt.Valid,
+		}
+		if n.ActorID.Valid {
+			nj.ActorId = n.ActorID.UUID.String()
+		}
+		if n.ChirpID.Valid {
+			nj.ChirpId = n.ChirpID.UUID.String()
+		}
+		if n.ExportID.Valid {
+			nj.ExportId = n.ExportID.UUID.String()
+		}
+		ret = append(ret, nj)
+	}
+
+	respondWithJSON(rw, http.StatusOK, ret)
+}
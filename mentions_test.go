@@ -0,0 +1,171 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/Serux/chirpy/internal/database"
+	"github.com/Serux/chirpy/internal/entities"
+	"github.com/google/uuid"
+)
+
+// notificationsFor reads user's notifications through the endpoint.
+func notificationsFor(t *testing.T, cfg *apiConfig, user uuid.UUID) []notificationJson {
+	t.Helper()
+	rw := serve(t, cfg.getNotificationsHandler, user, "/api/notifications", nil)
+	if rw.Code != http.StatusOK {
+		t.Fatalf("GET /api/notifications = %d: %s", rw.Code, rw.Body.String())
+	}
+	notifications := []notificationJson{}
+	err := json.Unmarshal(rw.Body.Bytes(), &notifications)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return notifications
+}
+
+// createUnindexedChirp creates a chirp without looking at its body, so the
+// test can index it itself.
+func createUnindexedChirp(t *testing.T, cfg *apiConfig, author uuid.UUID, body string) database.Chirp {
+	t.Helper()
+	chirp, err := cfg.queries.CreateChirp(context.Background(), database.CreateChirpParams{Body: body, UserID: author, Visibility: "public"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return chirp
+}
+
+func TestIndexChirpMentions(t *testing.T) {
+	cfg := testConfig(t)
+	ctx := context.Background()
+	alice := createTestUser(t, cfg, "alice")
+	bob := createTestUser(t, cfg, "bob")
+	carol := createTestUser(t, cfg, "carol")
+	dave := createTestUser(t, cfg, "dave")
+	_, err := cfg.queries.InsertBlock(ctx, database.InsertBlockParams{BlockerID: carol, BlockedID: alice})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	body := "😀 hi @Bob and @bob, @nobody @carol @alice bob@example.com (@dave)"
+	chirp := createUnindexedChirp(t, cfg, alice, body)
+	users, err := indexChirpMentions(ctx, cfg.queries, chirp, entities.Parse(body).Mentions)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resolved := []string{}
+	for handle, u := range users {
+		resolved = append(resolved, handle+"="+u.UserId)
+	}
+	slices.Sort(resolved)
+	want := []string{"alice=" + alice.String(), "bob=" + bob.String(), "dave=" + dave.String()}
+	if !slices.Equal(resolved, want) {
+		t.Errorf("resolved = %v, want %v", resolved, want)
+	}
+
+	stored, err := cfg.queries.SelectMentionsForChirps(ctx, []uuid.UUID{chirp.ID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	runes := []rune(body)
+	storedUsers := []uuid.UUID{}
+	for _, m := range stored {
+		storedUsers = append(storedUsers, m.UserID)
+		if got := string(runes[m.StartOffset:m.EndOffset]); !strings.EqualFold(got, "@"+m.Handle) {
+			t.Errorf("mention of %s spans %q", m.Handle, got)
+		}
+	}
+	if want := []uuid.UUID{bob, bob, alice, dave}; !slices.Equal(storedUsers, want) {
+		t.Errorf("stored mentions = %v, want %v", storedUsers, want)
+	}
+
+	for _, c := range []struct {
+		user uuid.UUID
+		want int
+	}{
+		{bob, 1},
+		{dave, 1},
+		{alice, 0},
+		{carol, 0},
+	} {
+		if got := notificationsFor(t, cfg, c.user); len(got) != c.want {
+			t.Errorf("%s has %d notifications, want %d", c.user, len(got), c.want)
+		}
+	}
+}
+
+// TestMentionsNotifyOnceOnEdit re-indexes a chirp the way an edit does:
+// users mentioned before aren't notified again, newly mentioned ones are.
+func TestMentionsNotifyOnceOnEdit(t *testing.T) {
+	cfg := testConfig(t)
+	ctx := context.Background()
+	alice := createTestUser(t, cfg, "alice")
+	bob := createTestUser(t, cfg, "bob")
+	dave := createTestUser(t, cfg, "dave")
+
+	chirp := createUnindexedChirp(t, cfg, alice, "hi @bob")
+	for _, body := range []string{"hi @bob", "hi @bob", "hi @Bob and @dave", "hi @dave"} {
+		chirp.Body = body
+		_, err := indexChirpMentions(ctx, cfg.queries, chirp, entities.Parse(body).Mentions)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, user := range []uuid.UUID{bob, dave} {
+		if got := notificationsFor(t, cfg, user); len(got) != 1 {
+			t.Errorf("%s has %d notifications, want 1", user, len(got))
+		}
+	}
+	stored, err := cfg.queries.SelectMentionsForChirps(ctx, []uuid.UUID{chirp.ID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stored) != 1 || stored[0].UserID != dave {
+		t.Errorf("stored mentions = %v, want only dave", stored)
+	}
+}
+
+func TestNotificationsEndpoint(t *testing.T) {
+	cfg := testConfig(t)
+	ctx := context.Background()
+	alice := createTestUser(t, cfg, "alice")
+	bob := createTestUser(t, cfg, "bob")
+	carol := createTestUser(t, cfg, "carol")
+
+	if rw := serve(t, cfg.getNotificationsHandler, uuid.Nil, "/api/notifications", nil); rw.Code != http.StatusUnauthorized {
+		t.Errorf("anonymous GET /api/notifications = %d, want 401", rw.Code)
+	}
+
+	chirp := createTestChirp(t, cfg, alice, "public", "hi @bob")
+	got := notificationsFor(t, cfg, bob)
+	if len(got) != 1 {
+		t.Fatalf("bob has %d notifications, want 1", len(got))
+	}
+	n := got[0]
+	if n.Kind != "mention" || n.ActorId != alice.String() || n.ChirpId != chirp.String() || n.Read {
+		t.Errorf("notification = %+v, want an unread mention by alice in %s", n, chirp)
+	}
+
+	// Muting or blocking the author hides what they already caused.
+	_, err := cfg.queries.InsertMute(ctx, database.InsertMuteParams{MuterID: bob, MutedID: alice})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := notificationsFor(t, cfg, bob); len(got) != 0 {
+		t.Errorf("bob has %d notifications from a muted user, want 0", len(got))
+	}
+	createTestChirp(t, cfg, alice, "public", "hi @carol")
+	_, err = cfg.queries.InsertBlock(ctx, database.InsertBlockParams{BlockerID: alice, BlockedID: carol})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := notificationsFor(t, cfg, carol); len(got) != 0 {
+		t.Errorf("carol has %d notifications from a user who blocked carol, want 0", len(got))
+	}
+}
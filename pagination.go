@@ -0,0 +1,102 @@
+package main
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Serux/chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+const defaultPageLimit = 20
+const maxPageLimit = 100
+
+// encodeCursor packs the keyset values of a row into an opaque token clients
+// hand back to get the next page.
+func encodeCursor(parts ...string) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strings.Join(parts, "|")))
+}
+
+// decodeCursor unpacks a token made by encodeCursor, checking it has the
+// expected number of values.
+func decodeCursor(cursor string, nparts int) ([]string, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, fmt.Errorf("MALFORMED CURSOR")
+	}
+	parts := strings.Split(string(raw), "|")
+	if len(parts) != nparts {
+		return nil, fmt.Errorf("MALFORMED CURSOR")
+	}
+	return parts, nil
+}
+
+// pageLimit reads the limit query parameter, defaulting to defaultPageLimit.
+func pageLimit(r *http.Request) (int, error) {
+	if !r.URL.Query().Has("limit") {
+		return defaultPageLimit, nil
+	}
+	limit, err := strconv.Atoi(r.URL.Query().Get("limit"))
+	if err != nil || limit < 1 || limit > maxPageLimit {
+		return 0, fmt.Errorf("limit must be between 1 and %d", maxPageLimit)
+	}
+	return limit, nil
+}
+
+type chirpPageCursor struct {
+	createdAt time.Time
+	id        uuid.UUID
+	backwards bool
+}
+
+// chirpCursor points just after (or, going backwards, just before) a chirp in
+// (created_at, id) order.
+func chirpCursor(ch database.Chirp, backwards bool) string {
+	direction := "next"
+	if backwards {
+		direction = "prev"
+	}
+	return encodeCursor(direction, strconv.FormatInt(ch.CreatedAt.UnixMicro(), 10), ch.ID.String())
+}
+
+func parseChirpCursor(cursor string) (chirpPageCursor, error) {
+	parts, err := decodeCursor(cursor, 3)
+	if err != nil {
+		return chirpPageCursor{}, err
+	}
+	if parts[0] != "next" && parts[0] != "prev" {
+		return chirpPageCursor{}, fmt.Errorf("MALFORMED CURSOR")
+	}
+	micros, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		return chirpPageCursor{}, fmt.Errorf("MALFORMED CURSOR")
+	}
+	id, err := uuid.Parse(parts[2])
+	if err != nil {
+		return chirpPageCursor{}, fmt.Errorf("MALFORMED CURSOR")
+	}
+	return chirpPageCursor{createdAt: time.UnixMicro(micros).UTC(), id: id, backwards: parts[0] == "prev"}, nil
+}
+
+// setPageLinks advertises the neighbouring pages through a Link header so
+// list responses can stay plain JSON arrays. Empty cursors are left out.
+func setPageLinks(rw http.ResponseWriter, r *http.Request, next, prev string) {
+	links := []string{}
+	for _, l := range []struct{ rel, cursor string }{{"next", next}, {"prev", prev}} {
+		if l.cursor == "" {
+			continue
+		}
+		u := *r.URL
+		query := u.Query()
+		query.Set("cursor", l.cursor)
+		u.RawQuery = query.Encode()
+		links = append(links, fmt.Sprintf(`<%s>; rel="%s"`, u.RequestURI(), l.rel))
+	}
+	if len(links) > 0 {
+		rw.Header().Set("Link", strings.Join(links, ", "))
+	}
+}
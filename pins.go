@@ -0,0 +1,216 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+
+	"github.com/Serux/chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+const maxPins = 3
+
+// pinnedChirpsToJson returns the chirps params.UserID has pinned that match
+// the filters in params, in the order the user gave them, as seen by
+// params.ViewerID.
+func (cfg *apiConfig) pinnedChirpsToJson(ctx context.Context, params database.SelectPinnedChirpsUserParams) ([]fullChirpJsonDb, error) {
+	rows, err := cfg.queries.SelectPinnedChirpsUser(ctx, params)
+	if err != nil {
+		return nil, err
+	}
+	chirps := []database.Chirp{}
+	for _, row := range rows {
+		chirps = append(chirps, row.Chirp)
+	}
+	ret, err := cfg.chirpsToJson(ctx, params.ViewerID, chirps)
+	if err != nil {
+		return nil, err
+	}
+	for i := range ret {
+		ret[i].Pinned = true
+	}
+	return ret, nil
+}
+
+// postPinHandler pins one of the user's chirps above their other pins and
+// responds with their pins. Pinning an already pinned chirp is not an error.
+func (cfg *apiConfig) postPinHandler(rw http.ResponseWriter, r *http.Request) {
+	uidtok, err := cfg.authenticatedUserId(r)
+	if err != nil {
+		respondWithError(rw, http.StatusUnauthorized, "Something went wrong validating JWT")
+		return
+	}
+	chirpId, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		respondWithError(rw, http.StatusNotFound, "Chirp not found")
+		return
+	}
+
+	chirp, err := cfg.queries.SelectOneChirps(r.Context(), database.SelectOneChirpsParams{ID: chirpId, ViewerID: uidtok})
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(rw, http.StatusNotFound, "Chirp not found")
+		return
+	}
+	if err != nil {
+		respondWithError(rw, http.StatusInternalServerError, "Something went wrong getting chirp")
+		return
+	}
+	if chirp.UserID != uidtok {
+		respondWithError(rw, http.StatusForbidden, "You can only pin your own chirps")
+		return
+	}
+
+	// PinChirp counts the pins before adding one, so two requests at once
+	// would both see room for it without the lock.
+	tx, err := cfg.db.BeginTx(r.Context(), nil)
+	if err != nil {
+		respondWithError(rw, http.StatusInternalServerError, "Something went wrong pinning chirp")
+		return
+	}
+	defer tx.Rollback()
+	q := cfg.queries.WithTx(tx)
+
+	err = q.LockUser(r.Context(), uidtok)
+	if err != nil {
+		respondWithError(rw, http.StatusInternalServerError, "Something went wrong pinning chirp")
+		return
+	}
+	pinned, err := q.PinChirp(r.Context(), database.PinChirpParams{ChirpID: chirpId, UserID: uidtok, MaxPins: maxPins})
+	if err != nil {
+		respondWithError(rw, http.StatusInternalServerError, "Something went wrong pinning chirp")
+		return
+	}
+	err = tx.Commit()
+	if err != nil {
+		respondWithError(rw, http.StatusInternalServerError, "Something went wrong pinning chirp")
+		return
+	}
+
+	ret, err := cfg.pinnedChirpsToJson(r.Context(), database.SelectPinnedChirpsUserParams{UserID: uidtok, ViewerID: uidtok})
+	if err != nil {
+		respondWithError(rw, http.StatusInternalServerError, "Something went wrong loading pins")
+		return
+	}
+	if pinned == 0 && !containsChirp(ret, chirpId) {
+		respondWithError(rw, http.StatusConflict, fmt.Sprintf("You can pin at most %d chirps", maxPins))
+		return
+	}
+	respondWithJSON(rw, http.StatusOK, ret)
+}
+
+func (cfg *apiConfig) deletePinHandler(rw http.ResponseWriter, r *http.Request) {
+	uidtok, err := cfg.authenticatedUserId(r)
+	if err != nil {
+		respondWithError(rw, http.StatusUnauthorized, "Something went wrong validating JWT")
+		return
+	}
+	chirpId, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		respondWithError(rw, http.StatusNotFound, "Pin not found")
+		return
+	}
+
+	unpinned, err := cfg.queries.UnpinChirp(r.Context(), database.UnpinChirpParams{UserID: uidtok, ChirpID: chirpId})
+	if err != nil {
+		respondWithError(rw, http.StatusInternalServerError, "Something went wrong unpinning chirp")
+		return
+	}
+	if unpinned == 0 {
+		respondWithError(rw, http.StatusNotFound, "Pin not found")
+		return
+	}
+
+	ret, err := cfg.pinnedChirpsToJson(r.Context(), database.SelectPinnedChirpsUserParams{UserID: uidtok, ViewerID: uidtok})
+	if err != nil {
+		respondWithError(rw, http.StatusInternalServerError, "Something went wrong loading pins")
+		return
+	}
+	respondWithJSON(rw, http.StatusOK, ret)
+}
+
+// putPinsHandler reorders the user's pins. chirp_ids has to list every pin
+// exactly once, in the new order.
+func (cfg *apiConfig) putPinsHandler(rw http.ResponseWriter, r *http.Request) {
+	type requestJson struct {
+		ChirpIds []string `json:"chirp_ids"`
+	}
+
+	uidtok, err := cfg.authenticatedUserId(r)
+	if err != nil {
+		respondWithError(rw, http.StatusUnauthorized, "Something went wrong validating JWT")
+		return
+	}
+	params := requestJson{}
+	err = json.NewDecoder(r.Body).Decode(&params)
+	if err != nil {
+		respondWithError(rw, http.StatusBadRequest, "Something went wrong decoding input")
+		return
+	}
+
+	// The lock keeps a pin added or removed meanwhile from slipping past the
+	// check below.
+	tx, err := cfg.db.BeginTx(r.Context(), nil)
+	if err != nil {
+		respondWithError(rw, http.StatusInternalServerError, "Something went wrong reordering pins")
+		return
+	}
+	defer tx.Rollback()
+	q := cfg.queries.WithTx(tx)
+
+	err = q.LockUser(r.Context(), uidtok)
+	if err != nil {
+		respondWithError(rw, http.StatusInternalServerError, "Something went wrong reordering pins")
+		return
+	}
+	current, err := q.SelectPinIdsUser(r.Context(), uidtok)
+	if err != nil {
+		respondWithError(rw, http.StatusInternalServerError, "Something went wrong reordering pins")
+		return
+	}
+	order := []uuid.UUID{}
+	for _, s := range params.ChirpIds {
+		id, err := uuid.Parse(s)
+		if err != nil || !slices.Contains(current, id) || slices.Contains(order, id) {
+			order = nil
+			break
+		}
+		order = append(order, id)
+	}
+	if len(order) != len(current) {
+		respondWithError(rw, http.StatusBadRequest, "chirp_ids must list each of your pins once")
+		return
+	}
+	for i, id := range order {
+		err = q.SetPinPosition(r.Context(), database.SetPinPositionParams{Position: int32(i), UserID: uidtok, ChirpID: id})
+		if err != nil {
+			respondWithError(rw, http.StatusInternalServerError, "Something went wrong reordering pins")
+			return
+		}
+	}
+	err = tx.Commit()
+	if err != nil {
+		respondWithError(rw, http.StatusInternalServerError, "Something went wrong reordering pins")
+		return
+	}
+
+	ret, err := cfg.pinnedChirpsToJson(r.Context(), database.SelectPinnedChirpsUserParams{UserID: uidtok, ViewerID: uidtok})
+	if err != nil {
+		respondWithError(rw, http.StatusInternalServerError, "Something went wrong loading pins")
+		return
+	}
+	respondWithJSON(rw, http.StatusOK, ret)
+}
+
+func containsChirp(chirps []fullChirpJsonDb, id uuid.UUID) bool {
+	for _, ch := range chirps {
+		if ch.Id == id.String() {
+			return true
+		}
+	}
+	return false
+}
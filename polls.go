@@ -0,0 +1,221 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/Serux/chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+const minPollOptions = 2
+const maxPollOptions = 4
+const maxPollOptionLength = 25
+const maxPollDuration = 7 * 24 * time.Hour
+
+type pollRequestJson struct {
+	Options  []string `json:"options"`
+	ClosesAt string   `json:"closes_at"`
+}
+
+type pollOptionJson struct {
+	Id       string `json:"id"`
+	Position int    `json:"position"`
+	Text     string `json:"text"`
+	// Votes is null while the results are hidden from the viewer.
+	Votes *int64 `json:"votes"`
+}
+
+type pollJson struct {
+	ClosesAt      string           `json:"closes_at"`
+	Closed        bool             `json:"closed"`
+	Options       []pollOptionJson `json:"options"`
+	TotalVotes    *int64           `json:"total_votes"`
+	VotedOptionId string           `json:"voted_option_id,omitempty"`
+}
+
+// parsePoll validates the poll sent with a new chirp. opensAt is when the
+// chirp becomes visible, which is later than now for scheduled chirps.
+func parsePoll(poll pollRequestJson, opensAt time.Time) ([]string, time.Time, error) {
+	if len(poll.Options) < minPollOptions || len(poll.Options) > maxPollOptions {
+		return nil, time.Time{}, fmt.Errorf("A poll needs between %d and %d options", minPollOptions, maxPollOptions)
+	}
+	options := []string{}
+	seen := map[string]bool{}
+	for _, o := range poll.Options {
+		text := strings.TrimSpace(o)
+		if text == "" || utf8.RuneCountInString(text) > maxPollOptionLength {
+			return nil, time.Time{}, fmt.Errorf("Poll options must be between 1 and %d characters", maxPollOptionLength)
+		}
+		if seen[strings.ToLower(text)] {
+			return nil, time.Time{}, errors.New("Poll options must be different")
+		}
+		seen[strings.ToLower(text)] = true
+		options = append(options, removeProfanity(text))
+	}
+
+	closesAt, err := time.Parse(time.RFC3339, poll.ClosesAt)
+	if err != nil {
+		return nil, time.Time{}, errors.New("Invalid poll closes_at, expected an RFC3339 time")
+	}
+	if !closesAt.After(opensAt) {
+		return nil, time.Time{}, errors.New("Poll closes_at must be after the chirp is published")
+	}
+	if closesAt.After(opensAt.Add(maxPollDuration)) {
+		return nil, time.Time{}, errors.New("Polls can stay open for at most 7 days")
+	}
+	return options, closesAt.UTC(), nil
+}
+
+func createPoll(ctx context.Context, q *database.Queries, chirpId uuid.UUID, options []string, closesAt time.Time) error {
+	_, err := q.CreatePoll(ctx, database.CreatePollParams{ChirpID: chirpId, ClosesAt: closesAt})
+	if err != nil {
+		return err
+	}
+	for i, text := range options {
+		_, err = q.InsertPollOption(ctx, database.InsertPollOptionParams{ChirpID: chirpId, Position: int32(i), Text: text})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// pollsForChirps loads the polls of a batch of chirps as seen by viewer
+// (uuid.Nil when anonymous). Vote counts are only included once the viewer
+// has voted or the poll has closed, so they can't sway the vote.
+func (cfg *apiConfig) pollsForChirps(ctx context.Context, viewer uuid.UUID, ids []uuid.UUID) (map[uuid.UUID]*pollJson, error) {
+	polls, err := cfg.queries.SelectPollsForChirps(ctx, ids)
+	if err != nil || len(polls) == 0 {
+		return map[uuid.UUID]*pollJson{}, err
+	}
+	pollIds := []uuid.UUID{}
+	for _, p := range polls {
+		pollIds = append(pollIds, p.ChirpID)
+	}
+
+	options, err := cfg.queries.SelectPollOptionsForChirps(ctx, pollIds)
+	if err != nil {
+		return nil, err
+	}
+	votedFor := map[uuid.UUID]uuid.UUID{}
+	if viewer != uuid.Nil {
+		votes, err := cfg.queries.SelectPollVotesUser(ctx, database.SelectPollVotesUserParams{UserID: viewer, ChirpIds: pollIds})
+		if err != nil {
+			return nil, err
+		}
+		for _, v := range votes {
+			votedFor[v.ChirpID] = v.OptionID
+		}
+	}
+
+	ret := map[uuid.UUID]*pollJson{}
+	now := time.Now().UTC()
+	for _, p := range polls {
+		pj := &pollJson{
+			ClosesAt: p.ClosesAt.Format(time.RFC3339),
+			Closed:   !now.Before(p.ClosesAt),
+			Options:  []pollOptionJson{},
+		}
+		if option, ok := votedFor[p.ChirpID]; ok {
+			pj.VotedOptionId = option.String()
+		}
+		if pj.Closed || pj.VotedOptionId != "" {
+			pj.TotalVotes = new(int64)
+		}
+		ret[p.ChirpID] = pj
+	}
+	for _, o := range options {
+		pj := ret[o.PollOption.ChirpID]
+		oj := pollOptionJson{
+			Id:       o.PollOption.ID.String(),
+			Position: int(o.PollOption.Position),
+			Text:     o.PollOption.Text,
+		}
+		if pj.TotalVotes != nil {
+			votes := o.Votes
+			oj.Votes = &votes
+			*pj.TotalVotes += votes
+		}
+		pj.Options = append(pj.Options, oj)
+	}
+	return ret, nil
+}
+
+func (cfg *apiConfig) postPollVoteHandler(rw http.ResponseWriter, r *http.Request) {
+	type requestJson struct {
+		OptionId string `json:"option_id"`
+	}
+
+	uidtok, err := cfg.authenticatedUserId(r)
+	if err != nil {
+		respondWithError(rw, http.StatusUnauthorized, "Something went wrong validating JWT")
+		return
+	}
+	chirpId, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		respondWithError(rw, http.StatusNotFound, "Poll not found")
+		return
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	params := requestJson{}
+	err = decoder.Decode(&params)
+	if err != nil {
+		respondWithError(rw, http.StatusBadRequest, "Something went wrong decoding input")
+		return
+	}
+	optionId, err := uuid.Parse(params.OptionId)
+	if err != nil {
+		respondWithError(rw, http.StatusBadRequest, "Invalid option_id")
+		return
+	}
+
+	chirp, err := cfg.queries.SelectOneChirps(r.Context(), database.SelectOneChirpsParams{ID: chirpId, ViewerID: uidtok})
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(rw, http.StatusNotFound, "Poll not found")
+		return
+	}
+	if err != nil {
+		respondWithError(rw, http.StatusInternalServerError, "Something went wrong getting chirp")
+		return
+	}
+
+	voted, err := cfg.queries.InsertPollVote(r.Context(), database.InsertPollVoteParams{UserID: uidtok, OptionID: optionId, ChirpID: chirp.ID})
+	if err != nil {
+		respondWithError(rw, http.StatusInternalServerError, "Something went wrong voting")
+		return
+	}
+
+	polls, err := cfg.pollsForChirps(r.Context(), uidtok, []uuid.UUID{chirp.ID})
+	if err != nil {
+		respondWithError(rw, http.StatusInternalServerError, "Something went wrong loading poll")
+		return
+	}
+	poll, ok := polls[chirp.ID]
+	if !ok {
+		respondWithError(rw, http.StatusNotFound, "Poll not found")
+		return
+	}
+	if voted == 0 {
+		// Nothing was inserted, work out why from the poll as it is now.
+		switch {
+		case poll.VotedOptionId != "":
+			respondWithError(rw, http.StatusConflict, "You already voted on this poll")
+		case poll.Closed:
+			respondWithError(rw, http.StatusConflict, "Poll is closed")
+		default:
+			respondWithError(rw, http.StatusBadRequest, "Option is not part of this poll")
+		}
+		return
+	}
+
+	respondWithJSON(rw, http.StatusCreated, poll)
+}
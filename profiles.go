@@ -0,0 +1,212 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/Serux/chirpy/internal/database"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+const maxDisplayNameLength = 50
+const maxBioLength = 160
+
+// profileJson is what anybody can see of a user. It must never carry the
+// email or anything else private.
+type profileJson struct {
+	Id             string `json:"id"`
+	CreatedAt      string `json:"created_at"`
+	Handle         string `json:"handle"`
+	DisplayName    string `json:"display_name"`
+	Bio            string `json:"bio"`
+	AvatarUrl      string `json:"avatar_url,omitempty"`
+	IsChirpyRed    bool   `json:"is_chirpy_red"`
+	IsProtected    bool   `json:"is_protected"`
+	FollowersCount int64  `json:"followers_count"`
+	FollowingCount int64  `json:"following_count"`
+}
+
+func (cfg *apiConfig) profileToJson(ctx context.Context, user database.User) (profileJson, error) {
+	counts, err := cfg.queries.CountFollows(ctx, user.ID)
+	if err != nil {
+		return profileJson{}, err
+	}
+	ret := profileJson{
+		Id:             user.ID.String(),
+		CreatedAt:      user.CreatedAt.Format(time.RFC3339),
+		Handle:         user.Handle.String,
+		DisplayName:    user.DisplayName,
+		Bio:            user.Bio,
+		IsChirpyRed:    user.IsChirpyRed,
+		IsProtected:    user.IsProtected,
+		FollowersCount: counts.Followers,
+		FollowingCount: counts.Following,
+	}
+	if user.AvatarMediaID.Valid {
+		ret.AvatarUrl = "/api/media/" + user.AvatarMediaID.UUID.String()
+	}
+	return ret, nil
+}
+
+// getProfileHandler looks a user up by id or by handle, ignoring the case of
+// the handle. The ghost user behind erased accounts' tombstones has no
+// profile.
+func (cfg *apiConfig) getProfileHandler(rw http.ResponseWriter, r *http.Request) {
+	idOrHandle := r.PathValue("idOrHandle")
+
+	var user database.User
+	var err error
+	if id, perr := uuid.Parse(idOrHandle); perr == nil {
+		user, err = cfg.queries.SelectUserById(r.Context(), id)
+	} else if handleRegexp.MatchString(idOrHandle) {
+		user, err = cfg.queries.SelectUserByHandle(r.Context(), idOrHandle)
+	} else {
+		err = sql.ErrNoRows
+	}
+	if errors.Is(err, sql.ErrNoRows) || (err == nil && user.ID == ghostUserId) {
+		respondWithError(rw, http.StatusNotFound, "User not found")
+		return
+	}
+	if err != nil {
+		respondWithError(rw, http.StatusInternalServerError, "Something went wrong getting user")
+		return
+	}
+
+	ret, err := cfg.profileToJson(r.Context(), user)
+	if err != nil {
+		respondWithError(rw, http.StatusInternalServerError, "Something went wrong getting user")
+		return
+	}
+	respondWithJSON(rw, http.StatusOK, ret)
+}
+
+// profileChangesJson holds the profile fields of an update, nil when not
+// sent. An empty avatar_media_id removes the avatar.
+type profileChangesJson struct {
+	Handle        *string `json:"handle"`
+	DisplayName   *string `json:"display_name"`
+	Bio           *string `json:"bio"`
+	AvatarMediaId *string `json:"avatar_media_id"`
+}
+
+func (p profileChangesJson) empty() bool {
+	return p.Handle == nil && p.DisplayName == nil && p.Bio == nil && p.AvatarMediaId == nil
+}
+
+// applyProfileChanges validates the profile fields sent and copies them into
+// update. On failure it returns the status to answer with.
+func (cfg *apiConfig) applyProfileChanges(ctx context.Context, userId uuid.UUID, p profileChangesJson, update *database.UpdateUserProfileParams) (int, error) {
+	if p.Handle != nil {
+		if !handleRegexp.MatchString(*p.Handle) {
+			return http.StatusBadRequest, errors.New("Handle must be 1 to 30 letters, digits or underscores")
+		}
+		update.Handle = sql.NullString{String: *p.Handle, Valid: true}
+	}
+	if p.DisplayName != nil {
+		name := strings.TrimSpace(*p.DisplayName)
+		if utf8.RuneCountInString(name) > maxDisplayNameLength {
+			return http.StatusBadRequest, errors.New("Display name is too long")
+		}
+		update.DisplayName = removeProfanity(name)
+	}
+	if p.Bio != nil {
+		bio := strings.TrimSpace(*p.Bio)
+		if utf8.RuneCountInString(bio) > maxBioLength {
+			return http.StatusBadRequest, errors.New("Bio is too long")
+		}
+		update.Bio = removeProfanity(bio)
+	}
+	if p.AvatarMediaId != nil {
+		update.AvatarMediaID = uuid.NullUUID{}
+		if *p.AvatarMediaId != "" {
+			mediaId, err := uuid.Parse(*p.AvatarMediaId)
+			if err != nil {
+				return http.StatusBadRequest, errors.New("Invalid avatar_media_id")
+			}
+			m, err := cfg.queries.SelectOneMedia(ctx, mediaId)
+			if errors.Is(err, sql.ErrNoRows) || (err == nil && m.UserID != userId) {
+				return http.StatusBadRequest, errors.New("Avatar must be one of your uploads")
+			}
+			if err != nil {
+				return http.StatusInternalServerError, errors.New("Something went wrong getting media")
+			}
+			update.AvatarMediaID = uuid.NullUUID{UUID: m.ID, Valid: true}
+		}
+	}
+	return http.StatusOK, nil
+}
+
+func profileUpdateParams(user database.User) database.UpdateUserProfileParams {
+	return database.UpdateUserProfileParams{
+		Handle:        user.Handle,
+		DisplayName:   user.DisplayName,
+		Bio:           user.Bio,
+		AvatarMediaID: user.AvatarMediaID,
+		ID:            user.ID,
+	}
+}
+
+// putProfileHandler updates the profile fields sent, leaving out the others.
+// Unlike PUT /api/users it doesn't touch the email or password.
+func (cfg *apiConfig) putProfileHandler(rw http.ResponseWriter, r *http.Request) {
+	uidtok, err := cfg.authenticatedUserId(r)
+	if err != nil {
+		respondWithError(rw, http.StatusUnauthorized, "Something went wrong validating JWT")
+		return
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	params := profileChangesJson{}
+	err = decoder.Decode(&params)
+	if err != nil {
+		respondWithError(rw, http.StatusBadRequest, "Something went wrong decoding input")
+		return
+	}
+
+	user, err := cfg.queries.SelectUserById(r.Context(), uidtok)
+	if err != nil {
+		respondWithError(rw, http.StatusInternalServerError, "Something went wrong getting user")
+		return
+	}
+	update := profileUpdateParams(user)
+	status, err := cfg.applyProfileChanges(r.Context(), uidtok, params, &update)
+	if err != nil {
+		respondWithError(rw, status, err.Error())
+		return
+	}
+
+	user, err = cfg.queries.UpdateUserProfile(r.Context(), update)
+	if _, ok := uniqueViolation(err); ok {
+		respondWithError(rw, http.StatusConflict, "Handle is already taken")
+		return
+	}
+	if err != nil {
+		respondWithError(rw, http.StatusInternalServerError, "Something went wrong updating profile")
+		return
+	}
+
+	ret, err := cfg.profileToJson(r.Context(), user)
+	if err != nil {
+		respondWithError(rw, http.StatusInternalServerError, "Something went wrong updating profile")
+		return
+	}
+	respondWithJSON(rw, http.StatusOK, ret)
+}
+
+// uniqueViolation tells whether err comes from breaking a unique index, such
+// as taking a handle somebody else already has in another case, and returns
+// the name of the index.
+func uniqueViolation(err error) (string, bool) {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code.Name() == "unique_violation" {
+		return pqErr.Constraint, true
+	}
+	return "", false
+}
@@ -0,0 +1,27 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestGetProfileHidesGhost(t *testing.T) {
+	cfg := testConfig(t)
+	alice := createTestUser(t, cfg, "alice")
+
+	for _, c := range []struct {
+		idOrHandle string
+		want       int
+	}{
+		{alice.String(), http.StatusOK},
+		{"ALICE", http.StatusOK},
+		{ghostUserId.String(), http.StatusNotFound},
+		{"nobody", http.StatusNotFound},
+		{"not a handle!", http.StatusNotFound},
+	} {
+		rw := serve(t, cfg.getProfileHandler, alice, "/api/users/"+c.idOrHandle, map[string]string{"idOrHandle": c.idOrHandle})
+		if rw.Code != c.want {
+			t.Errorf("GET profile %q = %d, want %d", c.idOrHandle, rw.Code, c.want)
+		}
+	}
+}
@@ -0,0 +1,405 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Serux/chirpy/internal/auth"
+	"github.com/Serux/chirpy/internal/blob"
+	"github.com/Serux/chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+// These tests run the real queries and handlers, so they need a Postgres
+// database in CHIRPY_TEST_DB_URL and are skipped without one. Each test
+// migrates a schema of its own and drops it afterwards, nothing else in the
+// database is touched.
+
+const testJwtSecret = "test-secret"
+
+// testConfig returns an apiConfig on a freshly migrated schema.
+func testConfig(t *testing.T) *apiConfig {
+	t.Helper()
+	dbURL := os.Getenv("CHIRPY_TEST_DB_URL")
+	if dbURL == "" {
+		t.Skip("CHIRPY_TEST_DB_URL is not set")
+	}
+
+	admin, err := sql.Open("postgres", dbURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	schema := fmt.Sprintf("chirpy_test_%d", time.Now().UnixNano())
+	_, err = admin.Exec("CREATE SCHEMA " + schema)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		admin.Exec("DROP SCHEMA " + schema + " CASCADE")
+		admin.Close()
+	})
+
+	db, err := openDB(withConnParam(dbURL, "search_path", schema))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	files, err := filepath.Glob(filepath.Join("sql", "schema", "*.sql"))
+	if err != nil || len(files) == 0 {
+		t.Fatalf("no migrations found: %v", err)
+	}
+	sort.Strings(files)
+	for _, f := range files {
+		data, err := os.ReadFile(f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		up, _, _ := strings.Cut(string(data), "-- +goose Down")
+		_, err = db.Exec(up)
+		if err != nil {
+			t.Fatalf("migrating %s: %v", f, err)
+		}
+	}
+
+	blobs, err := blob.NewLocalStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &apiConfig{db: db, queries: database.New(db), jwtSecret: testJwtSecret, blobs: blobs}
+}
+
+func createTestUser(t *testing.T, cfg *apiConfig, handle string) uuid.UUID {
+	t.Helper()
+	user, err := cfg.queries.CreateUser(context.Background(), database.CreateUserParams{
+		Email:          handle + "@example.com",
+		HashedPassword: "password",
+		Handle:         sql.NullString{String: handle, Valid: true},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return user.ID
+}
+
+func createTestChirp(t *testing.T, cfg *apiConfig, author uuid.UUID, visibility, body string) uuid.UUID {
+	t.Helper()
+	return createTestReply(t, cfg, author, uuid.Nil, visibility, body)
+}
+
+// createTestReply creates a chirp replying to replyTo, or a new one for
+// uuid.Nil.
+func createTestReply(t *testing.T, cfg *apiConfig, author, replyTo uuid.UUID, visibility, body string) uuid.UUID {
+	t.Helper()
+	ctx := context.Background()
+	chirp, err := cfg.queries.CreateChirp(ctx, database.CreateChirpParams{
+		Body:       body,
+		UserID:     author,
+		ReplyToID:  uuid.NullUUID{UUID: replyTo, Valid: replyTo != uuid.Nil},
+		Visibility: visibility,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = indexChirpEntities(ctx, cfg.queries, chirp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return chirp.ID
+}
+
+// createTestMedia stores a small file for owner and attaches it to chirp,
+// unless chirp is uuid.Nil.
+func createTestMedia(t *testing.T, cfg *apiConfig, owner, chirp uuid.UUID) uuid.UUID {
+	t.Helper()
+	ctx := context.Background()
+	id := uuid.New()
+	for _, key := range []string{"media/" + id.String(), "media/" + id.String() + "-thumb"} {
+		err := cfg.blobs.Put(ctx, key, bytes.NewReader([]byte("image")))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	_, err := cfg.queries.CreateMedia(ctx, database.CreateMediaParams{
+		ID:                   id,
+		UserID:               owner,
+		ContentType:          "image/png",
+		SizeBytes:            5,
+		Width:                1,
+		Height:               1,
+		BlobKey:              "media/" + id.String(),
+		ThumbnailContentType: "image/png",
+		ThumbnailKey:         "media/" + id.String() + "-thumb",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if chirp != uuid.Nil {
+		err = cfg.queries.AttachChirpMedia(ctx, database.AttachChirpMediaParams{ChirpID: chirp, MediaID: id})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	return id
+}
+
+// serve calls handler the way the mux would, as viewer, or anonymously for
+// uuid.Nil.
+func serve(t *testing.T, handler http.HandlerFunc, viewer uuid.UUID, target string, pathValues map[string]string) *httptest.ResponseRecorder {
+	t.Helper()
+	r := httptest.NewRequest(http.MethodGet, target, nil)
+	for k, v := range pathValues {
+		r.SetPathValue(k, v)
+	}
+	if viewer != uuid.Nil {
+		token, err := auth.MakeJWT(viewer, testJwtSecret, time.Hour)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r.Header.Set("Authorization", "Bearer "+token)
+	}
+	rw := httptest.NewRecorder()
+	handler(rw, r)
+	return rw
+}
+
+// chirpIds returns the ids of a chirp list response, sorted.
+func chirpIds(t *testing.T, rw *httptest.ResponseRecorder) []string {
+	t.Helper()
+	if rw.Code != http.StatusOK {
+		t.Fatalf("got status %d: %s", rw.Code, rw.Body.String())
+	}
+	chirps := []struct {
+		Id string `json:"id"`
+	}{}
+	err := json.Unmarshal(rw.Body.Bytes(), &chirps)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ids := []string{}
+	for _, ch := range chirps {
+		ids = append(ids, ch.Id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// TestReadPathsAsViewers reads chirps of every visibility through every read
+// path as their author, a follower, a stranger, a blocked user and anonymous
+// viewers, and checks each sees exactly what they're allowed to.
+func TestReadPathsAsViewers(t *testing.T) {
+	cfg := testConfig(t)
+	ctx := context.Background()
+
+	author := createTestUser(t, cfg, "author")
+	follower := createTestUser(t, cfg, "follower")
+	stranger := createTestUser(t, cfg, "stranger")
+	blocked := createTestUser(t, cfg, "blocked")
+	protected := createTestUser(t, cfg, "protected")
+	host := createTestUser(t, cfg, "host")
+	anonymous := uuid.Nil
+
+	for _, followee := range []uuid.UUID{author, protected} {
+		_, err := cfg.queries.InsertFollow(ctx, database.InsertFollowParams{FollowerID: follower, FolloweeID: followee})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	_, err := cfg.queries.InsertBlock(ctx, database.InsertBlockParams{BlockerID: author, BlockedID: blocked})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = cfg.queries.UpdateProtected(ctx, database.UpdateProtectedParams{IsProtected: true, ID: protected})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Every chirp under test replies to the same public chirp, which makes
+	// them its thread.
+	root := createTestChirp(t, cfg, host, "public", "a thread")
+	public := createTestReply(t, cfg, author, root, "public", "zebra public")
+	followers := createTestReply(t, cfg, author, root, "followers", "zebra followers")
+	mentioned := createTestReply(t, cfg, author, root, "mentioned", "zebra mentioned @stranger")
+	trashed := createTestReply(t, cfg, author, root, "public", "zebra trashed")
+	_, err = cfg.queries.SoftDeleteChirp(ctx, database.SoftDeleteChirpParams{ID: trashed, UserID: author})
+	if err != nil {
+		t.Fatal(err)
+	}
+	onProtected := createTestReply(t, cfg, protected, root, "public", "zebra protected")
+
+	chirps := []uuid.UUID{public, followers, mentioned, trashed, onProtected}
+	authors := map[uuid.UUID]uuid.UUID{public: author, followers: author, mentioned: author, trashed: author, onProtected: protected}
+	canSee := map[uuid.UUID][]uuid.UUID{
+		public:      {author, follower, stranger, anonymous},
+		followers:   {author, follower},
+		mentioned:   {author, stranger},
+		trashed:     {},
+		onProtected: {follower},
+	}
+	viewers := map[string]uuid.UUID{"author": author, "follower": follower, "stranger": stranger, "blocked": blocked, "anonymous": anonymous}
+	following := map[uuid.UUID][]uuid.UUID{follower: {author, protected}}
+
+	// expected returns the sorted ids of the chirps viewer may see among
+	// those include keeps.
+	expected := func(viewer uuid.UUID, include func(chirp uuid.UUID) bool) []string {
+		ids := []string{}
+		for _, ch := range chirps {
+			if slices.Contains(canSee[ch], viewer) && include(ch) {
+				ids = append(ids, ch.String())
+			}
+		}
+		sort.Strings(ids)
+		return ids
+	}
+	all := func(uuid.UUID) bool { return true }
+
+	for {
+		n, err := cfg.fanOutChirps(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n == 0 {
+			break
+		}
+	}
+	list, err := cfg.queries.CreateList(ctx, database.CreateListParams{OwnerID: stranger, Name: "zebras"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, member := range []uuid.UUID{author, protected} {
+		_, err = cfg.queries.AddListMember(ctx, database.AddListMemberParams{ListID: list.ID, UserID: member, MaxMembers: maxListMembers})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for name, viewer := range viewers {
+		t.Run(name, func(t *testing.T) {
+			for _, ch := range chirps {
+				rw := serve(t, cfg.getChirpHandler, viewer, "/api/chirps/"+ch.String(), map[string]string{"chirpID": ch.String()})
+				want := http.StatusNotFound
+				if slices.Contains(canSee[ch], viewer) {
+					want = http.StatusOK
+				}
+				if rw.Code != want {
+					t.Errorf("GET /api/chirps/%s = %d, want %d", ch, rw.Code, want)
+				}
+			}
+
+			got := chirpIds(t, serve(t, cfg.getChirpsHandler, viewer, "/api/chirps?author_id="+author.String()+","+protected.String(), nil))
+			if want := expected(viewer, all); !slices.Equal(got, want) {
+				t.Errorf("GET /api/chirps = %v, want %v", got, want)
+			}
+
+			got = chirpIds(t, serve(t, cfg.getRepliesHandler, viewer, "/api/chirps/"+root.String()+"/replies", map[string]string{"chirpID": root.String()}))
+			if want := expected(viewer, all); !slices.Equal(got, want) {
+				t.Errorf("GET /api/chirps/{id}/replies = %v, want %v", got, want)
+			}
+
+			got = chirpIds(t, serve(t, cfg.searchChirpsHandler, viewer, "/api/search/chirps?q=zebra", nil))
+			if want := expected(viewer, all); !slices.Equal(got, want) {
+				t.Errorf("GET /api/search/chirps = %v, want %v", got, want)
+			}
+
+			got = chirpIds(t, serve(t, cfg.getListTimelineHandler, viewer, "/api/lists/"+list.ID.String()+"/timeline", map[string]string{"listID": list.ID.String()}))
+			if want := expected(viewer, all); !slices.Equal(got, want) {
+				t.Errorf("GET /api/lists/{id}/timeline = %v, want %v", got, want)
+			}
+
+			if viewer != anonymous {
+				got = chirpIds(t, serve(t, cfg.getHomeTimelineHandler, viewer, "/api/timeline/home", nil))
+				want := expected(viewer, func(ch uuid.UUID) bool {
+					return authors[ch] == viewer || slices.Contains(following[viewer], authors[ch])
+				})
+				if !slices.Equal(got, want) {
+					t.Errorf("GET /api/timeline/home = %v, want %v", got, want)
+				}
+			}
+		})
+	}
+
+	// Muting the author hides their replies from the thread, and the thread
+	// of a chirp the viewer can't see doesn't exist.
+	_, err = cfg.queries.InsertMute(ctx, database.InsertMuteParams{MuterID: follower, MutedID: author})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := chirpIds(t, serve(t, cfg.getRepliesHandler, follower, "/api/chirps/"+root.String()+"/replies", map[string]string{"chirpID": root.String()}))
+	if want := []string{onProtected.String()}; !slices.Equal(got, want) {
+		t.Errorf("GET /api/chirps/{id}/replies after muting = %v, want %v", got, want)
+	}
+	rw := serve(t, cfg.getRepliesHandler, stranger, "/api/chirps/"+followers.String()+"/replies", map[string]string{"chirpID": followers.String()})
+	if rw.Code != http.StatusNotFound {
+		t.Errorf("GET /api/chirps/{id}/replies of a hidden chirp = %d, want 404", rw.Code)
+	}
+}
+
+// TestMediaRoutesAsViewers checks media can only be fetched by those who can
+// see the chirp it's attached to, and by its owner.
+func TestMediaRoutesAsViewers(t *testing.T) {
+	cfg := testConfig(t)
+	ctx := context.Background()
+
+	author := createTestUser(t, cfg, "author")
+	follower := createTestUser(t, cfg, "follower")
+	stranger := createTestUser(t, cfg, "stranger")
+	anonymous := uuid.Nil
+	_, err := cfg.queries.InsertFollow(ctx, database.InsertFollowParams{FollowerID: follower, FolloweeID: author})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	onPublic := createTestMedia(t, cfg, author, createTestChirp(t, cfg, author, "public", "public"))
+	onFollowers := createTestMedia(t, cfg, author, createTestChirp(t, cfg, author, "followers", "followers"))
+	trashedChirp := createTestChirp(t, cfg, author, "public", "trashed")
+	onTrashed := createTestMedia(t, cfg, author, trashedChirp)
+	_, err = cfg.queries.SoftDeleteChirp(ctx, database.SoftDeleteChirpParams{ID: trashedChirp, UserID: author})
+	if err != nil {
+		t.Fatal(err)
+	}
+	unattached := createTestMedia(t, cfg, author, uuid.Nil)
+
+	cases := []struct {
+		name   string
+		media  uuid.UUID
+		canSee []uuid.UUID
+		public bool
+	}{
+		{"public chirp", onPublic, []uuid.UUID{author, follower, stranger, anonymous}, true},
+		{"followers chirp", onFollowers, []uuid.UUID{author, follower}, false},
+		{"trashed chirp", onTrashed, []uuid.UUID{author}, false},
+		{"unattached", unattached, []uuid.UUID{author}, false},
+	}
+	viewers := map[string]uuid.UUID{"author": author, "follower": follower, "stranger": stranger, "anonymous": anonymous}
+	handlers := map[string]http.HandlerFunc{"media": cfg.getMediaHandler, "thumbnail": cfg.getMediaThumbnailHandler}
+	for _, c := range cases {
+		for viewerName, viewer := range viewers {
+			for handlerName, handler := range handlers {
+				rw := serve(t, handler, viewer, "/api/media/"+c.media.String(), map[string]string{"mediaID": c.media.String()})
+				want := http.StatusNotFound
+				if slices.Contains(c.canSee, viewer) {
+					want = http.StatusOK
+				}
+				if rw.Code != want {
+					t.Errorf("%s %s as %s = %d, want %d", c.name, handlerName, viewerName, rw.Code, want)
+					continue
+				}
+				if rw.Code == http.StatusOK && strings.HasPrefix(rw.Header().Get("Cache-Control"), "public") != c.public {
+					t.Errorf("%s %s as %s has Cache-Control %q", c.name, handlerName, viewerName, rw.Header().Get("Cache-Control"))
+				}
+			}
+		}
+	}
+}
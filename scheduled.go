@@ -0,0 +1,191 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/Serux/chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+const scheduledBatchSize = 100
+const maxScheduleAhead = 365 * 24 * time.Hour
+
+// parsePublishAt validates the publish_at of a scheduled chirp: an RFC3339
+// time in the future, and not more than a year away.
+func parsePublishAt(value string) (time.Time, error) {
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, errors.New("Invalid publish_at, expected an RFC3339 time")
+	}
+	now := time.Now()
+	if !t.After(now) {
+		return time.Time{}, errors.New("publish_at must be in the future")
+	}
+	if t.After(now.Add(maxScheduleAhead)) {
+		return time.Time{}, errors.New("publish_at can be at most a year away")
+	}
+	return t.UTC(), nil
+}
+
+// publishDueChirps publishes one batch of scheduled chirps whose time has
+// come and returns how many it published. The due rows stay locked until the
+// transaction commits, and other instances skip them instead of waiting. If
+// anything fails the whole batch rolls back and is picked up again on the next
+// run, so every chirp gets published at least once.
+func (cfg *apiConfig) publishDueChirps(ctx context.Context) (int, error) {
+	tx, err := cfg.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+	q := cfg.queries.WithTx(tx)
+
+	due, err := q.SelectDueScheduledChirps(ctx, scheduledBatchSize)
+	if err != nil {
+		return 0, err
+	}
+	for _, ch := range due {
+		published, err := q.PublishChirp(ctx, ch.ID)
+		if err != nil {
+			return 0, err
+		}
+		_, err = indexChirpEntities(ctx, q, published)
+		if err != nil {
+			return 0, err
+		}
+	}
+	return len(due), tx.Commit()
+}
+
+// runScheduler publishes due chirps every tick until ctx is cancelled. A full
+// batch means there is probably more waiting, so it goes again right away.
+func (cfg *apiConfig) runScheduler(ctx context.Context, every time.Duration) {
+	ticker := time.NewTicker(every)
+	defer ticker.Stop()
+	for {
+		n, err := cfg.publishDueChirps(ctx)
+		if err != nil {
+			fmt.Println("ERROR PUBLISHING SCHEDULED CHIRPS", err)
+		}
+		if err == nil && n == scheduledBatchSize {
+			continue
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (cfg *apiConfig) getScheduledChirpsHandler(rw http.ResponseWriter, r *http.Request) {
+	uidtok, err := cfg.authenticatedUserId(r)
+	if err != nil {
+		respondWithError(rw, http.StatusUnauthorized, "Something went wrong validating JWT")
+		return
+	}
+
+	chirps, err := cfg.queries.SelectScheduledChirpsUser(r.Context(), uidtok)
+	if err != nil {
+		respondWithError(rw, http.StatusInternalServerError, "Something went wrong getting scheduled chirps")
+		return
+	}
+
+	ret, err := cfg.chirpsToJson(r.Context(), uidtok, chirps)
+	if err != nil {
+		respondWithError(rw, http.StatusInternalServerError, "Something went wrong loading chirps")
+		return
+	}
+	respondWithJSON(rw, http.StatusOK, ret)
+}
+
+func (cfg *apiConfig) putScheduledChirpHandler(rw http.ResponseWriter, r *http.Request) {
+	type requestJson struct {
+		PublishAt string `json:"publish_at"`
+	}
+
+	uidtok, err := cfg.authenticatedUserId(r)
+	if err != nil {
+		respondWithError(rw, http.StatusUnauthorized, "Something went wrong validating JWT")
+		return
+	}
+	chirpId, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		respondWithError(rw, http.StatusNotFound, "Scheduled chirp not found")
+		return
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	params := requestJson{}
+	err = decoder.Decode(&params)
+	if err != nil {
+		respondWithError(rw, http.StatusBadRequest, "Something went wrong decoding input")
+		return
+	}
+	publishAt, err := parsePublishAt(params.PublishAt)
+	if err != nil {
+		respondWithError(rw, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	polls, err := cfg.queries.SelectPollsForChirps(r.Context(), []uuid.UUID{chirpId})
+	if err != nil {
+		respondWithError(rw, http.StatusInternalServerError, "Something went wrong getting poll")
+		return
+	}
+	if len(polls) > 0 && !publishAt.Before(polls[0].ClosesAt) {
+		respondWithError(rw, http.StatusBadRequest, "publish_at must be before the chirp's poll closes")
+		return
+	}
+
+	chirp, err := cfg.queries.RescheduleChirp(r.Context(), database.RescheduleChirpParams{
+		PublishAt: sql.NullTime{Time: publishAt, Valid: true},
+		ID:        chirpId,
+		UserID:    uidtok,
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(rw, http.StatusNotFound, "Scheduled chirp not found")
+		return
+	}
+	if err != nil {
+		respondWithError(rw, http.StatusInternalServerError, "Something went wrong rescheduling chirp")
+		return
+	}
+
+	ret, err := cfg.chirpsToJson(r.Context(), uidtok, []database.Chirp{chirp})
+	if err != nil {
+		respondWithError(rw, http.StatusInternalServerError, "Something went wrong loading chirp")
+		return
+	}
+	respondWithJSON(rw, http.StatusOK, ret[0])
+}
+
+func (cfg *apiConfig) deleteScheduledChirpHandler(rw http.ResponseWriter, r *http.Request) {
+	uidtok, err := cfg.authenticatedUserId(r)
+	if err != nil {
+		respondWithError(rw, http.StatusUnauthorized, "Something went wrong validating JWT")
+		return
+	}
+	chirpId, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		respondWithError(rw, http.StatusNotFound, "Scheduled chirp not found")
+		return
+	}
+
+	deleted, err := cfg.queries.DeleteScheduledChirp(r.Context(), database.DeleteScheduledChirpParams{ID: chirpId, UserID: uidtok})
+	if err != nil {
+		respondWithError(rw, http.StatusInternalServerError, "Something went wrong cancelling chirp")
+		return
+	}
+	if deleted == 0 {
+		respondWithError(rw, http.StatusNotFound, "Scheduled chirp not found")
+		return
+	}
+	rw.WriteHeader(http.StatusNoContent)
+}
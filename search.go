@@ -0,0 +1,250 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/Serux/chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+type searchResultJson struct {
+	fullChirpJsonDb
+	Rank    float64 `json:"rank"`
+	Snippet string  `json:"snippet"`
+}
+
+type searchQuery struct {
+	tsquery string
+	from    string
+	since   time.Time
+	until   time.Time
+}
+
+// parseSearchQuery turns the q parameter into a to_tsquery expression plus
+// the from:, since: and until: operators. Quoted text becomes a phrase, a
+// trailing '*' a prefix match and a leading '-' excludes the term; every
+// other term must match.
+func parseSearchQuery(q string) (searchQuery, error) {
+	sq := searchQuery{}
+	terms := []string{}
+
+	for _, token := range splitSearchTokens(q) {
+		if strings.HasPrefix(token, `"`) {
+			words := searchWords(token)
+			if len(words) > 0 {
+				terms = append(terms, "("+strings.Join(words, " <-> ")+")")
+			}
+			continue
+		}
+
+		if name, value, ok := strings.Cut(token, ":"); ok && value != "" {
+			switch strings.ToLower(name) {
+			case "from":
+				sq.from = strings.TrimPrefix(value, "@")
+				continue
+			case "since":
+				t, err := parseTimeBound(value, false)
+				if err != nil {
+					return searchQuery{}, fmt.Errorf("since must be a date (YYYY-MM-DD) or RFC3339 time")
+				}
+				sq.since = t
+				continue
+			case "until":
+				t, err := parseTimeBound(value, true)
+				if err != nil {
+					return searchQuery{}, fmt.Errorf("until must be a date (YYYY-MM-DD) or RFC3339 time")
+				}
+				sq.until = t
+				continue
+			}
+		}
+
+		negate := strings.HasPrefix(token, "-")
+		prefix := strings.HasSuffix(token, "*")
+		words := searchWords(token)
+		if len(words) == 0 {
+			continue
+		}
+		if prefix {
+			words[len(words)-1] += ":*"
+		}
+		term := strings.Join(words, " <-> ")
+		if len(words) > 1 {
+			term = "(" + term + ")"
+		}
+		if negate {
+			term = "!" + term
+		}
+		terms = append(terms, term)
+	}
+
+	if len(terms) == 0 {
+		return searchQuery{}, fmt.Errorf("search needs at least one word")
+	}
+	sq.tsquery = strings.Join(terms, " & ")
+	return sq, nil
+}
+
+// splitSearchTokens splits on whitespace, keeping double quoted phrases
+// (quotes included) as a single token.
+func splitSearchTokens(q string) []string {
+	tokens := []string{}
+	var current strings.Builder
+	inQuotes := false
+	for _, r := range q {
+		switch {
+		case r == '"':
+			if inQuotes {
+				current.WriteRune(r)
+				tokens = append(tokens, current.String())
+				current.Reset()
+			} else {
+				if current.Len() > 0 {
+					tokens = append(tokens, current.String())
+					current.Reset()
+				}
+				current.WriteRune(r)
+			}
+			inQuotes = !inQuotes
+		case unicode.IsSpace(r) && !inQuotes:
+			if current.Len() > 0 {
+				tokens = append(tokens, current.String())
+				current.Reset()
+			}
+		default:
+			current.WriteRune(r)
+		}
+	}
+	if current.Len() > 0 {
+		tokens = append(tokens, current.String())
+	}
+	return tokens
+}
+
+// searchWords keeps only letters and digits, so nothing from the user can
+// reach to_tsquery as an operator.
+func searchWords(token string) []string {
+	return strings.FieldsFunc(strings.ToLower(token), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// parseTimeBound accepts a date (YYYY-MM-DD) or an RFC3339 time. As an upper
+// bound a bare date includes the whole day, so until=2024-01-31 still
+// matches chirps from the 31st.
+func parseTimeBound(value string, upper bool) (time.Time, error) {
+	if t, err := time.Parse(time.DateOnly, value); err == nil {
+		if upper {
+			t = t.Add(24 * time.Hour)
+		}
+		return t, nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return t.UTC(), nil
+}
+
+func (cfg *apiConfig) searchChirpsHandler(rw http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	sq, err := parseSearchQuery(query.Get("q"))
+	if err != nil {
+		respondWithError(rw, http.StatusBadRequest, err.Error())
+		return
+	}
+	limit, err := pageLimit(r)
+	if err != nil {
+		respondWithError(rw, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	viewer := cfg.viewerId(r)
+	sensitiveContent, err := cfg.sensitiveContentPreference(r.Context(), viewer)
+	if err != nil {
+		respondWithError(rw, http.StatusInternalServerError, "Something went wrong getting preferences")
+		return
+	}
+
+	params := database.SearchChirpsParams{
+		Query:         sq.tsquery,
+		ViewerID:      viewer,
+		HideSensitive: sensitiveContent == "hide",
+		Since:         sql.NullTime{Time: sq.since, Valid: !sq.since.IsZero()},
+		Until:         sql.NullTime{Time: sq.until, Valid: !sq.until.IsZero()},
+		MaxResults:    int32(limit),
+	}
+
+	if sq.from != "" {
+		uid, err := uuid.Parse(sq.from)
+		if err != nil {
+			users, err := cfg.queries.SelectUsersByHandles(r.Context(), []string{strings.ToLower(sq.from)})
+			if err != nil {
+				respondWithError(rw, http.StatusInternalServerError, "Something went wrong searching chirps")
+				return
+			}
+			if len(users) == 0 {
+				respondWithJSON(rw, http.StatusOK, []searchResultJson{})
+				return
+			}
+			uid = users[0].ID
+		}
+		params.UserID = uuid.NullUUID{UUID: uid, Valid: true}
+	}
+
+	if query.Has("cursor") {
+		parts, err := decodeCursor(query.Get("cursor"), 2)
+		if err != nil {
+			respondWithError(rw, http.StatusBadRequest, "Invalid cursor")
+			return
+		}
+		rank, err := strconv.ParseFloat(parts[0], 64)
+		if err != nil {
+			respondWithError(rw, http.StatusBadRequest, "Invalid cursor")
+			return
+		}
+		id, err := uuid.Parse(parts[1])
+		if err != nil {
+			respondWithError(rw, http.StatusBadRequest, "Invalid cursor")
+			return
+		}
+		params.CursorRank = sql.NullFloat64{Float64: rank, Valid: true}
+		params.CursorID = id
+	}
+
+	rows, err := cfg.queries.SearchChirps(r.Context(), params)
+	if err != nil {
+		respondWithError(rw, http.StatusInternalServerError, "Something went wrong searching chirps")
+		return
+	}
+
+	chirps := []database.Chirp{}
+	for _, row := range rows {
+		chirps = append(chirps, row.Chirp)
+	}
+	chirpsJson, err := cfg.chirpsToJson(r.Context(), viewer, chirps)
+	if err != nil {
+		respondWithError(rw, http.StatusInternalServerError, "Something went wrong loading chirps")
+		return
+	}
+
+	cfg.recordImpressions(viewer, chirpsJson)
+
+	ret := []searchResultJson{}
+	for i, row := range rows {
+		ret = append(ret, searchResultJson{fullChirpJsonDb: chirpsJson[i], Rank: row.Rank, Snippet: row.Snippet})
+	}
+
+	if len(rows) == limit {
+		last := rows[len(rows)-1]
+		setPageLinks(rw, r, encodeCursor(strconv.FormatFloat(last.Rank, 'g', -1, 64), last.Chirp.ID.String()), "")
+	}
+	respondWithJSON(rw, http.StatusOK, ret)
+}
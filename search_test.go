@@ -0,0 +1,65 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseSearchQuery(t *testing.T) {
+	day := func(y int, m time.Month, d int) time.Time {
+		return time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+	}
+	for _, c := range []struct {
+		name    string
+		q       string
+		want    searchQuery
+		wantErr bool
+	}{
+		{"one word", "Go", searchQuery{tsquery: "go"}, false},
+		{"every word must match", "go rust", searchQuery{tsquery: "go & rust"}, false},
+		{"phrase", `"hello world" go`, searchQuery{tsquery: "(hello <-> world) & go"}, false},
+		{"unterminated phrase", `"hello world`, searchQuery{tsquery: "(hello <-> world)"}, false},
+		{"prefix", "gopher*", searchQuery{tsquery: "gopher:*"}, false},
+		{"exclude", "go -spam", searchQuery{tsquery: "go & !spam"}, false},
+		{"exclude joined words", "-foo-bar", searchQuery{tsquery: "!(foo <-> bar)"}, false},
+
+		// Operators
+		{"from", "from:bob go", searchQuery{tsquery: "go", from: "bob"}, false},
+		{"from with at", "from:@bob go", searchQuery{tsquery: "go", from: "bob"}, false},
+		{"operator case", "FROM:bob go", searchQuery{tsquery: "go", from: "bob"}, false},
+		{"since date", "since:2024-01-02 go", searchQuery{tsquery: "go", since: day(2024, 1, 2)}, false},
+		{"until date takes the whole day", "until:2024-01-31 go", searchQuery{tsquery: "go", until: day(2024, 2, 1)}, false},
+		{"since rfc3339 in utc", "since:2024-01-02T03:04:05+02:00 go", searchQuery{tsquery: "go", since: time.Date(2024, 1, 2, 1, 4, 5, 0, time.UTC)}, false},
+		{"since and until", "go since:2024-01-01 until:2024-01-31", searchQuery{tsquery: "go", since: day(2024, 1, 1), until: day(2024, 2, 1)}, false},
+		{"empty operator is a word", "from: go", searchQuery{tsquery: "from & go"}, false},
+		{"unknown operator is words", "lang:go", searchQuery{tsquery: "(lang <-> go)"}, false},
+		{"operators only", "from:bob since:2024-01-01", searchQuery{}, true},
+
+		// Malformed dates
+		{"since month out of range", "since:2024-13-01 go", searchQuery{}, true},
+		{"since unpadded", "since:2024-1-2 go", searchQuery{}, true},
+		{"until word", "until:yesterday go", searchQuery{}, true},
+		{"until without zone", "until:2024-01-02T03:04:05 go", searchQuery{}, true},
+
+		// Nothing from the user reaches to_tsquery as an operator.
+		{"tsquery operators", "a & b | !c", searchQuery{tsquery: "a & b & c"}, false},
+		{"followed by", "foo<->bar", searchQuery{tsquery: "(foo <-> bar)"}, false},
+		{"weights and prefix syntax", "go:*A", searchQuery{tsquery: "(go <-> a)"}, false},
+		{"parens and quotes", `'go') | ('rust`, searchQuery{tsquery: "go & rust"}, false},
+		{"sql", "'); DROP TABLE chirps;--", searchQuery{tsquery: "drop & table & chirps"}, false},
+		{"backslashes", `\\go\`, searchQuery{tsquery: "go"}, false},
+		{"punctuation only", "& | ! ( ) :* <->", searchQuery{}, true},
+		{"empty phrase", `""`, searchQuery{}, true},
+		{"empty", "", searchQuery{}, true},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			got, err := parseSearchQuery(c.q)
+			if (err != nil) != c.wantErr {
+				t.Fatalf("parseSearchQuery(%q) error = %v, want error %v", c.q, err, c.wantErr)
+			}
+			if got != c.want {
+				t.Errorf("parseSearchQuery(%q) = %+v, want %+v", c.q, got, c.want)
+			}
+		})
+	}
+}
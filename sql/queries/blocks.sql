@@ -0,0 +1,43 @@
+-- name: InsertBlock :execrows
+INSERT INTO blocks (blocker_id, blocked_id, created_at)
+VALUES ($1, $2, NOW())
+ON CONFLICT (blocker_id, blocked_id) DO NOTHING;
+
+-- name: DeleteBlock :execrows
+DELETE FROM blocks
+WHERE blocks.blocker_id = $1
+AND blocks.blocked_id = $2;
+
+-- name: IsBlockedBetween :one
+-- Whether either user blocked the other.
+SELECT EXISTS (
+    SELECT 1 FROM blocks
+    WHERE (blocks.blocker_id = $1 AND blocks.blocked_id = $2)
+    OR (blocks.blocker_id = $2 AND blocks.blocked_id = $1)
+) AS blocked;
+
+-- name: SelectBlockedUserIds :many
+-- Everybody the user blocked or was blocked by.
+SELECT blocks.blocked_id AS user_id FROM blocks WHERE blocks.blocker_id = $1
+UNION
+SELECT blocks.blocker_id FROM blocks WHERE blocks.blocked_id = $1;
+
+-- name: DeleteFollowsBetween :exec
+DELETE FROM follows
+WHERE (follows.follower_id = $1 AND follows.followee_id = $2)
+OR (follows.follower_id = $2 AND follows.followee_id = $1);
+
+-- name: DeleteTimelineEntriesBetween :exec
+DELETE FROM timeline_entries
+WHERE (timeline_entries.user_id = $1 AND timeline_entries.author_id = $2)
+OR (timeline_entries.user_id = $2 AND timeline_entries.author_id = $1);
+
+-- name: InsertMute :execrows
+INSERT INTO mutes (muter_id, muted_id, created_at)
+VALUES ($1, $2, NOW())
+ON CONFLICT (muter_id, muted_id) DO NOTHING;
+
+-- name: DeleteMute :execrows
+DELETE FROM mutes
+WHERE mutes.muter_id = $1
+AND mutes.muted_id = $2;
\ No newline at end of file
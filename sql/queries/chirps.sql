@@ -1,32 +1,224 @@
 -- name: CreateChirp :one
-INSERT INTO chirps (id, created_at, updated_at, body,user_id)
+INSERT INTO chirps (id, created_at, updated_at, body,user_id, reply_to_id, status, publish_at, visibility, content_warning, sensitive)
 VALUES (
     gen_random_uuid(),
     NOW(),
     NOW(),
-    $1,
-    $2
+    sqlc.arg('body'),
+    sqlc.arg('user_id'),
+    sqlc.narg('reply_to_id'),
+    CASE WHEN sqlc.narg('publish_at')::timestamp IS NULL THEN 'published' ELSE 'scheduled' END,
+    sqlc.narg('publish_at'),
+    sqlc.arg('visibility'),
+    sqlc.narg('content_warning'),
+    sqlc.arg('sensitive')
 )
 RETURNING *;
 
 -- name: SelectAllChirps :many
 SELECT * FROM chirps 
+WHERE chirps.status = 'published'
+AND chirps.deleted_at IS NULL
+AND chirps.visibility = 'public'
+AND NOT EXISTS (
+    SELECT 1 FROM users
+    WHERE users.id = chirps.user_id
+    AND users.is_protected
+)
 ORDER BY chirps.created_at;
 
 -- name: SelectAllChirpsUser :many
 SELECT * FROM chirps 
 WHERE user_id = $1 
+AND chirps.status = 'published'
+AND chirps.deleted_at IS NULL
+AND chirps.visibility = 'public'
+AND NOT EXISTS (
+    SELECT 1 FROM users
+    WHERE users.id = chirps.user_id
+    AND users.is_protected
+)
 ORDER BY chirps.created_at ASC;
 
+-- name: SelectChirpsPageAsc :many
+-- Muted users only show up when asked for by author_id.
+SELECT * FROM chirps
+WHERE chirps.status = 'published'
+AND chirps.deleted_at IS NULL
+AND chirp_is_visible(chirps.visibility, chirps.id, chirps.user_id, sqlc.arg('viewer_id'))
+AND (NOT sqlc.arg('hide_sensitive')::bool OR chirps.user_id = sqlc.arg('viewer_id') OR (NOT chirps.sensitive AND chirps.content_warning IS NULL))
+AND (COALESCE(cardinality(sqlc.arg('author_ids')::uuid[]), 0) > 0 OR NOT chirp_is_muted(chirps.user_id, sqlc.arg('viewer_id')))
+AND (COALESCE(cardinality(sqlc.arg('author_ids')::uuid[]), 0) = 0 OR chirps.user_id = ANY(sqlc.arg('author_ids')::uuid[]))
+AND (sqlc.narg('tag')::text IS NULL OR EXISTS (
+    SELECT 1 FROM chirp_hashtags
+    JOIN hashtags ON hashtags.id = chirp_hashtags.hashtag_id
+    WHERE chirp_hashtags.chirp_id = chirps.id
+    AND hashtags.tag = sqlc.narg('tag')
+))
+AND (sqlc.narg('since')::timestamp IS NULL OR chirps.created_at >= sqlc.narg('since'))
+AND (sqlc.narg('until')::timestamp IS NULL OR chirps.created_at < sqlc.narg('until'))
+AND (sqlc.narg('contains')::text IS NULL OR chirps.body ILIKE '%' || sqlc.narg('contains') || '%' ESCAPE '\')
+AND (NOT sqlc.arg('exclude_replies')::boolean OR chirps.reply_to_id IS NULL)
+AND (sqlc.narg('has_media')::boolean IS NULL OR sqlc.narg('has_media') = EXISTS (
+    SELECT 1 FROM chirp_media
+    WHERE chirp_media.chirp_id = chirps.id
+))
+AND (sqlc.narg('cursor_created_at')::timestamp IS NULL
+    OR (chirps.created_at, chirps.id) > (sqlc.narg('cursor_created_at'), sqlc.arg('cursor_id')::uuid))
+ORDER BY chirps.created_at ASC, chirps.id ASC
+LIMIT sqlc.arg('max_results');
+
+-- name: SelectChirpsPageDesc :many
+-- Muted users only show up when asked for by author_id.
+SELECT * FROM chirps
+WHERE chirps.status = 'published'
+AND chirps.deleted_at IS NULL
+AND chirp_is_visible(chirps.visibility, chirps.id, chirps.user_id, sqlc.arg('viewer_id'))
+AND (NOT sqlc.arg('hide_sensitive')::bool OR chirps.user_id = sqlc.arg('viewer_id') OR (NOT chirps.sensitive AND chirps.content_warning IS NULL))
+AND (COALESCE(cardinality(sqlc.arg('author_ids')::uuid[]), 0) > 0 OR NOT chirp_is_muted(chirps.user_id, sqlc.arg('viewer_id')))
+AND (COALESCE(cardinality(sqlc.arg('author_ids')::uuid[]), 0) = 0 OR chirps.user_id = ANY(sqlc.arg('author_ids')::uuid[]))
+AND (sqlc.narg('tag')::text IS NULL OR EXISTS (
+    SELECT 1 FROM chirp_hashtags
+    JOIN hashtags ON hashtags.id = chirp_hashtags.hashtag_id
+    WHERE chirp_hashtags.chirp_id = chirps.id
+    AND hashtags.tag = sqlc.narg('tag')
+))
+AND (sqlc.narg('since')::timestamp IS NULL OR chirps.created_at >= sqlc.narg('since'))
+AND (sqlc.narg('until')::timestamp IS NULL OR chirps.created_at < sqlc.narg('until'))
+AND (sqlc.narg('contains')::text IS NULL OR chirps.body ILIKE '%' || sqlc.narg('contains') || '%' ESCAPE '\')
+AND (NOT sqlc.arg('exclude_replies')::boolean OR chirps.reply_to_id IS NULL)
+AND (sqlc.narg('has_media')::boolean IS NULL OR sqlc.narg('has_media') = EXISTS (
+    SELECT 1 FROM chirp_media
+    WHERE chirp_media.chirp_id = chirps.id
+))
+AND (sqlc.narg('cursor_created_at')::timestamp IS NULL
+    OR (chirps.created_at, chirps.id) < (sqlc.narg('cursor_created_at'), sqlc.arg('cursor_id')::uuid))
+ORDER BY chirps.created_at DESC, chirps.id DESC
+LIMIT sqlc.arg('max_results');
+
 -- name: SelectOneChirps :one
 SELECT * FROM chirps 
-WHERE chirps.id = $1;
+WHERE chirps.id = sqlc.arg('id')
+AND chirps.status = 'published'
+AND chirps.deleted_at IS NULL
+AND chirp_is_visible(chirps.visibility, chirps.id, chirps.user_id, sqlc.arg('viewer_id')::uuid);
+
+-- name: SelectRepliesPage :many
+-- The replies to a chirp the viewer can see, oldest first. Replies from
+-- muted users are left out.
+SELECT * FROM chirps
+WHERE chirps.reply_to_id = sqlc.arg('chirp_id')::uuid
+AND chirps.status = 'published'
+AND chirps.deleted_at IS NULL
+AND chirp_is_visible(chirps.visibility, chirps.id, chirps.user_id, sqlc.arg('viewer_id')::uuid)
+AND NOT chirp_is_muted(chirps.user_id, sqlc.arg('viewer_id')::uuid)
+AND (NOT sqlc.arg('hide_sensitive')::bool OR chirps.user_id = sqlc.arg('viewer_id')::uuid OR (NOT chirps.sensitive AND chirps.content_warning IS NULL))
+AND (sqlc.narg('cursor_created_at')::timestamp IS NULL
+    OR (chirps.created_at, chirps.id) > (sqlc.narg('cursor_created_at'), sqlc.arg('cursor_id')::uuid))
+ORDER BY chirps.created_at ASC, chirps.id ASC
+LIMIT sqlc.arg('max_results');
+
+-- name: DeleteAllChirps :exec
+DELETE FROM chirps;
+
+-- name: SelectScheduledChirpsUser :many
+SELECT * FROM chirps
+WHERE chirps.user_id = $1
+AND chirps.status = 'scheduled'
+AND chirps.deleted_at IS NULL
+ORDER BY chirps.publish_at ASC, chirps.id ASC;
 
--- name: DeleteByIdChirps :exec
+-- name: RescheduleChirp :one
+UPDATE chirps
+SET publish_at = $1, updated_at = NOW()
+WHERE chirps.id = $2
+AND chirps.user_id = $3
+AND chirps.status = 'scheduled'
+AND chirps.deleted_at IS NULL
+RETURNING *;
+
+-- name: DeleteScheduledChirp :execrows
 DELETE FROM chirps
 WHERE chirps.id = $1
 AND chirps.user_id = $2
-;
+AND chirps.status = 'scheduled'
+AND chirps.deleted_at IS NULL;
 
--- name: DeleteAllChirps :exec
-DELETE FROM chirps;
\ No newline at end of file
+-- name: SelectDueScheduledChirps :many
+-- Rows locked by another instance are skipped rather than waited on, so
+-- several schedulers can share the work.
+SELECT * FROM chirps
+WHERE chirps.status = 'scheduled'
+AND chirps.deleted_at IS NULL
+AND chirps.publish_at <= NOW()
+ORDER BY chirps.publish_at ASC
+LIMIT $1
+FOR UPDATE SKIP LOCKED;
+
+-- name: PublishChirp :one
+UPDATE chirps
+SET status = 'published', created_at = NOW(), updated_at = NOW()
+WHERE chirps.id = $1
+RETURNING *;
+
+-- name: SoftDeleteChirp :execrows
+UPDATE chirps
+SET deleted_at = NOW()
+WHERE chirps.id = $1
+AND chirps.user_id = $2
+AND chirps.deleted_at IS NULL;
+
+-- name: SelectTrashChirpsUser :many
+SELECT * FROM chirps
+WHERE chirps.user_id = sqlc.arg('user_id')
+AND chirps.deleted_at > NOW() - make_interval(secs => sqlc.arg('retention_seconds')::float8)
+ORDER BY chirps.deleted_at DESC, chirps.id DESC;
+
+-- name: RestoreChirp :one
+UPDATE chirps
+SET deleted_at = NULL
+WHERE chirps.id = sqlc.arg('id')
+AND chirps.user_id = sqlc.arg('user_id')
+AND chirps.deleted_at > NOW() - make_interval(secs => sqlc.arg('retention_seconds')::float8)
+RETURNING *;
+
+-- name: SelectExpiredDeletedChirps :many
+SELECT * FROM chirps
+WHERE chirps.deleted_at <= NOW() - make_interval(secs => sqlc.arg('retention_seconds')::float8)
+ORDER BY chirps.deleted_at ASC
+LIMIT sqlc.arg('max_results')
+FOR UPDATE SKIP LOCKED;
+
+-- name: DeleteChirpsByIds :exec
+DELETE FROM chirps
+WHERE chirps.id = ANY(sqlc.arg('ids')::uuid[]);
+
+-- name: UpdateChirpLabels :one
+UPDATE chirps
+SET content_warning = $1, sensitive = $2, updated_at = NOW()
+WHERE chirps.id = $3
+AND chirps.user_id = $4
+AND chirps.labels_forced = FALSE
+AND chirps.deleted_at IS NULL
+RETURNING *;
+
+-- name: ForceChirpLabels :one
+UPDATE chirps
+SET content_warning = $1, sensitive = $2, labels_forced = TRUE, updated_at = NOW()
+WHERE chirps.id = $3
+AND chirps.deleted_at IS NULL
+RETURNING *;
+
+-- name: UpdateChirpEntities :one
+UPDATE chirps
+SET entities = $1
+WHERE chirps.id = $2
+RETURNING *;
+
+-- name: SelectChirpsMissingEntities :many
+-- Chirps written before entities were stored, for the backfill.
+SELECT * FROM chirps
+WHERE chirps.status = 'published'
+AND chirps.entities = '{}'::jsonb
+ORDER BY chirps.id
+LIMIT $1;
\ No newline at end of file
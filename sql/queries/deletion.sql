@@ -0,0 +1,69 @@
+-- name: ScheduleUserDeletion :one
+UPDATE users
+SET deletion_scheduled_at = NOW() + make_interval(secs => sqlc.arg('grace_seconds')::float8), updated_at = NOW()
+WHERE id = sqlc.arg('id')
+RETURNING *;
+
+-- name: CancelUserDeletion :exec
+UPDATE users
+SET deletion_scheduled_at = NULL, updated_at = NOW()
+WHERE id = $1;
+
+-- name: SelectUsersDueForDeletion :many
+SELECT * FROM users
+WHERE users.deletion_scheduled_at <= NOW()
+ORDER BY users.deletion_scheduled_at
+LIMIT $1
+FOR UPDATE SKIP LOCKED;
+
+-- name: EnsureGhostUser :exec
+-- The ghost user is seeded by the migration; this only brings it back after
+-- the dev reset wiped the users table. A real account holding its email makes
+-- this fail instead of being skipped.
+INSERT INTO users (id, created_at, updated_at, email, hashed_password, display_name)
+VALUES ($1, NOW(), NOW(), 'ghost@chirpy.invalid', md5(random()::text), 'Deleted user')
+ON CONFLICT (id) DO NOTHING;
+
+-- name: TombstoneRepliedChirpsUser :many
+-- Hands the user's chirps that somebody else replied to over to the ghost
+-- user with everything identifying stripped, so the replies keep pointing
+-- at a chirp. Tombstones aren't published, so no read path serves them.
+UPDATE chirps
+SET user_id = sqlc.arg('ghost_id'),
+body = '',
+status = 'tombstone',
+visibility = 'public',
+entities = '{}',
+content_warning = NULL,
+sensitive = false,
+deleted_at = NULL,
+updated_at = NOW()
+WHERE chirps.user_id = sqlc.arg('user_id')
+AND EXISTS (
+    SELECT 1 FROM chirps AS replies
+    WHERE replies.reply_to_id = chirps.id
+    AND replies.user_id <> sqlc.arg('user_id')
+)
+RETURNING chirps.id;
+
+-- name: DeleteTombstoneIndexes :exec
+WITH hashtags AS (
+    DELETE FROM chirp_hashtags
+    WHERE chirp_hashtags.chirp_id = ANY(sqlc.arg('ids')::uuid[])
+), mentions AS (
+    DELETE FROM chirp_mentions
+    WHERE chirp_mentions.chirp_id = ANY(sqlc.arg('ids')::uuid[])
+), stats AS (
+    DELETE FROM chirp_daily_stats
+    WHERE chirp_daily_stats.chirp_id = ANY(sqlc.arg('ids')::uuid[])
+)
+DELETE FROM polls
+WHERE polls.chirp_id = ANY(sqlc.arg('ids')::uuid[]);
+
+-- name: SelectMediaUser :many
+SELECT * FROM media
+WHERE media.user_id = $1;
+
+-- name: DeleteUser :exec
+DELETE FROM users
+WHERE id = $1;
\ No newline at end of file
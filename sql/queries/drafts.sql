@@ -0,0 +1,35 @@
+-- name: CreateDraft :one
+INSERT INTO drafts (id, created_at, updated_at, user_id, body)
+VALUES (
+    gen_random_uuid(),
+    NOW(),
+    NOW(),
+    $1,
+    $2
+)
+RETURNING *;
+
+-- name: SelectDraftsUser :many
+SELECT * FROM drafts
+WHERE drafts.user_id = $1
+ORDER BY drafts.updated_at DESC, drafts.id DESC;
+
+-- name: SelectOneDraft :one
+SELECT * FROM drafts
+WHERE drafts.id = $1
+AND drafts.user_id = $2;
+
+-- name: UpdateDraft :one
+UPDATE drafts
+SET body = $1, updated_at = NOW()
+WHERE drafts.id = $2
+AND drafts.user_id = $3
+RETURNING *;
+
+-- name: DeleteDraft :one
+-- Returns the deleted draft, so publishing can claim it and read its body in
+-- a single statement.
+DELETE FROM drafts
+WHERE drafts.id = $1
+AND drafts.user_id = $2
+RETURNING *;
\ No newline at end of file
@@ -0,0 +1,111 @@
+-- name: CreateExport :one
+INSERT INTO data_exports (id, created_at, user_id)
+VALUES (
+    gen_random_uuid(),
+    NOW(),
+    $1
+)
+RETURNING *;
+
+-- name: SelectPendingExportUser :one
+SELECT * FROM data_exports
+WHERE data_exports.user_id = $1
+AND data_exports.status = 'pending'
+LIMIT 1;
+
+-- name: SelectExportsUser :many
+SELECT * FROM data_exports
+WHERE data_exports.user_id = $1
+ORDER BY data_exports.created_at DESC;
+
+-- name: SelectOneExport :one
+SELECT * FROM data_exports
+WHERE data_exports.id = $1;
+
+-- name: SelectNextPendingExport :one
+-- Locks the export so several instances can share the work.
+SELECT * FROM data_exports
+WHERE data_exports.status = 'pending'
+ORDER BY data_exports.created_at
+LIMIT 1
+FOR UPDATE SKIP LOCKED;
+
+-- name: CompleteExport :one
+UPDATE data_exports
+SET status = 'ready',
+blob_key = sqlc.arg('blob_key'),
+size_bytes = sqlc.arg('size_bytes'),
+ready_at = NOW(),
+expires_at = NOW() + make_interval(secs => sqlc.arg('retention_seconds')::float8)
+WHERE id = sqlc.arg('id')
+RETURNING *;
+
+-- name: FailExport :exec
+-- Failed exports expire like ready ones, so the cleanup removes them too.
+UPDATE data_exports
+SET status = 'failed',
+expires_at = NOW() + make_interval(secs => sqlc.arg('retention_seconds')::float8)
+WHERE id = sqlc.arg('id');
+
+-- name: DeleteExpiredExports :many
+DELETE FROM data_exports
+WHERE data_exports.id IN (
+    SELECT expired.id FROM data_exports AS expired
+    WHERE expired.expires_at <= NOW()
+    ORDER BY expired.expires_at
+    LIMIT $1
+    FOR UPDATE SKIP LOCKED
+)
+RETURNING data_exports.blob_key;
+
+-- name: SelectChirpsForExport :many
+SELECT * FROM chirps
+WHERE chirps.user_id = $1
+ORDER BY chirps.created_at, chirps.id;
+
+-- name: SelectFollowsForExport :many
+-- Both directions, with the handle of the other user. Their email isn't
+-- the exporting user's data.
+SELECT sqlc.embed(follows), users.handle
+FROM follows
+JOIN users ON users.id = CASE
+    WHEN follows.follower_id = $1 THEN follows.followee_id
+    ELSE follows.follower_id
+END
+WHERE follows.follower_id = $1
+OR follows.followee_id = $1
+ORDER BY follows.created_at;
+
+-- name: SelectSessionsUser :many
+SELECT * FROM refresh_tokens
+WHERE refresh_tokens.user_id = $1
+ORDER BY refresh_tokens.created_at;
+
+-- name: SelectListMembersForExport :many
+-- The members of the lists the user owns, with their handle.
+SELECT sqlc.embed(list_members), users.handle
+FROM list_members
+JOIN lists ON lists.id = list_members.list_id
+JOIN users ON users.id = list_members.user_id
+WHERE lists.owner_id = $1
+ORDER BY list_members.created_at, list_members.user_id;
+
+-- name: SelectBlocksForExport :many
+-- Only the users they blocked. Who blocked them isn't their data.
+SELECT sqlc.embed(blocks), users.handle
+FROM blocks
+JOIN users ON users.id = blocks.blocked_id
+WHERE blocks.blocker_id = $1
+ORDER BY blocks.created_at;
+
+-- name: SelectMutesForExport :many
+SELECT sqlc.embed(mutes), users.handle
+FROM mutes
+JOIN users ON users.id = mutes.muted_id
+WHERE mutes.muter_id = $1
+ORDER BY mutes.created_at;
+
+-- name: SelectMediaForExport :many
+SELECT * FROM media
+WHERE media.user_id = $1
+ORDER BY media.created_at, media.id;
\ No newline at end of file
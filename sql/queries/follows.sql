@@ -0,0 +1,81 @@
+-- name: InsertFollow :execrows
+-- Following somebody twice is a no-op.
+INSERT INTO follows (follower_id, followee_id, created_at)
+VALUES ($1, $2, NOW())
+ON CONFLICT (follower_id, followee_id) DO NOTHING;
+
+-- name: DeleteFollow :execrows
+DELETE FROM follows
+WHERE follows.follower_id = $1
+AND follows.followee_id = $2;
+
+-- name: SelectFollowersPage :many
+SELECT sqlc.embed(users), follows.created_at AS followed_at
+FROM follows
+JOIN users ON users.id = follows.follower_id
+WHERE follows.followee_id = sqlc.arg('user_id')
+AND (sqlc.narg('cursor_created_at')::timestamp IS NULL
+    OR (follows.created_at, follows.follower_id) < (sqlc.narg('cursor_created_at'), sqlc.arg('cursor_id')::uuid))
+ORDER BY follows.created_at DESC, follows.follower_id DESC
+LIMIT sqlc.arg('max_results');
+
+-- name: SelectFollowingPage :many
+SELECT sqlc.embed(users), follows.created_at AS followed_at
+FROM follows
+JOIN users ON users.id = follows.followee_id
+WHERE follows.follower_id = sqlc.arg('user_id')
+AND (sqlc.narg('cursor_created_at')::timestamp IS NULL
+    OR (follows.created_at, follows.followee_id) < (sqlc.narg('cursor_created_at'), sqlc.arg('cursor_id')::uuid))
+ORDER BY follows.created_at DESC, follows.followee_id DESC
+LIMIT sqlc.arg('max_results');
+
+-- name: CountFollows :one
+SELECT
+    (SELECT COUNT(*) FROM follows WHERE follows.followee_id = sqlc.arg('user_id')) AS followers,
+    (SELECT COUNT(*) FROM follows WHERE follows.follower_id = sqlc.arg('user_id')) AS following;
+
+-- name: IsFollowing :one
+SELECT EXISTS (
+    SELECT 1 FROM follows
+    WHERE follows.follower_id = $1
+    AND follows.followee_id = $2
+) AS following;
+
+-- name: InsertFollowRequest :execrows
+INSERT INTO follow_requests (requester_id, target_id, created_at)
+VALUES ($1, $2, NOW())
+ON CONFLICT (requester_id, target_id) DO NOTHING;
+
+-- name: DeleteFollowRequest :execrows
+DELETE FROM follow_requests
+WHERE follow_requests.requester_id = $1
+AND follow_requests.target_id = $2;
+
+-- name: DeleteFollowRequestsBetween :exec
+DELETE FROM follow_requests
+WHERE (follow_requests.requester_id = $1 AND follow_requests.target_id = $2)
+OR (follow_requests.requester_id = $2 AND follow_requests.target_id = $1);
+
+-- name: SelectFollowRequestsPage :many
+SELECT sqlc.embed(users), follow_requests.created_at AS requested_at
+FROM follow_requests
+JOIN users ON users.id = follow_requests.requester_id
+WHERE follow_requests.target_id = sqlc.arg('user_id')
+AND (sqlc.narg('cursor_created_at')::timestamp IS NULL
+    OR (follow_requests.created_at, follow_requests.requester_id) < (sqlc.narg('cursor_created_at'), sqlc.arg('cursor_id')::uuid))
+ORDER BY follow_requests.created_at DESC, follow_requests.requester_id DESC
+LIMIT sqlc.arg('max_results');
+
+-- name: ApproveAllFollowRequests :many
+-- Turns every pending request to the user into a follow, returning the new
+-- followers.
+WITH approved AS (
+    DELETE FROM follow_requests
+    WHERE follow_requests.target_id = sqlc.arg('target_id')
+    RETURNING follow_requests.requester_id
+)
+INSERT INTO follows (follower_id, followee_id, created_at)
+SELECT approved.requester_id, sqlc.arg('target_id'), NOW()
+FROM approved
+ON CONFLICT (follower_id, followee_id) DO NOTHING
+RETURNING follows.follower_id;
\ No newline at end of file
@@ -0,0 +1,38 @@
+-- name: UpsertHashtag :one
+INSERT INTO hashtags (id, created_at, tag)
+VALUES (
+    gen_random_uuid(),
+    NOW(),
+    $1
+)
+ON CONFLICT (tag) DO UPDATE SET tag = EXCLUDED.tag
+RETURNING *;
+
+-- name: InsertChirpHashtag :exec
+INSERT INTO chirp_hashtags (chirp_id, hashtag_id)
+VALUES ($1, $2)
+ON CONFLICT DO NOTHING;
+
+-- name: DeleteChirpHashtags :exec
+DELETE FROM chirp_hashtags
+WHERE chirp_id = $1;
+
+-- name: SelectTrendingHashtags :many
+SELECT hashtags.tag,
+    COUNT(*) AS uses,
+    SUM(POWER(0.5, EXTRACT(EPOCH FROM (NOW() - chirps.created_at)) / sqlc.arg('half_life_seconds')::float8))::float8 AS score
+FROM chirp_hashtags
+JOIN hashtags ON hashtags.id = chirp_hashtags.hashtag_id
+JOIN chirps ON chirps.id = chirp_hashtags.chirp_id
+WHERE chirps.created_at > NOW() - make_interval(secs => sqlc.arg('window_seconds')::float8)
+AND chirps.status = 'published'
+AND chirps.deleted_at IS NULL
+AND chirps.visibility = 'public'
+AND NOT EXISTS (
+    SELECT 1 FROM users
+    WHERE users.id = chirps.user_id
+    AND users.is_protected
+)
+GROUP BY hashtags.tag
+ORDER BY score DESC, uses DESC, hashtags.tag
+LIMIT sqlc.arg('max_tags');
\ No newline at end of file
@@ -0,0 +1,27 @@
+-- name: CreateImportedChirp :one
+-- Imported chirps keep their original time and aren't fanned out, home
+-- timelines pick them up at read time like other old chirps.
+INSERT INTO chirps (id, created_at, updated_at, body, user_id, reply_to_id, status, visibility, fanout)
+VALUES (
+    gen_random_uuid(),
+    sqlc.arg('created_at'),
+    NOW(),
+    sqlc.arg('body'),
+    sqlc.arg('user_id'),
+    sqlc.narg('reply_to_id'),
+    'published',
+    sqlc.arg('visibility'),
+    'read'
+)
+RETURNING *;
+
+-- name: InsertChirpImport :execrows
+INSERT INTO chirp_imports (user_id, source, source_id, chirp_id, imported_at)
+VALUES ($1, $2, $3, $4, NOW())
+ON CONFLICT DO NOTHING;
+
+-- name: SelectChirpImports :many
+SELECT * FROM chirp_imports
+WHERE chirp_imports.user_id = sqlc.arg('user_id')
+AND chirp_imports.source = sqlc.arg('source')
+AND chirp_imports.source_id = ANY(sqlc.arg('source_ids')::text[]);
\ No newline at end of file
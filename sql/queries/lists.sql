@@ -0,0 +1,91 @@
+-- name: CreateList :one
+INSERT INTO lists (id, created_at, updated_at, owner_id, name, is_private)
+VALUES (
+    gen_random_uuid(),
+    NOW(),
+    NOW(),
+    $1,
+    $2,
+    $3
+)
+RETURNING *;
+
+-- name: SelectOneList :one
+SELECT * FROM lists
+WHERE lists.id = $1;
+
+-- name: SelectListsUser :many
+-- The user's own lists, then the public lists they subscribed to.
+SELECT * FROM lists
+WHERE lists.owner_id = $1
+OR (NOT lists.is_private AND EXISTS (
+    SELECT 1 FROM list_subscriptions
+    WHERE list_subscriptions.list_id = lists.id
+    AND list_subscriptions.user_id = $1
+))
+ORDER BY lists.owner_id = $1 DESC, lists.created_at, lists.id;
+
+-- name: DeleteList :execrows
+DELETE FROM lists
+WHERE lists.id = $1
+AND lists.owner_id = $2;
+
+-- name: LockList :exec
+-- Holds the list row until the transaction ends, so adding members can
+-- count them before inserting.
+SELECT lists.id FROM lists
+WHERE lists.id = $1
+FOR UPDATE;
+
+-- name: AddListMember :execrows
+-- Adds nothing once the list already has max_members members. Callers lock
+-- the list first, so two adds at once can't both pass the count.
+INSERT INTO list_members (list_id, user_id, created_at)
+SELECT sqlc.arg('list_id'), sqlc.arg('user_id'), NOW()
+WHERE (SELECT COUNT(*) FROM list_members WHERE list_members.list_id = sqlc.arg('list_id')) < sqlc.arg('max_members')::bigint
+ON CONFLICT (list_id, user_id) DO NOTHING;
+
+-- name: RemoveListMember :execrows
+DELETE FROM list_members
+WHERE list_members.list_id = $1
+AND list_members.user_id = $2;
+
+-- name: IsListMember :one
+SELECT EXISTS (
+    SELECT 1 FROM list_members
+    WHERE list_members.list_id = $1
+    AND list_members.user_id = $2
+) AS member;
+
+-- name: SelectListMembers :many
+SELECT sqlc.embed(users), list_members.created_at AS added_at
+FROM list_members
+JOIN users ON users.id = list_members.user_id
+WHERE list_members.list_id = $1
+ORDER BY list_members.created_at, list_members.user_id;
+
+-- name: SubscribeList :execrows
+INSERT INTO list_subscriptions (list_id, user_id, created_at)
+VALUES ($1, $2, NOW())
+ON CONFLICT (list_id, user_id) DO NOTHING;
+
+-- name: UnsubscribeList :execrows
+DELETE FROM list_subscriptions
+WHERE list_subscriptions.list_id = $1
+AND list_subscriptions.user_id = $2;
+
+-- name: SelectListTimelinePage :many
+SELECT * FROM chirps
+WHERE chirps.user_id IN (
+    SELECT list_members.user_id FROM list_members
+    WHERE list_members.list_id = sqlc.arg('list_id')
+)
+AND chirps.status = 'published'
+AND chirps.deleted_at IS NULL
+AND chirp_is_visible(chirps.visibility, chirps.id, chirps.user_id, sqlc.arg('viewer_id'))
+AND (NOT sqlc.arg('hide_sensitive')::bool OR chirps.user_id = sqlc.arg('viewer_id') OR (NOT chirps.sensitive AND chirps.content_warning IS NULL))
+AND NOT chirp_is_muted(chirps.user_id, sqlc.arg('viewer_id'))
+AND (sqlc.narg('cursor_created_at')::timestamp IS NULL
+    OR (chirps.created_at, chirps.id) < (sqlc.narg('cursor_created_at'), sqlc.arg('cursor_id')::uuid))
+ORDER BY chirps.created_at DESC, chirps.id DESC
+LIMIT sqlc.arg('max_results');
\ No newline at end of file
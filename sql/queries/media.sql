@@ -0,0 +1,81 @@
+-- name: CreateMedia :one
+INSERT INTO media (id, created_at, user_id, content_type, size_bytes, width, height, blob_key, thumbnail_content_type, thumbnail_key)
+VALUES (
+    $1,
+    NOW(),
+    $2,
+    $3,
+    $4,
+    $5,
+    $6,
+    $7,
+    $8,
+    $9
+)
+RETURNING *;
+
+-- name: SelectOneMedia :one
+SELECT * FROM media
+WHERE media.id = $1;
+
+-- name: SelectVisibleMedia :one
+-- Media can be seen by its owner, by whoever can see a live chirp it's
+-- attached to, and by everyone when it's an avatar. Anything else, like an
+-- upload that's only in a draft, stays with its owner. public says anonymous
+-- viewers can see it too.
+SELECT sqlc.embed(media), (
+    EXISTS (
+        SELECT 1 FROM users
+        WHERE users.avatar_media_id = media.id
+    ) OR EXISTS (
+        SELECT 1 FROM chirp_media
+        JOIN chirps ON chirps.id = chirp_media.chirp_id
+        WHERE chirp_media.media_id = media.id
+        AND chirps.status = 'published'
+        AND chirps.deleted_at IS NULL
+        AND chirps.visibility = 'public'
+        AND NOT EXISTS (
+            SELECT 1 FROM users
+            WHERE users.id = chirps.user_id
+            AND users.is_protected
+        )
+    )
+)::bool AS public
+FROM media
+WHERE media.id = sqlc.arg('id')
+AND (media.user_id = sqlc.arg('viewer_id')
+    OR EXISTS (
+        SELECT 1 FROM users
+        WHERE users.avatar_media_id = media.id
+    ) OR EXISTS (
+        SELECT 1 FROM chirp_media
+        JOIN chirps ON chirps.id = chirp_media.chirp_id
+        WHERE chirp_media.media_id = media.id
+        AND chirps.status = 'published'
+        AND chirps.deleted_at IS NULL
+        AND chirp_is_visible(chirps.visibility, chirps.id, chirps.user_id, sqlc.arg('viewer_id'))
+    ));
+
+-- name: SelectAttachableMedia :many
+SELECT * FROM media
+WHERE media.id = ANY(sqlc.arg('ids')::uuid[])
+AND media.user_id = sqlc.arg('user_id')
+AND NOT EXISTS (
+    SELECT 1 FROM chirp_media
+    WHERE chirp_media.media_id = media.id
+);
+
+-- name: AttachChirpMedia :exec
+INSERT INTO chirp_media (chirp_id, media_id, position)
+VALUES ($1, $2, $3);
+
+-- name: SelectMediaForChirps :many
+SELECT chirp_media.chirp_id, sqlc.embed(media)
+FROM chirp_media
+JOIN media ON media.id = chirp_media.media_id
+WHERE chirp_media.chirp_id = ANY(sqlc.arg('chirp_ids')::uuid[])
+ORDER BY chirp_media.chirp_id, chirp_media.position;
+
+-- name: DeleteMediaByIds :exec
+DELETE FROM media
+WHERE media.id = ANY(sqlc.arg('ids')::uuid[]);
\ No newline at end of file
@@ -0,0 +1,12 @@
+-- name: InsertChirpMention :exec
+INSERT INTO chirp_mentions (chirp_id, user_id, handle, start_offset, end_offset)
+VALUES ($1, $2, $3, $4, $5);
+
+-- name: DeleteChirpMentions :exec
+DELETE FROM chirp_mentions
+WHERE chirp_id = $1;
+
+-- name: SelectMentionsForChirps :many
+SELECT * FROM chirp_mentions
+WHERE chirp_id = ANY(sqlc.arg('chirp_ids')::uuid[])
+ORDER BY chirp_id, start_offset;
\ No newline at end of file
@@ -0,0 +1,28 @@
+-- name: InsertNotification :one
+INSERT INTO notifications (id, created_at, user_id, kind, actor_id, chirp_id, export_id)
+VALUES (
+    gen_random_uuid(),
+    NOW(),
+    $1,
+    $2,
+    $3,
+    $4,
+    $5
+)
+RETURNING *;
+
+-- name: SelectNotificationsUser :many
+-- Leaves out notifications from users the user muted, or who are on either
+-- side of a block with them.
+SELECT * FROM notifications
+WHERE user_id = $1
+AND (notifications.actor_id IS NULL OR NOT (
+    chirp_is_muted(notifications.actor_id, $1)
+    OR EXISTS (
+        SELECT 1 FROM blocks
+        WHERE (blocks.blocker_id = $1 AND blocks.blocked_id = notifications.actor_id)
+        OR (blocks.blocker_id = notifications.actor_id AND blocks.blocked_id = $1)
+    )
+))
+ORDER BY created_at DESC
+LIMIT $2;
\ No newline at end of file
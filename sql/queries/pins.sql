@@ -0,0 +1,62 @@
+-- name: PinChirp :execrows
+-- Pins only the user's own live chirps, and nothing once they already have
+-- max_pins of them. Callers lock the user row first, so two pins at once
+-- can't both pass the count. A new pin goes above the others.
+INSERT INTO pins (user_id, chirp_id, created_at, position)
+SELECT chirps.user_id, chirps.id, NOW(), (
+    SELECT COALESCE(MIN(pins.position) - 1, 0) FROM pins
+    WHERE pins.user_id = sqlc.arg('user_id')
+)
+FROM chirps
+WHERE chirps.id = sqlc.arg('chirp_id')
+AND chirps.user_id = sqlc.arg('user_id')
+AND chirps.status = 'published'
+AND chirps.deleted_at IS NULL
+AND (SELECT COUNT(*) FROM pins WHERE pins.user_id = sqlc.arg('user_id')) < sqlc.arg('max_pins')::bigint
+ON CONFLICT (user_id, chirp_id) DO NOTHING;
+
+-- name: UnpinChirp :execrows
+DELETE FROM pins
+WHERE pins.user_id = $1
+AND pins.chirp_id = $2;
+
+-- name: SelectPinIdsUser :many
+SELECT pins.chirp_id FROM pins
+WHERE pins.user_id = $1
+ORDER BY pins.position, pins.created_at DESC, pins.chirp_id DESC;
+
+-- name: SetPinPosition :exec
+UPDATE pins
+SET position = $1
+WHERE pins.user_id = $2
+AND pins.chirp_id = $3;
+
+-- name: DeleteChirpPins :exec
+DELETE FROM pins
+WHERE pins.chirp_id = $1;
+
+-- name: SelectPinnedChirpsUser :many
+-- Takes the same filters as the chirp list, so pins shown above it match
+-- them too. Filters left empty don't apply.
+SELECT sqlc.embed(chirps)
+FROM pins
+JOIN chirps ON chirps.id = pins.chirp_id
+WHERE pins.user_id = sqlc.arg('user_id')
+AND chirps.deleted_at IS NULL
+AND chirp_is_visible(chirps.visibility, chirps.id, chirps.user_id, sqlc.arg('viewer_id'))
+AND (NOT sqlc.arg('hide_sensitive')::bool OR chirps.user_id = sqlc.arg('viewer_id') OR (NOT chirps.sensitive AND chirps.content_warning IS NULL))
+AND (sqlc.narg('tag')::text IS NULL OR EXISTS (
+    SELECT 1 FROM chirp_hashtags
+    JOIN hashtags ON hashtags.id = chirp_hashtags.hashtag_id
+    WHERE chirp_hashtags.chirp_id = chirps.id
+    AND hashtags.tag = sqlc.narg('tag')
+))
+AND (sqlc.narg('since')::timestamp IS NULL OR chirps.created_at >= sqlc.narg('since'))
+AND (sqlc.narg('until')::timestamp IS NULL OR chirps.created_at < sqlc.narg('until'))
+AND (sqlc.narg('contains')::text IS NULL OR chirps.body ILIKE '%' || sqlc.narg('contains') || '%' ESCAPE '\')
+AND (NOT sqlc.arg('exclude_replies')::boolean OR chirps.reply_to_id IS NULL)
+AND (sqlc.narg('has_media')::boolean IS NULL OR sqlc.narg('has_media') = EXISTS (
+    SELECT 1 FROM chirp_media
+    WHERE chirp_media.chirp_id = chirps.id
+))
+ORDER BY pins.position, pins.created_at DESC, pins.chirp_id DESC;
\ No newline at end of file
@@ -0,0 +1,38 @@
+-- name: CreatePoll :one
+INSERT INTO polls (chirp_id, created_at, closes_at)
+VALUES ($1, NOW(), $2)
+RETURNING *;
+
+-- name: InsertPollOption :one
+INSERT INTO poll_options (id, chirp_id, position, text)
+VALUES (gen_random_uuid(), $1, $2, $3)
+RETURNING *;
+
+-- name: SelectPollsForChirps :many
+SELECT * FROM polls
+WHERE polls.chirp_id = ANY(sqlc.arg('chirp_ids')::uuid[]);
+
+-- name: SelectPollOptionsForChirps :many
+SELECT sqlc.embed(poll_options), COUNT(poll_votes.user_id) AS votes
+FROM poll_options
+LEFT JOIN poll_votes ON poll_votes.option_id = poll_options.id
+WHERE poll_options.chirp_id = ANY(sqlc.arg('chirp_ids')::uuid[])
+GROUP BY poll_options.id
+ORDER BY poll_options.chirp_id, poll_options.position;
+
+-- name: SelectPollVotesUser :many
+SELECT * FROM poll_votes
+WHERE poll_votes.user_id = sqlc.arg('user_id')
+AND poll_votes.chirp_id = ANY(sqlc.arg('chirp_ids')::uuid[]);
+
+-- name: InsertPollVote :execrows
+-- Inserts nothing when the option isn't part of the chirp's poll, the poll
+-- has closed, or the user already voted on it.
+INSERT INTO poll_votes (chirp_id, user_id, option_id, created_at)
+SELECT poll_options.chirp_id, sqlc.arg('user_id')::uuid, poll_options.id, NOW()
+FROM poll_options
+JOIN polls ON polls.chirp_id = poll_options.chirp_id
+WHERE poll_options.id = sqlc.arg('option_id')
+AND poll_options.chirp_id = sqlc.arg('chirp_id')
+AND polls.closes_at > NOW()
+ON CONFLICT (chirp_id, user_id) DO NOTHING;
\ No newline at end of file
@@ -17,4 +17,16 @@ WHERE token = $1;
 
 UPDATE refresh_tokens
 SET revoked_at = NOW(), updated_at = NOW()
-WHERE token = $1;
\ No newline at end of file
+WHERE token = $1;
+
+-- name: SelectSession :one
+SELECT * FROM refresh_tokens
+WHERE session_id = $1
+AND user_id = $2;
+
+-- name: RevokeOtherRefreshTokens :exec
+UPDATE refresh_tokens
+SET revoked_at = NOW(), updated_at = NOW()
+WHERE user_id = $1
+AND session_id <> $2
+AND revoked_at IS NULL;
\ No newline at end of file
@@ -0,0 +1,32 @@
+-- name: SearchChirps :many
+WITH matches AS (
+    SELECT chirps.id,
+        ts_rank(chirps.search_vector, to_tsquery('english', sqlc.arg('query')))::float8 AS rank
+    FROM chirps
+    WHERE chirps.search_vector @@ to_tsquery('english', sqlc.arg('query'))
+    AND chirps.status = 'published'
+    AND chirps.deleted_at IS NULL
+    AND chirp_is_visible(chirps.visibility, chirps.id, chirps.user_id, sqlc.arg('viewer_id'))
+    AND (NOT sqlc.arg('hide_sensitive')::bool OR chirps.user_id = sqlc.arg('viewer_id') OR (NOT chirps.sensitive AND chirps.content_warning IS NULL))
+    AND (sqlc.narg('user_id')::uuid IS NOT NULL OR NOT chirp_is_muted(chirps.user_id, sqlc.arg('viewer_id')))
+    AND (sqlc.narg('user_id')::uuid IS NULL OR chirps.user_id = sqlc.narg('user_id'))
+    AND (sqlc.narg('since')::timestamp IS NULL OR chirps.created_at >= sqlc.narg('since'))
+    AND (sqlc.narg('until')::timestamp IS NULL OR chirps.created_at < sqlc.narg('until'))
+), page AS (
+    SELECT * FROM matches
+    WHERE sqlc.narg('cursor_rank')::float8 IS NULL
+    OR matches.rank < sqlc.narg('cursor_rank')
+    OR (matches.rank = sqlc.narg('cursor_rank') AND matches.id < sqlc.arg('cursor_id')::uuid)
+    ORDER BY matches.rank DESC, matches.id DESC
+    LIMIT sqlc.arg('max_results')
+)
+SELECT sqlc.embed(chirps), page.rank,
+    ts_headline(
+        'english',
+        REPLACE(REPLACE(REPLACE(chirps.body, '&', '&amp;'), '<', '&lt;'), '>', '&gt;'),
+        to_tsquery('english', sqlc.arg('query')),
+        'StartSel=<mark>, StopSel=</mark>, MaxFragments=2, MaxWords=20, MinWords=8'
+    )::text AS snippet
+FROM page
+JOIN chirps ON chirps.id = page.id
+ORDER BY page.rank DESC, page.id DESC;
\ No newline at end of file
@@ -0,0 +1,39 @@
+-- name: AddChirpDailyStats :exec
+-- Chirps purged since they were seen are skipped.
+INSERT INTO chirp_daily_stats (chirp_id, day, views, impressions)
+SELECT chirps.id, sqlc.arg('day')::date, sqlc.arg('views')::bigint, sqlc.arg('impressions')::bigint
+FROM chirps
+WHERE chirps.id = sqlc.arg('chirp_id')
+ON CONFLICT (chirp_id, day) DO UPDATE
+SET views = chirp_daily_stats.views + EXCLUDED.views,
+    impressions = chirp_daily_stats.impressions + EXCLUDED.impressions;
+
+-- name: SelectDailyChirpStatsUser :many
+-- Views, impressions and replies per day for each of a user's chirps that
+-- had any in the range. Both sides count in UTC days: views are bucketed by
+-- today() in Go, and reply times are UTC wall-clock times because every
+-- session runs in UTC (see openDB), so ::date gives the UTC day too.
+WITH daily AS (
+    SELECT chirp_daily_stats.chirp_id, chirp_daily_stats.day, chirp_daily_stats.views, chirp_daily_stats.impressions, 0 AS replies
+    FROM chirp_daily_stats
+    JOIN chirps ON chirps.id = chirp_daily_stats.chirp_id
+    WHERE chirps.user_id = sqlc.arg('user_id')
+    AND chirps.deleted_at IS NULL
+    AND chirp_daily_stats.day BETWEEN sqlc.arg('since')::date AND sqlc.arg('until')::date
+    UNION ALL
+    SELECT replies.reply_to_id, COALESCE(replies.publish_at, replies.created_at)::date, 0, 0, 1
+    FROM chirps AS replies
+    JOIN chirps ON chirps.id = replies.reply_to_id
+    WHERE chirps.user_id = sqlc.arg('user_id')
+    AND chirps.deleted_at IS NULL
+    AND replies.status = 'published'
+    AND replies.deleted_at IS NULL
+    AND COALESCE(replies.publish_at, replies.created_at)::date BETWEEN sqlc.arg('since')::date AND sqlc.arg('until')::date
+)
+SELECT daily.chirp_id, daily.day,
+    SUM(daily.views)::bigint AS views,
+    SUM(daily.impressions)::bigint AS impressions,
+    SUM(daily.replies)::bigint AS replies
+FROM daily
+GROUP BY daily.chirp_id, daily.day
+ORDER BY daily.chirp_id, daily.day;
\ No newline at end of file
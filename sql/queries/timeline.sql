@@ -0,0 +1,80 @@
+-- name: SelectChirpsToFanOut :many
+-- Locks the batch so several instances can share the fan-out work.
+SELECT * FROM chirps
+WHERE chirps.status = 'published'
+AND chirps.fanout = 'pending'
+ORDER BY chirps.created_at
+LIMIT $1
+FOR UPDATE SKIP LOCKED;
+
+-- name: FanOutChirp :exec
+INSERT INTO timeline_entries (user_id, chirp_id, author_id, created_at)
+SELECT follows.follower_id, chirps.id, chirps.user_id, chirps.created_at
+FROM chirps
+JOIN follows ON follows.followee_id = chirps.user_id
+WHERE chirps.id = $1
+ON CONFLICT (user_id, chirp_id) DO NOTHING;
+
+-- name: SetChirpFanout :exec
+UPDATE chirps
+SET fanout = $1
+WHERE chirps.id = $2;
+
+-- name: BackfillTimeline :exec
+-- Copies the latest fanned out chirps of a newly followed account.
+INSERT INTO timeline_entries (user_id, chirp_id, author_id, created_at)
+SELECT sqlc.arg('user_id'), chirps.id, chirps.user_id, chirps.created_at
+FROM chirps
+WHERE chirps.user_id = sqlc.arg('author_id')
+AND chirps.fanout = 'done'
+AND chirps.deleted_at IS NULL
+ORDER BY chirps.created_at DESC
+LIMIT sqlc.arg('max_entries')
+ON CONFLICT (user_id, chirp_id) DO NOTHING;
+
+-- name: DeleteTimelineEntriesAuthor :exec
+DELETE FROM timeline_entries
+WHERE timeline_entries.user_id = $1
+AND timeline_entries.author_id = $2;
+
+-- name: SelectHomeTimelinePage :many
+-- The viewer's own chirps, the fanned out chirps in their timeline_entries,
+-- and chirps of followed accounts that weren't fanned out. Each branch is
+-- filtered and limited on its own so the union stays small. Entries are only
+-- trusted while the follow still exists, which covers an unfollow racing the
+-- fan-out worker.
+SELECT * FROM chirps
+WHERE chirps.id IN (
+    (SELECT chirps.id
+    FROM timeline_entries
+    JOIN chirps ON chirps.id = timeline_entries.chirp_id
+    JOIN follows ON follows.follower_id = timeline_entries.user_id AND follows.followee_id = timeline_entries.author_id
+    WHERE timeline_entries.user_id = sqlc.arg('viewer_id')
+    AND chirps.status = 'published'
+    AND chirps.deleted_at IS NULL
+    AND chirp_is_visible(chirps.visibility, chirps.id, chirps.user_id, sqlc.arg('viewer_id'))
+    AND (NOT sqlc.arg('hide_sensitive')::bool OR (NOT chirps.sensitive AND chirps.content_warning IS NULL))
+    AND NOT chirp_is_muted(chirps.user_id, sqlc.arg('viewer_id'))
+    AND (sqlc.narg('cursor_created_at')::timestamp IS NULL
+        OR (timeline_entries.created_at, timeline_entries.chirp_id) < (sqlc.narg('cursor_created_at'), sqlc.arg('cursor_id')::uuid))
+    ORDER BY timeline_entries.created_at DESC, timeline_entries.chirp_id DESC
+    LIMIT sqlc.arg('max_results'))
+    UNION ALL
+    (SELECT chirps.id
+    FROM chirps
+    WHERE (chirps.user_id = sqlc.arg('viewer_id') OR (chirps.fanout <> 'done' AND chirps.user_id IN (
+        SELECT follows.followee_id FROM follows
+        WHERE follows.follower_id = sqlc.arg('viewer_id')
+    )))
+    AND chirps.status = 'published'
+    AND chirps.deleted_at IS NULL
+    AND chirp_is_visible(chirps.visibility, chirps.id, chirps.user_id, sqlc.arg('viewer_id'))
+    AND (NOT sqlc.arg('hide_sensitive')::bool OR chirps.user_id = sqlc.arg('viewer_id') OR (NOT chirps.sensitive AND chirps.content_warning IS NULL))
+    AND NOT chirp_is_muted(chirps.user_id, sqlc.arg('viewer_id'))
+    AND (sqlc.narg('cursor_created_at')::timestamp IS NULL
+        OR (chirps.created_at, chirps.id) < (sqlc.narg('cursor_created_at'), sqlc.arg('cursor_id')::uuid))
+    ORDER BY chirps.created_at DESC, chirps.id DESC
+    LIMIT sqlc.arg('max_results'))
+)
+ORDER BY chirps.created_at DESC, chirps.id DESC
+LIMIT sqlc.arg('max_results');
\ No newline at end of file
@@ -1,11 +1,12 @@
 -- name: CreateUser :one
-INSERT INTO users (id, created_at, updated_at, email, hashed_password)
+INSERT INTO users (id, created_at, updated_at, email, hashed_password, handle)
 VALUES (
     gen_random_uuid(),
     NOW(),
     NOW(),
     $1,
-    $2
+    $2,
+    $3
 )
 RETURNING *;
 
@@ -14,6 +15,21 @@ SELECT *
 FROM users
 WHERE users.email = $1;
 
+-- name: SelectUserById :one
+SELECT *
+FROM users
+WHERE users.id = $1;
+
+-- name: SelectUserByHandle :one
+SELECT *
+FROM users
+WHERE LOWER(users.handle) = LOWER(sqlc.arg('handle'));
+
+-- name: SelectUsersByHandles :many
+SELECT *
+FROM users
+WHERE LOWER(users.handle) = ANY(sqlc.arg('handles')::text[]);
+
 -- name: UpdateUserMailPassByUUID :one
 
 UPDATE users
@@ -24,6 +40,28 @@ updated_at = NOW()
 WHERE id = $3
 RETURNING *;
 
+-- name: UpdateUserEmail :one
+UPDATE users
+SET email = $1, updated_at = NOW()
+WHERE id = $2
+RETURNING *;
+
+-- name: UpdateUserPassword :one
+UPDATE users
+SET hashed_password = $1, updated_at = NOW()
+WHERE id = $2
+RETURNING *;
+
+-- name: UpdateUserProfile :one
+UPDATE users
+SET handle = $1,
+display_name = $2,
+bio = $3,
+avatar_media_id = $4,
+updated_at = NOW()
+WHERE id = $5
+RETURNING *;
+
 -- name: UpdateToRedUserByUUID :one
 
 UPDATE users
@@ -32,6 +70,32 @@ WHERE id = $1
 RETURNING *;
 
 
+-- name: UpdateSensitiveContentPreference :one
+UPDATE users
+SET sensitive_content = $1, updated_at = NOW()
+WHERE id = $2
+RETURNING *;
+
+-- name: UpdateProtected :one
+UPDATE users
+SET is_protected = $1, updated_at = NOW()
+WHERE id = $2
+RETURNING *;
+
+-- name: LockUser :exec
+-- Holds the user row until the transaction ends, for checks like a cap on
+-- pins that count rows before inserting.
+SELECT users.id FROM users
+WHERE users.id = $1
+FOR UPDATE;
+
+-- name: LockUserProtected :one
+-- Like LockUser, returning whether the account is protected as of the lock,
+-- so a follow can't race a change of the setting.
+SELECT users.is_protected FROM users
+WHERE users.id = $1
+FOR UPDATE;
+
 -- name: DeleteAllUsers :exec
 
 DELETE FROM users;
\ No newline at end of file
@@ -0,0 +1,18 @@
+-- +goose Up
+CREATE TABLE hashtags(
+    id UUID PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    tag TEXT UNIQUE NOT NULL
+);
+
+CREATE TABLE chirp_hashtags(
+    chirp_id UUID NOT NULL REFERENCES chirps(id) ON DELETE CASCADE,
+    hashtag_id UUID NOT NULL REFERENCES hashtags(id) ON DELETE CASCADE,
+    PRIMARY KEY (chirp_id, hashtag_id)
+);
+
+CREATE INDEX chirp_hashtags_hashtag_id_idx ON chirp_hashtags(hashtag_id);
+
+-- +goose Down
+DROP TABLE chirp_hashtags;
+DROP TABLE hashtags;
\ No newline at end of file
@@ -0,0 +1,35 @@
+-- +goose Up
+ALTER TABLE users
+    ADD COLUMN "handle" TEXT;
+
+CREATE UNIQUE INDEX users_handle_lower_idx ON users (LOWER(handle));
+
+CREATE TABLE chirp_mentions(
+    chirp_id UUID NOT NULL REFERENCES chirps(id) ON DELETE CASCADE,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    handle TEXT NOT NULL,
+    start_offset INTEGER NOT NULL,
+    end_offset INTEGER NOT NULL,
+    PRIMARY KEY (chirp_id, start_offset)
+);
+
+CREATE INDEX chirp_mentions_user_id_idx ON chirp_mentions(user_id);
+
+CREATE TABLE notifications(
+    id UUID PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    kind TEXT NOT NULL,
+    actor_id UUID REFERENCES users(id) ON DELETE CASCADE,
+    chirp_id UUID REFERENCES chirps(id) ON DELETE CASCADE,
+    read_at TIMESTAMP
+);
+
+CREATE INDEX notifications_user_id_idx ON notifications(user_id, created_at);
+
+-- +goose Down
+DROP TABLE notifications;
+DROP TABLE chirp_mentions;
+DROP INDEX users_handle_lower_idx;
+ALTER TABLE users
+    DROP COLUMN "handle";
\ No newline at end of file
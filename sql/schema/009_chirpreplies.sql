@@ -0,0 +1,11 @@
+-- +goose Up
+ALTER TABLE chirps
+    ADD COLUMN "reply_to_id" UUID
+    REFERENCES chirps(id) ON DELETE SET NULL;
+
+CREATE INDEX chirps_reply_to_id_idx ON chirps(reply_to_id);
+
+-- +goose Down
+DROP INDEX chirps_reply_to_id_idx;
+ALTER TABLE chirps
+    DROP COLUMN "reply_to_id";
\ No newline at end of file
@@ -0,0 +1,24 @@
+-- +goose Up
+CREATE TABLE media(
+    id UUID PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    content_type TEXT NOT NULL,
+    size_bytes BIGINT NOT NULL,
+    width INTEGER NOT NULL,
+    height INTEGER NOT NULL,
+    blob_key TEXT NOT NULL,
+    thumbnail_content_type TEXT NOT NULL,
+    thumbnail_key TEXT NOT NULL
+);
+
+CREATE TABLE chirp_media(
+    chirp_id UUID NOT NULL REFERENCES chirps(id) ON DELETE CASCADE,
+    media_id UUID UNIQUE NOT NULL REFERENCES media(id) ON DELETE CASCADE,
+    position INTEGER NOT NULL,
+    PRIMARY KEY (chirp_id, position)
+);
+
+-- +goose Down
+DROP TABLE chirp_media;
+DROP TABLE media;
\ No newline at end of file
@@ -0,0 +1,17 @@
+-- +goose Up
+ALTER TABLE chirps
+    ADD COLUMN "status" TEXT NOT NULL
+    DEFAULT 'published';
+
+ALTER TABLE chirps
+    ADD COLUMN "publish_at" TIMESTAMP;
+
+CREATE INDEX chirps_scheduled_publish_at_idx ON chirps(publish_at)
+    WHERE status = 'scheduled';
+
+-- +goose Down
+DROP INDEX chirps_scheduled_publish_at_idx;
+ALTER TABLE chirps
+    DROP COLUMN "publish_at";
+ALTER TABLE chirps
+    DROP COLUMN "status";
\ No newline at end of file
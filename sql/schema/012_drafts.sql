@@ -0,0 +1,13 @@
+-- +goose Up
+CREATE TABLE drafts(
+    id UUID PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    body TEXT NOT NULL
+);
+
+CREATE INDEX drafts_user_id_idx ON drafts(user_id, updated_at);
+
+-- +goose Down
+DROP TABLE drafts;
\ No newline at end of file
@@ -0,0 +1,43 @@
+-- +goose Up
+ALTER TABLE chirps
+    ADD COLUMN "visibility" TEXT NOT NULL
+    DEFAULT 'public'
+    CHECK (visibility IN ('public', 'followers', 'mentioned'));
+
+CREATE TABLE follows(
+    follower_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    followee_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    created_at TIMESTAMP NOT NULL,
+    PRIMARY KEY (follower_id, followee_id),
+    CHECK (follower_id <> followee_id)
+);
+
+CREATE INDEX follows_followee_id_idx ON follows(followee_id);
+
+-- Every query that returns chirps to a user filters with this, so the rules
+-- live in one place. viewer is the nil uuid for anonymous readers.
+-- +goose StatementBegin
+CREATE FUNCTION chirp_is_visible(visibility TEXT, chirp UUID, author UUID, viewer UUID)
+RETURNS BOOLEAN
+LANGUAGE sql STABLE
+AS $$
+    SELECT visibility = 'public'
+    OR author = viewer
+    OR EXISTS (
+        SELECT 1 FROM chirp_mentions
+        WHERE chirp_mentions.chirp_id = chirp
+        AND chirp_mentions.user_id = viewer
+    )
+    OR (visibility = 'followers' AND EXISTS (
+        SELECT 1 FROM follows
+        WHERE follows.follower_id = viewer
+        AND follows.followee_id = author
+    ));
+$$;
+-- +goose StatementEnd
+
+-- +goose Down
+DROP FUNCTION chirp_is_visible;
+DROP TABLE follows;
+ALTER TABLE chirps
+    DROP COLUMN "visibility";
\ No newline at end of file
@@ -0,0 +1,34 @@
+-- +goose Up
+ALTER TABLE chirps
+    ADD COLUMN "content_warning" TEXT;
+
+ALTER TABLE chirps
+    ADD COLUMN "sensitive" BOOLEAN NOT NULL
+    DEFAULT FALSE;
+
+-- Set when a moderator labels the chirp, the author can't change the labels
+-- after that.
+ALTER TABLE chirps
+    ADD COLUMN "labels_forced" BOOLEAN NOT NULL
+    DEFAULT FALSE;
+
+ALTER TABLE users
+    ADD COLUMN "is_moderator" BOOLEAN NOT NULL
+    DEFAULT FALSE;
+
+ALTER TABLE users
+    ADD COLUMN "sensitive_content" TEXT NOT NULL
+    DEFAULT 'collapse'
+    CHECK (sensitive_content IN ('show', 'collapse', 'hide'));
+
+-- +goose Down
+ALTER TABLE users
+    DROP COLUMN "sensitive_content";
+ALTER TABLE users
+    DROP COLUMN "is_moderator";
+ALTER TABLE chirps
+    DROP COLUMN "labels_forced";
+ALTER TABLE chirps
+    DROP COLUMN "sensitive";
+ALTER TABLE chirps
+    DROP COLUMN "content_warning";
\ No newline at end of file
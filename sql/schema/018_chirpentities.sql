@@ -0,0 +1,10 @@
+-- +goose Up
+-- The URLs, mentions and hashtags found in the body when the chirp was
+-- written, as served in the chirp's "entities".
+ALTER TABLE chirps
+    ADD COLUMN "entities" JSONB NOT NULL
+    DEFAULT '{}';
+
+-- +goose Down
+ALTER TABLE chirps
+    DROP COLUMN "entities";
\ No newline at end of file
@@ -0,0 +1,13 @@
+-- +goose Up
+-- Daily view and impression counts per chirp, flushed in batches from the
+-- counters kept in memory by each instance.
+CREATE TABLE chirp_daily_stats(
+    chirp_id UUID NOT NULL REFERENCES chirps(id) ON DELETE CASCADE,
+    day DATE NOT NULL,
+    views BIGINT NOT NULL DEFAULT 0,
+    impressions BIGINT NOT NULL DEFAULT 0,
+    PRIMARY KEY (chirp_id, day)
+);
+
+-- +goose Down
+DROP TABLE chirp_daily_stats;
\ No newline at end of file
@@ -0,0 +1,10 @@
+-- +goose Up
+-- Follower and following lists are paged newest first.
+DROP INDEX follows_followee_id_idx;
+CREATE INDEX follows_followee_id_created_at_idx ON follows(followee_id, created_at DESC, follower_id DESC);
+CREATE INDEX follows_follower_id_created_at_idx ON follows(follower_id, created_at DESC, followee_id DESC);
+
+-- +goose Down
+DROP INDEX follows_follower_id_created_at_idx;
+DROP INDEX follows_followee_id_created_at_idx;
+CREATE INDEX follows_followee_id_idx ON follows(followee_id);
\ No newline at end of file
@@ -0,0 +1,32 @@
+-- +goose Up
+-- Chirps copied into their followers' home timelines by the fan-out worker.
+CREATE TABLE timeline_entries(
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    chirp_id UUID NOT NULL REFERENCES chirps(id) ON DELETE CASCADE,
+    author_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    created_at TIMESTAMP NOT NULL,
+    PRIMARY KEY (user_id, chirp_id)
+);
+
+CREATE INDEX timeline_entries_user_id_created_at_idx ON timeline_entries(user_id, created_at DESC, chirp_id DESC);
+CREATE INDEX timeline_entries_user_id_author_id_idx ON timeline_entries(user_id, author_id);
+
+-- pending: waiting for the fan-out worker.
+-- done: copied into timeline_entries.
+-- read: not copied, home timelines pick it up at read time. This is what
+-- chirps of very large accounts and chirps older than this table get.
+ALTER TABLE chirps
+    ADD COLUMN "fanout" TEXT NOT NULL
+    DEFAULT 'read'
+    CHECK (fanout IN ('pending', 'done', 'read'));
+ALTER TABLE chirps
+    ALTER COLUMN "fanout" SET DEFAULT 'pending';
+
+CREATE INDEX chirps_fanout_pending_idx ON chirps(created_at) WHERE fanout = 'pending';
+CREATE INDEX chirps_user_id_created_at_idx ON chirps(user_id, created_at DESC, id DESC);
+
+-- +goose Down
+DROP INDEX chirps_user_id_created_at_idx;
+ALTER TABLE chirps
+    DROP COLUMN "fanout";
+DROP TABLE timeline_entries;
\ No newline at end of file
@@ -0,0 +1,83 @@
+-- +goose Up
+CREATE TABLE blocks(
+    blocker_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    blocked_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    created_at TIMESTAMP NOT NULL,
+    PRIMARY KEY (blocker_id, blocked_id),
+    CHECK (blocker_id <> blocked_id)
+);
+
+CREATE INDEX blocks_blocked_id_idx ON blocks(blocked_id);
+
+CREATE TABLE mutes(
+    muter_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    muted_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    created_at TIMESTAMP NOT NULL,
+    PRIMARY KEY (muter_id, muted_id),
+    CHECK (muter_id <> muted_id)
+);
+
+-- A block hides chirps both ways, on top of the visibility rules.
+-- +goose StatementBegin
+CREATE OR REPLACE FUNCTION chirp_is_visible(visibility TEXT, chirp UUID, author UUID, viewer UUID)
+RETURNS BOOLEAN
+LANGUAGE sql STABLE
+AS $$
+    SELECT author = viewer
+    OR (NOT EXISTS (
+        SELECT 1 FROM blocks
+        WHERE (blocks.blocker_id = author AND blocks.blocked_id = viewer)
+        OR (blocks.blocker_id = viewer AND blocks.blocked_id = author)
+    ) AND (
+        visibility = 'public'
+        OR EXISTS (
+            SELECT 1 FROM chirp_mentions
+            WHERE chirp_mentions.chirp_id = chirp
+            AND chirp_mentions.user_id = viewer
+        )
+        OR (visibility = 'followers' AND EXISTS (
+            SELECT 1 FROM follows
+            WHERE follows.follower_id = viewer
+            AND follows.followee_id = author
+        ))
+    ));
+$$;
+-- +goose StatementEnd
+
+-- Feeds and search leave out chirps of users the viewer muted.
+-- +goose StatementBegin
+CREATE FUNCTION chirp_is_muted(author UUID, viewer UUID)
+RETURNS BOOLEAN
+LANGUAGE sql STABLE
+AS $$
+    SELECT EXISTS (
+        SELECT 1 FROM mutes
+        WHERE mutes.muter_id = viewer
+        AND mutes.muted_id = author
+    );
+$$;
+-- +goose StatementEnd
+
+-- +goose Down
+DROP FUNCTION chirp_is_muted;
+-- +goose StatementBegin
+CREATE OR REPLACE FUNCTION chirp_is_visible(visibility TEXT, chirp UUID, author UUID, viewer UUID)
+RETURNS BOOLEAN
+LANGUAGE sql STABLE
+AS $$
+    SELECT visibility = 'public'
+    OR author = viewer
+    OR EXISTS (
+        SELECT 1 FROM chirp_mentions
+        WHERE chirp_mentions.chirp_id = chirp
+        AND chirp_mentions.user_id = viewer
+    )
+    OR (visibility = 'followers' AND EXISTS (
+        SELECT 1 FROM follows
+        WHERE follows.follower_id = viewer
+        AND follows.followee_id = author
+    ));
+$$;
+-- +goose StatementEnd
+DROP TABLE mutes;
+DROP TABLE blocks;
\ No newline at end of file
@@ -0,0 +1,80 @@
+-- +goose Up
+ALTER TABLE users
+    ADD COLUMN "is_protected" BOOLEAN NOT NULL
+    DEFAULT false;
+
+CREATE TABLE follow_requests(
+    requester_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    target_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    created_at TIMESTAMP NOT NULL,
+    PRIMARY KEY (requester_id, target_id),
+    CHECK (requester_id <> target_id)
+);
+
+CREATE INDEX follow_requests_target_id_created_at_idx ON follow_requests(target_id, created_at DESC, requester_id DESC);
+
+-- Chirps of protected accounts are only visible to their approved followers,
+-- whatever the chirp's own visibility.
+-- +goose StatementBegin
+CREATE OR REPLACE FUNCTION chirp_is_visible(visibility TEXT, chirp UUID, author UUID, viewer UUID)
+RETURNS BOOLEAN
+LANGUAGE sql STABLE
+AS $$
+    SELECT author = viewer
+    OR (NOT EXISTS (
+        SELECT 1 FROM blocks
+        WHERE (blocks.blocker_id = author AND blocks.blocked_id = viewer)
+        OR (blocks.blocker_id = viewer AND blocks.blocked_id = author)
+    ) AND (NOT EXISTS (
+        SELECT 1 FROM users
+        WHERE users.id = author
+        AND users.is_protected
+    ) OR EXISTS (
+        SELECT 1 FROM follows
+        WHERE follows.follower_id = viewer
+        AND follows.followee_id = author
+    )) AND (
+        visibility = 'public'
+        OR EXISTS (
+            SELECT 1 FROM chirp_mentions
+            WHERE chirp_mentions.chirp_id = chirp
+            AND chirp_mentions.user_id = viewer
+        )
+        OR (visibility = 'followers' AND EXISTS (
+            SELECT 1 FROM follows
+            WHERE follows.follower_id = viewer
+            AND follows.followee_id = author
+        ))
+    ));
+$$;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+CREATE OR REPLACE FUNCTION chirp_is_visible(visibility TEXT, chirp UUID, author UUID, viewer UUID)
+RETURNS BOOLEAN
+LANGUAGE sql STABLE
+AS $$
+    SELECT author = viewer
+    OR (NOT EXISTS (
+        SELECT 1 FROM blocks
+        WHERE (blocks.blocker_id = author AND blocks.blocked_id = viewer)
+        OR (blocks.blocker_id = viewer AND blocks.blocked_id = author)
+    ) AND (
+        visibility = 'public'
+        OR EXISTS (
+            SELECT 1 FROM chirp_mentions
+            WHERE chirp_mentions.chirp_id = chirp
+            AND chirp_mentions.user_id = viewer
+        )
+        OR (visibility = 'followers' AND EXISTS (
+            SELECT 1 FROM follows
+            WHERE follows.follower_id = viewer
+            AND follows.followee_id = author
+        ))
+    ));
+$$;
+-- +goose StatementEnd
+DROP TABLE follow_requests;
+ALTER TABLE users
+    DROP COLUMN "is_protected";
\ No newline at end of file
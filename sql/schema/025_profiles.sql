@@ -0,0 +1,18 @@
+-- +goose Up
+ALTER TABLE users
+    ADD COLUMN "display_name" TEXT NOT NULL
+    DEFAULT '';
+ALTER TABLE users
+    ADD COLUMN "bio" TEXT NOT NULL
+    DEFAULT '';
+ALTER TABLE users
+    ADD COLUMN "avatar_media_id" UUID
+    REFERENCES media(id) ON DELETE SET NULL;
+
+-- +goose Down
+ALTER TABLE users
+    DROP COLUMN "avatar_media_id";
+ALTER TABLE users
+    DROP COLUMN "bio";
+ALTER TABLE users
+    DROP COLUMN "display_name";
\ No newline at end of file
@@ -0,0 +1,18 @@
+-- +goose Up
+-- A session is a login, it keeps its id and authenticated_at through every
+-- refresh so access tokens can tell how long ago the password was typed.
+ALTER TABLE refresh_tokens
+    ADD COLUMN "session_id" UUID NOT NULL
+    DEFAULT gen_random_uuid();
+ALTER TABLE refresh_tokens
+    ADD COLUMN "authenticated_at" TIMESTAMP NOT NULL
+    DEFAULT NOW();
+
+CREATE INDEX refresh_tokens_user_id_idx ON refresh_tokens(user_id);
+
+-- +goose Down
+DROP INDEX refresh_tokens_user_id_idx;
+ALTER TABLE refresh_tokens
+    DROP COLUMN "authenticated_at";
+ALTER TABLE refresh_tokens
+    DROP COLUMN "session_id";
\ No newline at end of file
@@ -0,0 +1,19 @@
+-- +goose Up
+-- When set, the account is erased once this time has passed unless the user
+-- logs in before.
+ALTER TABLE users
+    ADD COLUMN "deletion_scheduled_at" TIMESTAMP;
+
+CREATE INDEX users_deletion_scheduled_at_idx ON users(deletion_scheduled_at)
+    WHERE deletion_scheduled_at IS NOT NULL;
+
+-- The placeholder author of tombstones. Its password is random and never
+-- handed out, and its email can't be taken by a real account.
+INSERT INTO users (id, created_at, updated_at, email, hashed_password, display_name)
+VALUES ('ffffffff-ffff-ffff-ffff-ffffffffffff', NOW(), NOW(), 'ghost@chirpy.invalid', md5(random()::text), 'Deleted user');
+
+-- +goose Down
+DELETE FROM users WHERE id = 'ffffffff-ffff-ffff-ffff-ffffffffffff';
+DROP INDEX users_deletion_scheduled_at_idx;
+ALTER TABLE users
+    DROP COLUMN "deletion_scheduled_at";
\ No newline at end of file
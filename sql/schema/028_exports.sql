@@ -0,0 +1,26 @@
+-- +goose Up
+-- Archives of a user's data. The worker builds pending ones, and ready ones
+-- are removed along with their archive once they expire.
+CREATE TABLE data_exports(
+    id UUID PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    status TEXT NOT NULL DEFAULT 'pending',
+    blob_key TEXT,
+    size_bytes BIGINT,
+    ready_at TIMESTAMP,
+    expires_at TIMESTAMP
+);
+
+CREATE INDEX data_exports_user_id_created_at_idx ON data_exports(user_id, created_at DESC);
+CREATE INDEX data_exports_pending_idx ON data_exports(created_at)
+    WHERE status = 'pending';
+
+ALTER TABLE notifications
+    ADD COLUMN "export_id" UUID
+    REFERENCES data_exports(id) ON DELETE CASCADE;
+
+-- +goose Down
+ALTER TABLE notifications
+    DROP COLUMN "export_id";
+DROP TABLE data_exports;
\ No newline at end of file
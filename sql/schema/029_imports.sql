@@ -0,0 +1,15 @@
+-- +goose Up
+-- Which chirps came from which row of an imported archive, so importing the
+-- same archive again skips them. The row stays when the chirp is deleted,
+-- so deleted chirps aren't brought back either.
+CREATE TABLE chirp_imports(
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    source TEXT NOT NULL,
+    source_id TEXT NOT NULL,
+    chirp_id UUID REFERENCES chirps(id) ON DELETE SET NULL,
+    imported_at TIMESTAMP NOT NULL,
+    PRIMARY KEY (user_id, source, source_id)
+);
+
+-- +goose Down
+DROP TABLE chirp_imports;
\ No newline at end of file
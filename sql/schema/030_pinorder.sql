@@ -0,0 +1,19 @@
+-- +goose Up
+-- Pins are shown by position, lowest first. Existing pins keep the order
+-- they had, most recently pinned first.
+ALTER TABLE pins
+    ADD COLUMN "position" INTEGER NOT NULL DEFAULT 0;
+
+UPDATE pins
+SET position = ranked.position
+FROM (
+    SELECT pins.user_id, pins.chirp_id,
+    ROW_NUMBER() OVER (PARTITION BY pins.user_id ORDER BY pins.created_at DESC, pins.chirp_id DESC) - 1 AS position
+    FROM pins
+) AS ranked
+WHERE pins.user_id = ranked.user_id
+AND pins.chirp_id = ranked.chirp_id;
+
+-- +goose Down
+ALTER TABLE pins
+    DROP COLUMN "position";
\ No newline at end of file
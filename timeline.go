@@ -0,0 +1,134 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/Serux/chirpy/internal/database"
+)
+
+const fanoutBatchSize = 100
+
+// Accounts with more followers than this aren't fanned out, their chirps are
+// merged into home timelines at read time instead.
+const fanoutMaxFollowers = 10000
+
+// How many of an account's latest chirps are copied into a new follower's
+// home timeline.
+const timelineBackfillSize = 100
+
+// fanOutChirps copies one batch of newly published chirps into the home
+// timelines of their authors' followers and returns how many it handled. Like
+// the scheduler, the batch stays locked until the transaction commits and a
+// failure leaves it to be retried.
+func (cfg *apiConfig) fanOutChirps(ctx context.Context) (int, error) {
+	tx, err := cfg.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+	q := cfg.queries.WithTx(tx)
+
+	chirps, err := q.SelectChirpsToFanOut(ctx, fanoutBatchSize)
+	if err != nil {
+		return 0, err
+	}
+	for _, ch := range chirps {
+		fanout := "read"
+		if !ch.DeletedAt.Valid {
+			counts, err := q.CountFollows(ctx, ch.UserID)
+			if err != nil {
+				return 0, err
+			}
+			if counts.Followers <= fanoutMaxFollowers {
+				err = q.FanOutChirp(ctx, ch.ID)
+				if err != nil {
+					return 0, err
+				}
+				fanout = "done"
+			}
+		}
+		err = q.SetChirpFanout(ctx, database.SetChirpFanoutParams{Fanout: fanout, ID: ch.ID})
+		if err != nil {
+			return 0, err
+		}
+	}
+	return len(chirps), tx.Commit()
+}
+
+// runFanoutWorker fans out new chirps every tick until ctx is cancelled,
+// going again right away after a full batch.
+func (cfg *apiConfig) runFanoutWorker(ctx context.Context, every time.Duration) {
+	ticker := time.NewTicker(every)
+	defer ticker.Stop()
+	for {
+		n, err := cfg.fanOutChirps(ctx)
+		if err != nil {
+			fmt.Println("ERROR FANNING OUT CHIRPS", err)
+		}
+		if err == nil && n == fanoutBatchSize {
+			continue
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// getHomeTimelineHandler serves the chirps of the accounts the user follows,
+// and their own, newest first.
+func (cfg *apiConfig) getHomeTimelineHandler(rw http.ResponseWriter, r *http.Request) {
+	uidtok, err := cfg.authenticatedUserId(r)
+	if err != nil {
+		respondWithError(rw, http.StatusUnauthorized, "Something went wrong validating JWT")
+		return
+	}
+	limit, err := pageLimit(r)
+	if err != nil {
+		respondWithError(rw, http.StatusBadRequest, err.Error())
+		return
+	}
+	params := database.SelectHomeTimelinePageParams{ViewerID: uidtok, MaxResults: int32(limit + 1)}
+	if r.URL.Query().Has("cursor") {
+		cursor, err := parseChirpCursor(r.URL.Query().Get("cursor"))
+		if err != nil || cursor.backwards {
+			respondWithError(rw, http.StatusBadRequest, "Invalid cursor")
+			return
+		}
+		params.CursorCreatedAt = sql.NullTime{Time: cursor.createdAt, Valid: true}
+		params.CursorID = cursor.id
+	}
+	sensitiveContent, err := cfg.sensitiveContentPreference(r.Context(), uidtok)
+	if err != nil {
+		respondWithError(rw, http.StatusInternalServerError, "Something went wrong getting preferences")
+		return
+	}
+	params.HideSensitive = sensitiveContent == "hide"
+
+	chirps, err := cfg.queries.SelectHomeTimelinePage(r.Context(), params)
+	if err != nil {
+		respondWithError(rw, http.StatusInternalServerError, "Something went wrong getting timeline")
+		return
+	}
+	hasMore := len(chirps) > limit
+	if hasMore {
+		chirps = chirps[:limit]
+	}
+
+	ret, err := cfg.chirpsToJson(r.Context(), uidtok, chirps)
+	if err != nil {
+		respondWithError(rw, http.StatusInternalServerError, "Something went wrong loading chirps")
+		return
+	}
+	cfg.recordImpressions(uidtok, ret)
+
+	if hasMore {
+		setPageLinks(rw, r, chirpCursor(chirps[len(chirps)-1], false), "")
+	}
+	respondWithJSON(rw, http.StatusOK, ret)
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/Serux/chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+// How long deleted chirps stay restorable before they are purged.
+const trashRetention = 30 * 24 * time.Hour
+const purgeBatchSize = 100
+
+func (cfg *apiConfig) getTrashHandler(rw http.ResponseWriter, r *http.Request) {
+	uidtok, err := cfg.authenticatedUserId(r)
+	if err != nil {
+		respondWithError(rw, http.StatusUnauthorized, "Something went wrong validating JWT")
+		return
+	}
+
+	chirps, err := cfg.queries.SelectTrashChirpsUser(r.Context(), database.SelectTrashChirpsUserParams{UserID: uidtok, RetentionSeconds: trashRetention.Seconds()})
+	if err != nil {
+		respondWithError(rw, http.StatusInternalServerError, "Something went wrong getting trash")
+		return
+	}
+
+	ret, err := cfg.chirpsToJson(r.Context(), uidtok, chirps)
+	if err != nil {
+		respondWithError(rw, http.StatusInternalServerError, "Something went wrong loading chirps")
+		return
+	}
+	respondWithJSON(rw, http.StatusOK, ret)
+}
+
+func (cfg *apiConfig) restoreChirpHandler(rw http.ResponseWriter, r *http.Request) {
+	uidtok, err := cfg.authenticatedUserId(r)
+	if err != nil {
+		respondWithError(rw, http.StatusUnauthorized, "Something went wrong validating JWT")
+		return
+	}
+	chirpId, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		respondWithError(rw, http.StatusNotFound, "Chirp not found in trash")
+		return
+	}
+
+	chirp, err := cfg.queries.RestoreChirp(r.Context(), database.RestoreChirpParams{ID: chirpId, UserID: uidtok, RetentionSeconds: trashRetention.Seconds()})
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(rw, http.StatusNotFound, "Chirp not found in trash")
+		return
+	}
+	if err != nil {
+		respondWithError(rw, http.StatusInternalServerError, "Something went wrong restoring chirp")
+		return
+	}
+
+	ret, err := cfg.chirpsToJson(r.Context(), uidtok, []database.Chirp{chirp})
+	if err != nil {
+		respondWithError(rw, http.StatusInternalServerError, "Something went wrong loading chirp")
+		return
+	}
+	respondWithJSON(rw, http.StatusOK, ret[0])
+}
+
+// purgeDeletedChirps hard-deletes one batch of chirps that have been in the
+// trash for longer than trashRetention, together with their media. Everything
+// else hanging off a chirp goes with it through ON DELETE CASCADE. It returns
+// how many chirps it purged.
+func (cfg *apiConfig) purgeDeletedChirps(ctx context.Context) (int, error) {
+	tx, err := cfg.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+	q := cfg.queries.WithTx(tx)
+
+	expired, err := q.SelectExpiredDeletedChirps(ctx, database.SelectExpiredDeletedChirpsParams{RetentionSeconds: trashRetention.Seconds(), MaxResults: purgeBatchSize})
+	if err != nil || len(expired) == 0 {
+		return 0, err
+	}
+	ids := []uuid.UUID{}
+	for _, ch := range expired {
+		ids = append(ids, ch.ID)
+	}
+
+	chirpMedia, err := q.SelectMediaForChirps(ctx, ids)
+	if err != nil {
+		return 0, err
+	}
+	mediaIds := []uuid.UUID{}
+	for _, m := range chirpMedia {
+		mediaIds = append(mediaIds, m.Medium.ID)
+	}
+	err = q.DeleteMediaByIds(ctx, mediaIds)
+	if err != nil {
+		return 0, err
+	}
+	err = q.DeleteChirpsByIds(ctx, ids)
+	if err != nil {
+		return 0, err
+	}
+	err = tx.Commit()
+	if err != nil {
+		return 0, err
+	}
+
+	// Files go once their rows are gone. If this fails we only leave
+	// unreachable files behind, never rows pointing at missing files.
+	for _, m := range chirpMedia {
+		for _, key := range []string{m.Medium.BlobKey, m.Medium.ThumbnailKey} {
+			err = cfg.blobs.Delete(ctx, key)
+			if err != nil {
+				fmt.Println("ERROR DELETING PURGED MEDIA", key, err)
+			}
+		}
+	}
+	return len(expired), nil
+}
+
+// runPurgeWorker purges expired chirps from the trash every tick until ctx is
+// cancelled, going again right away while there are full batches to purge.
+func (cfg *apiConfig) runPurgeWorker(ctx context.Context, every time.Duration) {
+	ticker := time.NewTicker(every)
+	defer ticker.Stop()
+	for {
+		n, err := cfg.purgeDeletedChirps(ctx)
+		if err != nil {
+			fmt.Println("ERROR PURGING DELETED CHIRPS", err)
+		}
+		if err == nil && n == purgeBatchSize {
+			continue
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,232 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/Serux/chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+const defaultAnalyticsDays = 30
+const maxAnalyticsDays = 90
+
+type viewKey struct {
+	chirpId uuid.UUID
+	day     time.Time
+}
+
+type viewCounts struct {
+	views       int64
+	impressions int64
+}
+
+// viewCounter adds up chirp views (the chirp was opened) and impressions (it
+// was shown in a list) in memory, so serving a chirp never writes to the
+// database. flushViews moves the counts into chirp_daily_stats.
+type viewCounter struct {
+	mu     sync.Mutex
+	counts map[viewKey]viewCounts
+}
+
+func (vc *viewCounter) add(key viewKey, c viewCounts) {
+	vc.mu.Lock()
+	defer vc.mu.Unlock()
+	if vc.counts == nil {
+		vc.counts = map[viewKey]viewCounts{}
+	}
+	old := vc.counts[key]
+	vc.counts[key] = viewCounts{views: old.views + c.views, impressions: old.impressions + c.impressions}
+}
+
+// take returns the counts so far and starts over.
+func (vc *viewCounter) take() map[viewKey]viewCounts {
+	vc.mu.Lock()
+	defer vc.mu.Unlock()
+	counts := vc.counts
+	vc.counts = nil
+	return counts
+}
+
+func today() time.Time {
+	return time.Now().UTC().Truncate(24 * time.Hour)
+}
+
+// recordView counts a chirp being opened. Authors looking at their own chirps
+// don't count.
+func (cfg *apiConfig) recordView(viewer uuid.UUID, chirp fullChirpJsonDb) {
+	if chirp.UserId == viewer.String() {
+		return
+	}
+	id, err := uuid.Parse(chirp.Id)
+	if err != nil {
+		return
+	}
+	cfg.views.add(viewKey{chirpId: id, day: today()}, viewCounts{views: 1})
+}
+
+// recordImpressions counts every chirp of a list as it was served.
+func (cfg *apiConfig) recordImpressions(viewer uuid.UUID, chirps []fullChirpJsonDb) {
+	day := today()
+	for _, ch := range chirps {
+		if ch.UserId == viewer.String() {
+			continue
+		}
+		id, err := uuid.Parse(ch.Id)
+		if err != nil {
+			continue
+		}
+		cfg.views.add(viewKey{chirpId: id, day: day}, viewCounts{impressions: 1})
+	}
+}
+
+// flushViews writes the counts gathered since the last flush in one
+// transaction. If that fails they are put back to be tried again next time.
+func (cfg *apiConfig) flushViews(ctx context.Context) error {
+	counts := cfg.views.take()
+	if len(counts) == 0 {
+		return nil
+	}
+	err := cfg.writeViews(ctx, counts)
+	if err != nil {
+		for key, c := range counts {
+			cfg.views.add(key, c)
+		}
+	}
+	return err
+}
+
+func (cfg *apiConfig) writeViews(ctx context.Context, counts map[viewKey]viewCounts) error {
+	tx, err := cfg.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	q := cfg.queries.WithTx(tx)
+
+	for key, c := range counts {
+		err = q.AddChirpDailyStats(ctx, database.AddChirpDailyStatsParams{
+			Day:         key.day,
+			Views:       c.views,
+			Impressions: c.impressions,
+			ChirpID:     key.chirpId,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// runViewsFlusher flushes the view counters every tick until ctx is
+// cancelled, and once more on the way out.
+func (cfg *apiConfig) runViewsFlusher(ctx context.Context, every time.Duration) {
+	ticker := time.NewTicker(every)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			err := cfg.flushViews(context.Background())
+			if err != nil {
+				fmt.Println("ERROR FLUSHING CHIRP VIEWS", err)
+			}
+			return
+		case <-ticker.C:
+		}
+		err := cfg.flushViews(ctx)
+		if err != nil {
+			fmt.Println("ERROR FLUSHING CHIRP VIEWS", err)
+		}
+	}
+}
+
+// parseAnalyticsRange reads the since/until dates (YYYY-MM-DD, both
+// included) of an analytics request. It defaults to the last 30 days.
+func parseAnalyticsRange(r *http.Request) (time.Time, time.Time, error) {
+	query := r.URL.Query()
+	until := today()
+	if query.Has("until") {
+		t, err := time.Parse(time.DateOnly, query.Get("until"))
+		if err != nil {
+			return time.Time{}, time.Time{}, fmt.Errorf("Invalid until, expected YYYY-MM-DD")
+		}
+		until = t
+	}
+	since := until.AddDate(0, 0, -(defaultAnalyticsDays - 1))
+	if query.Has("since") {
+		t, err := time.Parse(time.DateOnly, query.Get("since"))
+		if err != nil {
+			return time.Time{}, time.Time{}, fmt.Errorf("Invalid since, expected YYYY-MM-DD")
+		}
+		since = t
+	}
+	if since.After(until) {
+		return time.Time{}, time.Time{}, fmt.Errorf("since must not be after until")
+	}
+	if until.Sub(since) >= maxAnalyticsDays*24*time.Hour {
+		return time.Time{}, time.Time{}, fmt.Errorf("The range can cover at most %d days", maxAnalyticsDays)
+	}
+	return since, until, nil
+}
+
+func (cfg *apiConfig) getAnalyticsHandler(rw http.ResponseWriter, r *http.Request) {
+	type dayJson struct {
+		Date        string `json:"date"`
+		Views       int64  `json:"views"`
+		Impressions int64  `json:"impressions"`
+		Replies     int64  `json:"replies"`
+	}
+	type chirpStatsJson struct {
+		ChirpId     string    `json:"chirp_id"`
+		Views       int64     `json:"views"`
+		Impressions int64     `json:"impressions"`
+		Replies     int64     `json:"replies"`
+		Days        []dayJson `json:"days"`
+	}
+	type responseJson struct {
+		Since  string           `json:"since"`
+		Until  string           `json:"until"`
+		Chirps []chirpStatsJson `json:"chirps"`
+	}
+
+	uidtok, err := cfg.authenticatedUserId(r)
+	if err != nil {
+		respondWithError(rw, http.StatusUnauthorized, "Something went wrong validating JWT")
+		return
+	}
+	since, until, err := parseAnalyticsRange(r)
+	if err != nil {
+		respondWithError(rw, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	rows, err := cfg.queries.SelectDailyChirpStatsUser(r.Context(), database.SelectDailyChirpStatsUserParams{UserID: uidtok, Since: since, Until: until})
+	if err != nil {
+		respondWithError(rw, http.StatusInternalServerError, "Something went wrong getting analytics")
+		return
+	}
+
+	// Rows come ordered by chirp, then day.
+	ret := responseJson{Since: since.Format(time.DateOnly), Until: until.Format(time.DateOnly), Chirps: []chirpStatsJson{}}
+	for _, row := range rows {
+		n := len(ret.Chirps)
+		if n == 0 || ret.Chirps[n-1].ChirpId != row.ChirpID.String() {
+			ret.Chirps = append(ret.Chirps, chirpStatsJson{ChirpId: row.ChirpID.String(), Days: []dayJson{}})
+			n++
+		}
+		c := &ret.Chirps[n-1]
+		c.Views += row.Views
+		c.Impressions += row.Impressions
+		c.Replies += row.Replies
+		c.Days = append(c.Days, dayJson{
+			Date:        row.Day.Format(time.DateOnly),
+			Views:       row.Views,
+			Impressions: row.Impressions,
+			Replies:     row.Replies,
+		})
+	}
+	respondWithJSON(rw, http.StatusOK, ret)
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestViewCounter(t *testing.T) {
+	vc := viewCounter{}
+	if got := vc.take(); len(got) != 0 {
+		t.Errorf("empty take = %v", got)
+	}
+
+	a := viewKey{chirpId: uuid.New(), day: today()}
+	b := viewKey{chirpId: a.chirpId, day: a.day.AddDate(0, 0, -1)}
+	wg := sync.WaitGroup{}
+	for range 100 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			vc.add(a, viewCounts{views: 1})
+			vc.add(a, viewCounts{impressions: 2})
+			vc.add(b, viewCounts{views: 1, impressions: 1})
+		}()
+	}
+	wg.Wait()
+
+	got := vc.take()
+	want := map[viewKey]viewCounts{a: {views: 100, impressions: 200}, b: {views: 100, impressions: 100}}
+	if len(got) != len(want) || got[a] != want[a] || got[b] != want[b] {
+		t.Errorf("take = %v, want %v", got, want)
+	}
+	if got := vc.take(); len(got) != 0 {
+		t.Errorf("take after take = %v, want nothing", got)
+	}
+}
+
+func TestRecordViewsSkipsAuthor(t *testing.T) {
+	cfg := &apiConfig{}
+	author := uuid.New()
+	viewer := uuid.New()
+	own := fullChirpJsonDb{Id: uuid.NewString(), UserId: author.String()}
+	other := fullChirpJsonDb{Id: uuid.NewString(), UserId: viewer.String()}
+
+	cfg.recordView(author, own)
+	cfg.recordView(viewer, own)
+	cfg.recordImpressions(author, []fullChirpJsonDb{own, other, {Id: "not a uuid"}})
+
+	got := cfg.views.take()
+	ownKey := viewKey{chirpId: uuid.MustParse(own.Id), day: today()}
+	otherKey := viewKey{chirpId: uuid.MustParse(other.Id), day: today()}
+	if len(got) != 2 || got[ownKey] != (viewCounts{views: 1}) || got[otherKey] != (viewCounts{impressions: 1}) {
+		t.Errorf("counts = %v, want one view of own and one impression of other", got)
+	}
+}
+
+// TestFlushViewsKeepsCountsOnFailure flushes into a closed database, which
+// fails without needing a server.
+func TestFlushViewsKeepsCountsOnFailure(t *testing.T) {
+	db, err := sql.Open("postgres", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.Close()
+	cfg := &apiConfig{db: db}
+	key := viewKey{chirpId: uuid.New(), day: today()}
+	cfg.views.add(key, viewCounts{views: 3, impressions: 4})
+
+	if err := cfg.flushViews(context.Background()); err == nil {
+		t.Fatal("flushing into a closed database didn't fail")
+	}
+	cfg.views.add(key, viewCounts{views: 1})
+	if got := cfg.views.take(); got[key] != (viewCounts{views: 4, impressions: 4}) {
+		t.Errorf("counts after a failed flush = %v, want them kept", got)
+	}
+}
+
+func TestParseAnalyticsRange(t *testing.T) {
+	day := func(s string) time.Time {
+		d, err := time.Parse(time.DateOnly, s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return d
+	}
+	for _, c := range []struct {
+		name      string
+		query     string
+		wantSince time.Time
+		wantUntil time.Time
+		wantErr   bool
+	}{
+		{"default", "", today().AddDate(0, 0, -(defaultAnalyticsDays - 1)), today(), false},
+		{"until only", "until=2024-03-31", day("2024-03-02"), day("2024-03-31"), false},
+		{"since only", "since=" + today().AddDate(0, 0, -6).Format(time.DateOnly), today().AddDate(0, 0, -6), today(), false},
+		{"both", "since=2024-01-01&until=2024-01-31", day("2024-01-01"), day("2024-01-31"), false},
+		{"one day", "since=2024-01-01&until=2024-01-01", day("2024-01-01"), day("2024-01-01"), false},
+		{"longest range", "since=2024-01-01&until=2024-03-30", day("2024-01-01"), day("2024-03-30"), false},
+		{"too long", "since=2024-01-01&until=2024-03-31", time.Time{}, time.Time{}, true},
+		{"since only too long ago", "since=2000-01-01", time.Time{}, time.Time{}, true},
+		{"since after until", "since=2024-02-01&until=2024-01-31", time.Time{}, time.Time{}, true},
+		{"not a date", "until=yesterday", time.Time{}, time.Time{}, true},
+		{"no such day", "until=2024-02-30", time.Time{}, time.Time{}, true},
+		{"unpadded", "since=2024-1-1&until=2024-01-31", time.Time{}, time.Time{}, true},
+		{"time included", "until=2024-01-31T00:00:00Z", time.Time{}, time.Time{}, true},
+		{"empty", "until=", time.Time{}, time.Time{}, true},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/api/users/me/analytics?"+c.query, nil)
+			since, until, err := parseAnalyticsRange(r)
+			if (err != nil) != c.wantErr {
+				t.Fatalf("error = %v, want error %v", err, c.wantErr)
+			}
+			if !since.Equal(c.wantSince) || !until.Equal(c.wantUntil) {
+				t.Errorf("range = %s..%s, want %s..%s", since, until, c.wantSince, c.wantUntil)
+			}
+		})
+	}
+}
+
+// TestAnalyticsDaysAgree checks that a reply and a view from the same moment
+// land on the same (UTC) day of the analytics.
+func TestAnalyticsDaysAgree(t *testing.T) {
+	cfg := testConfig(t)
+	alice := createTestUser(t, cfg, "alice")
+	bob := createTestUser(t, cfg, "bob")
+	chirp := createTestChirp(t, cfg, alice, "public", "hello")
+	createTestReply(t, cfg, bob, chirp, "public", "hi")
+	cfg.recordView(bob, fullChirpJsonDb{Id: chirp.String(), UserId: alice.String()})
+	if err := cfg.flushViews(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	rw := serve(t, cfg.getAnalyticsHandler, alice, "/api/users/me/analytics", nil)
+	if rw.Code != http.StatusOK {
+		t.Fatalf("GET analytics = %d: %s", rw.Code, rw.Body.String())
+	}
+	got := struct {
+		Chirps []struct {
+			ChirpId string `json:"chirp_id"`
+			Days    []struct {
+				Date    string `json:"date"`
+				Views   int64  `json:"views"`
+				Replies int64  `json:"replies"`
+			} `json:"days"`
+		} `json:"chirps"`
+	}{}
+	if err := json.Unmarshal(rw.Body.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	if len(got.Chirps) != 1 || got.Chirps[0].ChirpId != chirp.String() || len(got.Chirps[0].Days) != 1 {
+		t.Fatalf("analytics = %s, want one day of one chirp", rw.Body.String())
+	}
+	d := got.Chirps[0].Days[0]
+	if d.Date != today().Format(time.DateOnly) || d.Views != 1 || d.Replies != 1 {
+		t.Errorf("day = %+v, want one view and one reply on %s", d, today().Format(time.DateOnly))
+	}
+}
@@ -0,0 +1,25 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+)
+
+const defaultChirpVisibility = "public"
+
+// chirpVisibilities are the audiences a chirp can be posted to. Who can see
+// what is decided in SQL by chirp_is_visible (see sql/schema), so every read
+// query has to call it with the viewer: public chirps are visible to
+// everybody, followers chirps to the author's followers and mentioned users
+// chirps only to the users they mention. The author always sees their own.
+var chirpVisibilities = []string{"public", "followers", "mentioned"}
+
+func parseChirpVisibility(value string) (string, error) {
+	if value == "" {
+		return defaultChirpVisibility, nil
+	}
+	if !slices.Contains(chirpVisibilities, value) {
+		return "", fmt.Errorf("Invalid visibility %q, expected one of public, followers or mentioned", value)
+	}
+	return value, nil
+}
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+// The tests in this file only read the SQL, to catch a new query that
+// forgets a check. readpaths_test.go runs the read paths against a database.
+
+// Queries that read chirps without checking visibility, and why that's fine.
+var visibilityExempt = map[string]string{
+	"SelectScheduledChirpsUser":   "only returns the author's own chirps",
+	"SelectDueScheduledChirps":    "used by the scheduler, never returned to a user",
+	"SelectTrashChirpsUser":       "only returns the author's own chirps",
+	"SelectExpiredDeletedChirps":  "used by the purge worker, never returned to a user",
+	"SelectChirpsMissingEntities": "used by the entities backfill, never returned to a user",
+	"SelectDailyChirpStatsUser":   "only counts the author's own chirps and their replies",
+	"SelectChirpsToFanOut":        "used by the fan-out worker, never returned to a user",
+	"SelectChirpsForExport":       "only returns the author's own chirps",
+}
+
+// Queries that are meant to read chirps from the trash.
+var deletedExempt = map[string]string{
+	"SelectTrashChirpsUser":       "lists the author's trash",
+	"SelectExpiredDeletedChirps":  "used by the purge worker",
+	"SelectChirpsMissingEntities": "the backfill covers chirps in the trash too",
+	"SelectChirpsToFanOut":        "deleted chirps are marked as fanned out too, and filtered on read",
+	"SelectChirpsForExport":       "the export includes the author's trash",
+}
+
+var queryNameRegexp = regexp.MustCompile(`(?m)^-- name: (\w+) :\w+`)
+var readsChirpsRegexp = regexp.MustCompile(`(?i)\b(FROM|JOIN)\s+chirps\b`)
+
+// loadQueries returns the SQL of every named query under sql/queries.
+func loadQueries(t *testing.T) map[string]string {
+	t.Helper()
+	files, err := filepath.Glob(filepath.Join("sql", "queries", "*.sql"))
+	if err != nil || len(files) == 0 {
+		t.Fatalf("no query files found: %v", err)
+	}
+	queries := map[string]string{}
+	for _, f := range files {
+		data, err := os.ReadFile(f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		text := string(data)
+		locs := queryNameRegexp.FindAllStringSubmatchIndex(text, -1)
+		for i, loc := range locs {
+			end := len(text)
+			if i+1 < len(locs) {
+				end = locs[i+1][0]
+			}
+			queries[text[loc[2]:loc[3]]] = text[loc[1]:end]
+		}
+	}
+	return queries
+}
+
+// statement strips the leading comments so the query starts at its keyword.
+func statement(sql string) string {
+	lines := []string{}
+	for _, line := range strings.Split(sql, "\n") {
+		if strings.HasPrefix(strings.TrimSpace(line), "--") || (len(lines) == 0 && strings.TrimSpace(line) == "") {
+			continue
+		}
+		lines = append(lines, line)
+	}
+	return strings.Join(lines, "\n")
+}
+
+func TestEveryChirpReadChecksVisibility(t *testing.T) {
+	for name, sql := range loadQueries(t) {
+		stmt := strings.ToUpper(statement(sql))
+		if !strings.HasPrefix(stmt, "SELECT") && !strings.HasPrefix(stmt, "WITH") {
+			continue
+		}
+		if !readsChirpsRegexp.MatchString(sql) {
+			continue
+		}
+		if _, ok := visibilityExempt[name]; ok {
+			continue
+		}
+		if !strings.Contains(sql, "chirp_is_visible(") && !strings.Contains(sql, "chirps.visibility = 'public'") {
+			t.Errorf("%s reads chirps without chirp_is_visible or a public-only filter", name)
+		}
+		if !strings.Contains(sql, "chirp_is_visible(") && !strings.Contains(sql, "users.is_protected") {
+			t.Errorf("%s reads public chirps without leaving out protected accounts", name)
+		}
+	}
+}
+
+func TestEveryChirpReadExcludesDeleted(t *testing.T) {
+	for name, sql := range loadQueries(t) {
+		stmt := strings.ToUpper(statement(sql))
+		if !strings.HasPrefix(stmt, "SELECT") && !strings.HasPrefix(stmt, "WITH") {
+			continue
+		}
+		if !readsChirpsRegexp.MatchString(sql) {
+			continue
+		}
+		if _, ok := deletedExempt[name]; ok {
+			continue
+		}
+		if !strings.Contains(sql, "chirps.deleted_at IS NULL") {
+			t.Errorf("%s reads chirps without excluding deleted ones", name)
+		}
+	}
+}
+
+func TestReadPathsFilterByViewer(t *testing.T) {
+	queries := loadQueries(t)
+	paths := map[string]string{
+		"list ascending":  "SelectChirpsPageAsc",
+		"list descending": "SelectChirpsPageDesc",
+		"single get":      "SelectOneChirps",
+		"thread":          "SelectRepliesPage",
+		"search":          "SearchChirps",
+		"home timeline":   "SelectHomeTimelinePage",
+		"list timeline":   "SelectListTimelinePage",
+	}
+	for path, name := range paths {
+		sql, ok := queries[name]
+		if !ok {
+			t.Errorf("%s: query %s not found", path, name)
+			continue
+		}
+		if !strings.Contains(sql, "chirp_is_visible(chirps.visibility, chirps.id, chirps.user_id,") {
+			t.Errorf("%s: %s doesn't check visibility for the viewer", path, name)
+		}
+	}
+}
+
+func TestFeedsHideMutedUsers(t *testing.T) {
+	queries := loadQueries(t)
+	for _, name := range []string{"SelectChirpsPageAsc", "SelectChirpsPageDesc", "SearchChirps", "SelectHomeTimelinePage", "SelectListTimelinePage", "SelectRepliesPage", "SelectNotificationsUser"} {
+		sql, ok := queries[name]
+		if !ok {
+			t.Errorf("query %s not found", name)
+			continue
+		}
+		if !strings.Contains(sql, "chirp_is_muted(") {
+			t.Errorf("%s doesn't leave out muted users", name)
+		}
+	}
+}
+
+func TestVisibilityExemptionsStillExist(t *testing.T) {
+	queries := loadQueries(t)
+	for name := range visibilityExempt {
+		if _, ok := queries[name]; !ok {
+			t.Errorf("exempt query %s no longer exists, remove it from visibilityExempt", name)
+		}
+	}
+	for name := range deletedExempt {
+		if _, ok := queries[name]; !ok {
+			t.Errorf("exempt query %s no longer exists, remove it from deletedExempt", name)
+		}
+	}
+}
+
+func TestParseChirpVisibility(t *testing.T) {
+	cases := []struct {
+		in      string
+		want    string
+		wantErr bool
+	}{
+		{"", "public", false},
+		{"public", "public", false},
+		{"followers", "followers", false},
+		{"mentioned", "mentioned", false},
+		{"private", "", true},
+		{"PUBLIC", "", true},
+	}
+	for _, c := range cases {
+		got, err := parseChirpVisibility(c.in)
+		if (err != nil) != c.wantErr || got != c.want {
+			t.Errorf("parseChirpVisibility(%q) = %q, %v", c.in, got, err)
+		}
+	}
+}